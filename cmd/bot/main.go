@@ -4,7 +4,10 @@ import (
 	"context"
 	"log"
 
+	"github.com/Bariban/vector-shop-bot/pkg/ann"
+	"github.com/Bariban/vector-shop-bot/pkg/blob"
 	"github.com/Bariban/vector-shop-bot/pkg/config"
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
 	"github.com/Bariban/vector-shop-bot/pkg/storage/postgres"
 	"github.com/Bariban/vector-shop-bot/pkg/telegram"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
@@ -22,7 +25,7 @@ func main() {
 	}
 	botApi.Debug = true
 
-	storage, err := postgres.New()
+	storage, err := postgres.New(cfg.DatabaseURL)
 	if err != nil {
 		log.Fatal("can't connect to storage: ", err)
 	}
@@ -30,9 +33,76 @@ func main() {
 		log.Fatal("can't init storage: ", err)
 	}
 
-	bot := telegram.NewBot(botApi, storage, cfg.Messages)
+	blobs, err := newBlobStore(cfg.Blob)
+	if err != nil {
+		log.Fatal("can't init blob store: ", err)
+	}
+	// Переносим фото, которые ещё лежат в базе
+	migrated, err := storage.MigrateBlobs(context.Background(), blobs)
+	if err != nil {
+		log.Fatal("can't migrate photos to blob store: ", err)
+	}
+	if migrated > 0 {
+		log.Printf("moved %d photos to blob store", migrated)
+	}
+	// Переводим векторы из текста в двоичный формат
+	converted, dropped, err := storage.MigrateVectors(context.Background())
+	if err != nil {
+		log.Fatal("can't migrate vectors: ", err)
+	}
+	if converted > 0 {
+		log.Printf("converted %d vectors to binary format", converted)
+	}
+	if dropped > 0 {
+		log.Printf("dropped %d unparsable vectors, run /reindex to recompute them", dropped)
+	}
+
+	metric, err := recognize.ParseMetric(cfg.Matching.Metric)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if metric == recognize.MetricDot && !cfg.Matching.Normalize {
+		log.Fatal("metric dot requires matching.normalize: true")
+	}
+
+	recognizer := recognize.NewClient(cfg.ClipURL)
+	if cfg.ClipModel != "" {
+		recognizer.Model = cfg.ClipModel
+	}
+	recognizer.Normalize = cfg.Matching.Normalize
+	bot := telegram.NewBot(telegram.NewTelegramMessenger(botApi), storage, recognizer, blobs, cfg.Messages)
+	bot.SetMaxDiscount(cfg.MaxDiscount)
+	bot.SetMatching(metric, cfg.Matching.Threshold)
+	// Фото, не распознанные сразу, индексируются в фоне и переживают перезапуск
+	bot.SetJobQueue(storage.Jobs())
+
+	if cfg.VectorIndex.Dir != "" {
+		registry, err := ann.NewRegistry(cfg.VectorIndex.Dir, recognizer.ActiveModel(), metric)
+		if err != nil {
+			log.Fatal("can't init vector index: ", err)
+		}
+		bot.SetVectorIndex(registry)
+		if err := bot.LoadVectorIndex(context.Background()); err != nil {
+			log.Fatal("can't load vector index: ", err)
+		}
+		go bot.RunVectorSnapshots(context.Background(), cfg.VectorIndex.SnapshotInterval)
+	}
+	// Индексатор добавляет векторы в индекс фото, поэтому запускается после его загрузки
+	go bot.RunIndexer(context.Background())
 
 	if err := bot.Start(); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// newBlobStore выбирает S3, если задан endpoint, иначе каталог на диске
+func newBlobStore(cfg config.Blob) (blob.BlobStore, error) {
+	if cfg.S3Endpoint != "" {
+		return blob.NewS3(cfg.S3Endpoint, cfg.S3Bucket, cfg.S3Region, cfg.S3AccessKey, cfg.S3SecretKey), nil
+	}
+	dir := cfg.Dir
+	if dir == "" {
+		dir = "data/blobs"
+	}
+	return blob.NewFS(dir)
+}
@@ -0,0 +1,305 @@
+// Команда calibrate подбирает порог совпадения фото по размеченным парам из базы.
+// По умолчанию пары размечаются по товарам: фото одного товара — одно и то же,
+// фото разных товаров одного магазина — разное. Файл -pairs задаёт разметку явно:
+// строки image_a,image_b,same с ID фото из базы и true/false. Для каждой метрики
+// команда считает точность и полноту на разных порогах и рекомендует порог.
+// Модель, нормализация и база по умолчанию берутся из configs/main.yml, как у бота.
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/config"
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	"github.com/Bariban/vector-shop-bot/pkg/storage/postgres"
+)
+
+// pair размеченная пара фото
+type pair struct {
+	a, b *storage.ImageMeta
+	same bool
+}
+
+// scored пара с расстоянием по метрике
+type scored struct {
+	distance float64
+	same     bool
+}
+
+// point точность и полнота на пороге
+type point struct {
+	threshold, precision, recall, f1 float64
+}
+
+func main() {
+	defaults := botDefaults()
+	database := flag.String("database", defaults.DatabaseURL, "строка подключения к базе")
+	shop := flag.String("shop", "", "магазин, пусто — все магазины")
+	model := flag.String("model", defaults.ClipModel, "модель, векторы которой проверяются")
+	metricName := flag.String("metric", "", "метрика euclidean, cosine или dot, пусто — все")
+	pairsFile := flag.String("pairs", "", "CSV с разметкой image_a,image_b,same вместо разметки по товарам")
+	negatives := flag.Int("negatives", 20000, "наибольшее число пар разных товаров")
+	normalize := flag.Bool("normalize", defaults.Matching.Normalize, "нормализовать векторы, как при matching.normalize")
+	text := flag.Bool("text", true, "учитывать надписи на фото, как поиск бота")
+	minPrecision := flag.Float64("min-precision", 0.95, "точность, ниже которой порог не рекомендуется")
+	flag.Parse()
+
+	metrics := []recognize.Metric{recognize.MetricEuclidean, recognize.MetricCosine, recognize.MetricDot}
+	if *metricName != "" {
+		metric, err := recognize.ParseMetric(*metricName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		metrics = []recognize.Metric{metric}
+	}
+
+	db, err := postgres.New(*database)
+	if err != nil {
+		log.Fatal("can't connect to storage: ", err)
+	}
+	// Векторы, сохранённые с нормализацией, помечены своей моделью. Если их ещё нет,
+	// берутся исходные векторы и нормализуются здесь
+	shops, err := db.GetVectorsByModel(context.Background(), recognize.ModelTag(*model, *normalize))
+	if err == nil && *normalize && len(shops) == 0 {
+		shops, err = db.GetVectorsByModel(context.Background(), *model)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *shop != "" {
+		shops = map[string][]*storage.ImageMeta{*shop: shops[*shop]}
+	}
+	if *normalize {
+		for _, images := range shops {
+			for _, image := range images {
+				image.Float = recognize.Normalize(image.Float)
+			}
+		}
+	}
+
+	var pairs []pair
+	if *pairsFile != "" {
+		pairs, err = readPairs(*pairsFile, shops)
+	} else {
+		pairs = productPairs(shops, *negatives)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+	same := 0
+	for _, p := range pairs {
+		if p.same {
+			same++
+		}
+	}
+	if same == 0 || same == len(pairs) {
+		log.Fatalf("нужны пары обоих видов: одинаковых %d, разных %d", same, len(pairs)-same)
+	}
+	fmt.Printf("модель %s, пар: %d одинаковых, %d разных\n", *model, same, len(pairs)-same)
+
+	for _, metric := range metrics {
+		if metric == recognize.MetricDot && !*normalize {
+			fmt.Printf("\n%s: пропущена, метрика верна только с -normalize\n", metric)
+			continue
+		}
+		report(metric, score(metric, pairs, *text), *minPrecision)
+	}
+}
+
+// botDefaults настройки бота из configs/main.yml. Без конфига — значения по умолчанию
+// и DATABASE_URL из окружения
+func botDefaults() config.Config {
+	cfg, err := config.Init()
+	if err != nil {
+		log.Printf("конфиг не прочитан, используются значения по умолчанию: %v", err)
+		cfg = &config.Config{DatabaseURL: os.Getenv("DATABASE_URL")}
+	}
+	if cfg.ClipModel == "" {
+		cfg.ClipModel = recognize.DefaultModel
+	}
+	return *cfg
+}
+
+// productPairs размечает пары по товарам внутри каждого магазина. Разных пар берётся
+// не больше limit, случайно, но воспроизводимо: выборка резервуаром держит в памяти
+// только limit пар, сколько бы фото ни было в магазине
+func productPairs(shops map[string][]*storage.ImageMeta, limit int) []pair {
+	names := make([]string, 0, len(shops))
+	for name := range shops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	rnd := rand.New(rand.NewSource(1))
+	var positives, negatives []pair
+	seen := 0
+	for _, name := range names {
+		images := shops[name]
+		for i := range images {
+			for j := i + 1; j < len(images); j++ {
+				p := pair{a: images[i], b: images[j], same: images[i].ProductID == images[j].ProductID}
+				if p.same {
+					positives = append(positives, p)
+					continue
+				}
+				seen++
+				if len(negatives) < limit {
+					negatives = append(negatives, p)
+				} else if k := rnd.Intn(seen); k < limit {
+					negatives[k] = p
+				}
+			}
+		}
+	}
+	return append(positives, negatives...)
+}
+
+// readPairs читает разметку из CSV, строка заголовка пропускается
+func readPairs(path string, shops map[string][]*storage.ImageMeta) ([]pair, error) {
+	byID := make(map[uint]*storage.ImageMeta)
+	for _, images := range shops {
+		for _, image := range images {
+			byID[image.ImageID] = image
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 3
+	var pairs []pair
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			return pairs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		a, errA := strconv.ParseUint(strings.TrimSpace(record[0]), 10, 64)
+		b, errB := strconv.ParseUint(strings.TrimSpace(record[1]), 10, 64)
+		same, errSame := strconv.ParseBool(strings.TrimSpace(record[2]))
+		if errA != nil || errB != nil || errSame != nil {
+			if line == 1 {
+				continue // заголовок
+			}
+			return nil, fmt.Errorf("строка %d: нужны ID двух фото и true/false", line)
+		}
+		imageA, okA := byID[uint(a)]
+		imageB, okB := byID[uint(b)]
+		if !okA || !okB {
+			log.Printf("строка %d пропущена: у фото нет вектора модели", line)
+			continue
+		}
+		pairs = append(pairs, pair{a: imageA, b: imageB, same: same})
+	}
+}
+
+// score считает расстояния пар так же, как поиск бота, и сортирует их по возрастанию
+func score(metric recognize.Metric, pairs []pair, text bool) []scored {
+	result := make([]scored, 0, len(pairs))
+	for _, p := range pairs {
+		distance, err := metric.Distance(p.a.Float, p.b.Float)
+		if err != nil {
+			log.Printf("пара %d–%d пропущена: %v", p.a.ImageID, p.b.ImageID, err)
+			continue
+		}
+		if text {
+			distance = metric.HybridDistance(distance, p.a.Text, p.b.Text)
+		}
+		result = append(result, scored{distance: distance, same: p.same})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].distance < result[j].distance })
+	return result
+}
+
+// curve точность и полнота на каждом пороге, равном расстоянию одной из пар
+func curve(pairs []scored) []point {
+	total := 0
+	for _, p := range pairs {
+		if p.same {
+			total++
+		}
+	}
+	var points []point
+	tp, fp := 0, 0
+	for i, p := range pairs {
+		if p.same {
+			tp++
+		} else {
+			fp++
+		}
+		if i+1 < len(pairs) && pairs[i+1].distance == p.distance {
+			continue
+		}
+		precision := float64(tp) / float64(tp+fp)
+		recall := float64(tp) / float64(total)
+		f1 := 0.0
+		if precision+recall > 0 {
+			f1 = 2 * precision * recall / (precision + recall)
+		}
+		points = append(points, point{threshold: p.distance, precision: precision, recall: recall, f1: f1})
+	}
+	return points
+}
+
+// at точность и полнота на пороге threshold
+func at(points []point, threshold float64) point {
+	result := point{threshold: threshold, precision: 1}
+	for _, p := range points {
+		if p.threshold > threshold {
+			break
+		}
+		result = point{threshold: threshold, precision: p.precision, recall: p.recall, f1: p.f1}
+	}
+	return result
+}
+
+// report печатает порог с наибольшей полнотой при точности не ниже minPrecision,
+// порог с лучшим F1 и порог метрики по умолчанию
+func report(metric recognize.Metric, pairs []scored, minPrecision float64) {
+	points := curve(pairs)
+	if len(points) == 0 {
+		return
+	}
+
+	var best, recommended *point
+	for i := range points {
+		p := &points[i]
+		if best == nil || p.f1 > best.f1 {
+			best = p
+		}
+		if p.precision >= minPrecision && (recommended == nil || p.recall > recommended.recall) {
+			recommended = p
+		}
+	}
+
+	fmt.Printf("\n%s\n", metric)
+	fmt.Printf("  %-34s %9s %9s %9s %6s\n", "", "порог", "точность", "полнота", "F1")
+	if recommended != nil {
+		printPoint(fmt.Sprintf("рекомендуемый (точность ≥ %.2f)", minPrecision), *recommended)
+	} else {
+		fmt.Printf("  точность %.2f недостижима, рекомендуется порог с лучшим F1\n", minPrecision)
+	}
+	printPoint("лучший F1", *best)
+	printPoint("по умолчанию", at(points, metric.DefaultThreshold()))
+}
+
+func printPoint(name string, p point) {
+	fmt.Printf("  %-34s %9.4f %9.3f %9.3f %6.3f\n", name, p.threshold, p.precision, p.recall, p.f1)
+}
@@ -0,0 +1,79 @@
+// Команда e2e прогоняет сценарии telegramtest против настоящего бота,
+// поддельных Telegram и CLIP и базы из DATABASE_URL.
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"runtime"
+
+	"github.com/Bariban/vector-shop-bot/pkg/config"
+	"github.com/Bariban/vector-shop-bot/pkg/storage/postgres"
+	"github.com/Bariban/vector-shop-bot/pkg/telegram/telegramtest"
+)
+
+// runner реализует telegramtest.T: Fatalf завершает горутину сценария, как в testing
+type runner struct {
+	name   string
+	failed bool
+}
+
+func (r *runner) Helper() {}
+
+func (r *runner) Logf(format string, args ...interface{}) {
+	log.Printf("[%s] %s", r.name, fmt.Sprintf(format, args...))
+}
+
+func (r *runner) Fatalf(format string, args ...interface{}) {
+	r.Logf(format, args...)
+	r.failed = true
+	runtime.Goexit()
+}
+
+func main() {
+	storage, err := postgres.New(os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatal("can't connect to storage: ", err)
+	}
+	if err := storage.Init(context.Background()); err != nil {
+		log.Fatal("can't init storage: ", err)
+	}
+	if _, _, err := storage.MigrateVectors(context.Background()); err != nil {
+		log.Fatal("can't migrate vectors: ", err)
+	}
+
+	messages := config.Messages{
+		Responses: config.Responses{
+			Start:          "Привет!",
+			UnknownCommand: "Я не знаю такой команды :(",
+			SendPhoto:      "Отправьте фото товара 📷",
+		},
+		Errors: config.Errors{Default: "Произошла неизвестная ошибка."},
+	}
+
+	failed := 0
+	for _, sc := range telegramtest.Scenarios() {
+		r := &runner{name: sc.Name}
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			h := telegramtest.NewHarness(r, storage, messages)
+			defer h.Close()
+			sc.Run(r, h)
+		}()
+		<-done
+
+		if r.failed {
+			failed++
+			log.Printf("FAIL %s", sc.Name)
+		} else {
+			log.Printf("ok   %s", sc.Name)
+		}
+	}
+
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
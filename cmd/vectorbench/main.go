@@ -0,0 +1,201 @@
+// Команда vectorbench сравнивает форматы хранения векторов фото: текст через запятую
+// и двоичный float32 (recognize.EncodeVector). Меряет размер, разбор векторов и задержку
+// поиска перебором и по индексу HNSW. С флагом -database дополнительно меряет поиск
+// по векторам магазина -shop из базы.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"sort"
+	"time"
+
+	"github.com/Bariban/vector-shop-bot/pkg/ann"
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+	"github.com/Bariban/vector-shop-bot/pkg/storage/postgres"
+)
+
+func main() {
+	n := flag.Int("n", 5000, "число фото в магазине")
+	dim := flag.Int("dim", 512, "размерность векторов")
+	queries := flag.Int("queries", 50, "число поисковых запросов")
+	model := flag.String("model", recognize.DefaultModel, "модель, которой помечаются векторы")
+	database := flag.String("database", "", "строка подключения к базе для замера на настоящих векторах")
+	shop := flag.String("shop", "", "магазин, векторы которого берутся из базы")
+	flag.Parse()
+	if *n < 1 || *dim < 1 || *queries < 1 {
+		log.Fatal("n, dim и queries должны быть положительными")
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	vectors := make([][]float64, *n)
+	for i := range vectors {
+		vectors[i] = randomVector(rnd, *dim)
+	}
+	queryVectors := make([][]float64, *queries)
+	for i := range queryVectors {
+		queryVectors[i] = randomVector(rnd, *dim)
+	}
+
+	texts := make([]string, *n)
+	blobs := make([][]byte, *n)
+	textSize, binarySize := 0, 0
+	for i, v := range vectors {
+		texts[i] = postgres.Float64SliceToString(v)
+		data, err := recognize.EncodeVector(v, *model)
+		if err != nil {
+			log.Fatal(err)
+		}
+		blobs[i] = data
+		textSize += len(texts[i])
+		binarySize += len(data)
+	}
+	fmt.Printf("фото: %d, размерность: %d, запросов: %d\n\n", *n, *dim, *queries)
+	fmt.Printf("%-28s %12s %12s\n", "", "текст", "float32")
+	fmt.Printf("%-28s %12d %12d\n", "байт на вектор", textSize / *n, binarySize / *n)
+
+	textDecode := measure(func() {
+		for _, s := range texts {
+			if _, err := postgres.StringToFloat64Slice(s); err != nil {
+				log.Fatal(err)
+			}
+		}
+	})
+	binaryDecode := measure(func() {
+		for _, b := range blobs {
+			if _, _, err := recognize.DecodeVector(b); err != nil {
+				log.Fatal(err)
+			}
+		}
+	})
+	fmt.Printf("%-28s %12s %12s\n", "разбор всех векторов", textDecode.Round(time.Microsecond), binaryDecode.Round(time.Microsecond))
+
+	// Поиск до индекса: каждый запрос разбирает все векторы магазина и сравнивает с образцом
+	textSearch := measure(func() {
+		for _, q := range queryVectors {
+			for _, s := range texts {
+				v, _ := postgres.StringToFloat64Slice(s)
+				_, _ = recognize.Distance(q, v)
+			}
+		}
+	}) / time.Duration(*queries)
+	binarySearch := measure(func() {
+		for _, q := range queryVectors {
+			for _, b := range blobs {
+				v, _, _ := recognize.DecodeVector(b)
+				_, _ = recognize.Distance(q, v)
+			}
+		}
+	}) / time.Duration(*queries)
+	fmt.Printf("%-28s %12s %12s\n", "поиск перебором, на запрос", textSearch.Round(time.Microsecond), binarySearch.Round(time.Microsecond))
+
+	index := ann.New(recognize.MetricEuclidean)
+	build := measure(func() {
+		for i, v := range vectors {
+			if err := index.Add(ann.Item{ID: uint(i + 1), ProductID: uint(i + 1), Vector: v}); err != nil {
+				log.Fatal(err)
+			}
+		}
+	})
+	var annSearch time.Duration
+	recall := 0.0
+	for _, q := range queryVectors {
+		start := time.Now()
+		results, err := index.Search(q, 10)
+		if err != nil {
+			log.Fatal(err)
+		}
+		annSearch += time.Since(start)
+		recall += overlap(results, exactNearest(q, vectors, 10))
+	}
+	fmt.Printf("\nиндекс HNSW: построение %s, поиск %s на запрос, полнота top-10 %.2f\n",
+		build.Round(time.Millisecond), (annSearch / time.Duration(*queries)).Round(time.Microsecond), recall/float64(*queries))
+
+	if *database != "" {
+		benchDatabase(*database, *shop, *model, *queries)
+	}
+}
+
+// benchDatabase меряет поиск перебором по векторам магазина из базы: запрос и сравнение
+func benchDatabase(database, shop, model string, queries int) {
+	storage, err := postgres.New(database)
+	if err != nil {
+		log.Fatal("can't connect to storage: ", err)
+	}
+	ctx := context.Background()
+	shops, err := storage.GetVectorsByModel(ctx, model)
+	if err != nil {
+		log.Fatal(err)
+	}
+	images := shops[shop]
+	if len(images) == 0 {
+		fmt.Printf("\nв базе нет векторов модели %s магазина %q\n", model, shop)
+		return
+	}
+
+	dim := len(images[0].Float)
+	var total time.Duration
+	for i := 0; i < queries; i++ {
+		sample := images[i%len(images)].Float
+		start := time.Now()
+		found, err := storage.GetVectorsByUsername(ctx, shop, model, dim)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, image := range found {
+			_, _ = recognize.Distance(sample, image.Float)
+		}
+		total += time.Since(start)
+	}
+	fmt.Printf("\nбаза, магазин %s: %d фото, поиск перебором %s на запрос\n",
+		shop, len(images), (total / time.Duration(queries)).Round(time.Microsecond))
+}
+
+func randomVector(rnd *rand.Rand, dim int) []float64 {
+	v := make([]float64, dim)
+	for i := range v {
+		v[i] = rnd.NormFloat64()
+	}
+	return v
+}
+
+func measure(f func()) time.Duration {
+	start := time.Now()
+	f()
+	return time.Since(start)
+}
+
+// exactNearest номера k ближайших векторов, найденных перебором
+func exactNearest(q []float64, vectors [][]float64, k int) map[uint]bool {
+	type scored struct {
+		id   uint
+		dist float64
+	}
+	all := make([]scored, len(vectors))
+	for i, v := range vectors {
+		d, _ := recognize.Distance(q, v)
+		all[i] = scored{id: uint(i + 1), dist: d}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].dist < all[j].dist })
+	if k > len(all) {
+		k = len(all)
+	}
+	nearest := make(map[uint]bool, k)
+	for _, s := range all[:k] {
+		nearest[s.id] = true
+	}
+	return nearest
+}
+
+func overlap(results []ann.Result, want map[uint]bool) float64 {
+	hit := 0
+	for _, r := range results {
+		if want[r.ID] {
+			hit++
+		}
+	}
+	return float64(hit) / float64(len(want))
+}
@@ -0,0 +1,41 @@
+package ann
+
+import "sort"
+
+// candidate узел графа и его расстояние до запроса
+type candidate struct {
+	id   int32
+	dist float64
+}
+
+// nearHeap очередь кандидатов, ближайший первым
+type nearHeap []candidate
+
+func (h nearHeap) Len() int            { return len(h) }
+func (h nearHeap) Less(i, j int) bool  { return h[i].dist < h[j].dist }
+func (h nearHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *nearHeap) Push(v interface{}) { *h = append(*h, v.(candidate)) }
+func (h *nearHeap) Pop() interface{} {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+// farHeap лучшие найденные узлы, самый дальний первым
+type farHeap []candidate
+
+func (h farHeap) Len() int            { return len(h) }
+func (h farHeap) Less(i, j int) bool  { return h[i].dist > h[j].dist }
+func (h farHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *farHeap) Push(v interface{}) { *h = append(*h, v.(candidate)) }
+func (h *farHeap) Pop() interface{} {
+	old := *h
+	v := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return v
+}
+
+func sortCandidates(c []candidate) {
+	sort.Slice(c, func(i, j int) bool { return c[i].dist < c[j].dist })
+}
@@ -0,0 +1,439 @@
+// Package ann приближённый поиск ближайших векторов фото в памяти процесса (HNSW).
+// Индекс заменяет перебор всех векторов магазина при поиске товара по фото, когда
+// в базе нет pgvector. Снимки индексов сохраняются на диск, чтобы не строить граф
+// заново при каждом запуске.
+package ann
+
+import (
+	"container/heap"
+	"fmt"
+	"math"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+)
+
+// Параметры графа по умолчанию
+const (
+	DefaultM              = 16  // соседей узла на верхних уровнях, на нижнем вдвое больше
+	DefaultEfConstruction = 200 // ширина поиска соседей при вставке
+	DefaultEfSearch       = 64  // ширина поиска при запросе
+)
+
+// Item фото в индексе: вектор и то, что нужно поиску без обращения к базе
+type Item struct {
+	ID        uint // ID фото
+	ProductID uint
+	Text      string
+	Vector    []float64
+}
+
+// Result найденное фото и расстояние до запроса по метрике индекса
+type Result struct {
+	Item
+	Distance float64
+}
+
+type node struct {
+	item    Item
+	level   int
+	links   [][]int32 // соседи по уровням графа
+	deleted bool
+}
+
+// Index граф HNSW. Удалённые фото остаются в графе как промежуточные узлы
+// и не попадают в результаты, пока их не станет больше половины: тогда граф
+// перестраивается. Методы безопасны для одновременного вызова
+type Index struct {
+	mu             sync.RWMutex
+	m              int
+	efConstruction int
+	efSearch       int
+	levelMult      float64
+	rnd            *rand.Rand
+	metric         recognize.Metric
+	distance       func(a, b []float64) float64
+
+	dim      int
+	nodes    []*node
+	byID     map[uint]int32
+	entry    int32 // -1 — граф пуст
+	maxLevel int
+	deleted  int
+	dirty    bool // изменения после последнего снимка
+}
+
+// New создаёт пустой индекс с метрикой metric и параметрами по умолчанию
+func New(metric recognize.Metric) *Index {
+	return newIndex(DefaultM, DefaultEfConstruction, DefaultEfSearch, metric)
+}
+
+func newIndex(m, efConstruction, efSearch int, metric recognize.Metric) *Index {
+	return &Index{
+		m:              m,
+		efConstruction: efConstruction,
+		efSearch:       efSearch,
+		levelMult:      1 / math.Log(float64(m)),
+		rnd:            rand.New(rand.NewSource(time.Now().UnixNano())),
+		metric:         metric,
+		distance:       metric.Func(),
+		byID:           make(map[uint]int32),
+		entry:          -1,
+	}
+}
+
+// Dim размерность векторов индекса, 0 — индекс пуст
+func (x *Index) Dim() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.dim
+}
+
+// Len число фото в индексе
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return len(x.byID)
+}
+
+// Get возвращает фото по ID
+func (x *Index) Get(id uint) (Item, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	i, ok := x.byID[id]
+	if !ok {
+		return Item{}, false
+	}
+	return x.nodes[i].item, true
+}
+
+// Add добавляет фото или заменяет уже добавленное с тем же ID. Размерность
+// задаёт первое фото пустого индекса, векторы другой размерности не принимаются
+func (x *Index) Add(item Item) error {
+	if len(item.Vector) == 0 {
+		return fmt.Errorf("фото %d без вектора", item.ID)
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.byID) == 0 {
+		x.reset()
+		x.dim = len(item.Vector)
+	}
+	if len(item.Vector) != x.dim {
+		return fmt.Errorf("размерность вектора фото %d: %d, у индекса %d", item.ID, len(item.Vector), x.dim)
+	}
+	replaced := x.remove(item.ID)
+	x.insert(item)
+	if replaced {
+		x.compact()
+	}
+	x.dirty = true
+	return nil
+}
+
+// Remove удаляет фото и сообщает, было ли оно в индексе
+func (x *Index) Remove(id uint) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if !x.remove(id) {
+		return false
+	}
+	x.compact()
+	x.dirty = true
+	return true
+}
+
+// RemoveProduct удаляет все фото товара и возвращает их число
+func (x *Index) RemoveProduct(productID uint) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	removed := 0
+	for id, i := range x.byID {
+		if x.nodes[i].item.ProductID == productID && x.remove(id) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		x.compact()
+		x.dirty = true
+	}
+	return removed
+}
+
+// MoveProduct переносит фото товара from к товару to и возвращает их число
+func (x *Index) MoveProduct(from, to uint) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	moved := 0
+	for _, i := range x.byID {
+		if x.nodes[i].item.ProductID == from {
+			x.nodes[i].item.ProductID = to
+			moved++
+		}
+	}
+	if moved > 0 {
+		x.dirty = true
+	}
+	return moved
+}
+
+// Items возвращает все фото индекса
+func (x *Index) Items() []Item {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	items := make([]Item, 0, len(x.byID))
+	for _, i := range x.byID {
+		items = append(items, x.nodes[i].item)
+	}
+	return items
+}
+
+// Sync приводит индекс к списку фото из базы: лишние удаляет, недостающие
+// и изменившиеся добавляет
+func (x *Index) Sync(items []Item) (added, removed int, err error) {
+	keep := make(map[uint]bool, len(items))
+	for _, item := range items {
+		keep[item.ID] = true
+	}
+
+	x.mu.Lock()
+	for id := range x.byID {
+		if !keep[id] && x.remove(id) {
+			removed++
+		}
+	}
+	if removed > 0 {
+		x.compact()
+		x.dirty = true
+	}
+	x.mu.Unlock()
+
+	for _, item := range items {
+		if prev, ok := x.Get(item.ID); ok && prev.ProductID == item.ProductID && prev.Text == item.Text &&
+			equalVectors(prev.Vector, item.Vector) {
+			continue
+		}
+		if err := x.Add(item); err != nil {
+			return added, removed, err
+		}
+		added++
+	}
+	return added, removed, nil
+}
+
+// Search возвращает до k фото, ближайших к query, по возрастанию расстояния
+func (x *Index) Search(query []float64, k int) ([]Result, error) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	if x.entry < 0 || k <= 0 {
+		return nil, nil
+	}
+	if len(query) != x.dim {
+		return nil, fmt.Errorf("размерность запроса %d, у индекса %d", len(query), x.dim)
+	}
+
+	ep := x.entry
+	for l := x.maxLevel; l > 0; l-- {
+		ep = x.greedy(query, ep, l)
+	}
+	ef := x.efSearch
+	if ef < k {
+		ef = k
+	}
+	found := x.searchLayer(query, ep, ef, 0)
+
+	results := make([]Result, 0, k)
+	for _, c := range found {
+		n := x.nodes[c.id]
+		if n.deleted {
+			continue
+		}
+		results = append(results, Result{Item: n.item, Distance: c.dist})
+		if len(results) == k {
+			break
+		}
+	}
+	return results, nil
+}
+
+func (x *Index) reset() {
+	x.dim = 0
+	x.nodes = nil
+	x.byID = make(map[uint]int32)
+	x.entry = -1
+	x.maxLevel = 0
+	x.deleted = 0
+}
+
+func (x *Index) randomLevel() int {
+	return int(-math.Log(1-x.rnd.Float64()) * x.levelMult)
+}
+
+func (x *Index) maxLinks(level int) int {
+	if level == 0 {
+		return 2 * x.m
+	}
+	return x.m
+}
+
+func (x *Index) insert(item Item) {
+	level := x.randomLevel()
+	id := int32(len(x.nodes))
+	n := &node{item: item, level: level, links: make([][]int32, level+1)}
+	x.nodes = append(x.nodes, n)
+	x.byID[item.ID] = id
+
+	if x.entry < 0 {
+		x.entry, x.maxLevel = id, level
+		return
+	}
+
+	ep := x.entry
+	for l := x.maxLevel; l > level; l-- {
+		ep = x.greedy(item.Vector, ep, l)
+	}
+	for l := min(level, x.maxLevel); l >= 0; l-- {
+		found := x.searchLayer(item.Vector, ep, x.efConstruction, l)
+		n.links[l] = x.closest(found, x.m)
+		for _, nb := range n.links[l] {
+			x.link(nb, id, l)
+		}
+		ep = found[0].id
+	}
+	if level > x.maxLevel {
+		x.entry, x.maxLevel = id, level
+	}
+}
+
+// closest выбирает до m ближайших неудалённых узлов
+func (x *Index) closest(found []candidate, m int) []int32 {
+	links := make([]int32, 0, m)
+	for _, c := range found {
+		if x.nodes[c.id].deleted {
+			continue
+		}
+		links = append(links, c.id)
+		if len(links) == m {
+			break
+		}
+	}
+	return links
+}
+
+// link добавляет связь from → to, лишние связи отбрасываются по расстоянию
+func (x *Index) link(from, to int32, level int) {
+	n := x.nodes[from]
+	n.links[level] = append(n.links[level], to)
+	if len(n.links[level]) <= x.maxLinks(level) {
+		return
+	}
+	found := make([]candidate, 0, len(n.links[level]))
+	for _, nb := range n.links[level] {
+		found = append(found, candidate{id: nb, dist: x.distance(n.item.Vector, x.nodes[nb].item.Vector)})
+	}
+	sortCandidates(found)
+	n.links[level] = x.closest(found, x.maxLinks(level))
+}
+
+func (x *Index) remove(id uint) bool {
+	i, ok := x.byID[id]
+	if !ok {
+		return false
+	}
+	x.nodes[i].deleted = true
+	delete(x.byID, id)
+	x.deleted++
+	return true
+}
+
+// compact перестраивает граф, когда удалённых узлов больше, чем живых
+func (x *Index) compact() {
+	if len(x.byID) == 0 {
+		x.reset()
+		return
+	}
+	if x.deleted <= len(x.byID) {
+		return
+	}
+	live := make([]Item, 0, len(x.byID))
+	for _, n := range x.nodes {
+		if !n.deleted {
+			live = append(live, n.item)
+		}
+	}
+	dim := x.dim
+	x.reset()
+	x.dim = dim
+	for _, item := range live {
+		x.insert(item)
+	}
+}
+
+// greedy спускается к ближайшему к query узлу уровня level
+func (x *Index) greedy(query []float64, ep int32, level int) int32 {
+	best := x.distance(query, x.nodes[ep].item.Vector)
+	for changed := true; changed; {
+		changed = false
+		for _, nb := range x.nodes[ep].links[level] {
+			if d := x.distance(query, x.nodes[nb].item.Vector); d < best {
+				best, ep, changed = d, nb, true
+			}
+		}
+	}
+	return ep
+}
+
+// searchLayer ищет ef ближайших к query узлов уровня level, результат по возрастанию расстояния
+func (x *Index) searchLayer(query []float64, ep int32, ef int, level int) []candidate {
+	visited := map[int32]bool{ep: true}
+	start := candidate{id: ep, dist: x.distance(query, x.nodes[ep].item.Vector)}
+	queue := &nearHeap{start}
+	found := &farHeap{start}
+
+	for queue.Len() > 0 {
+		c := heap.Pop(queue).(candidate)
+		if found.Len() >= ef && c.dist > (*found)[0].dist {
+			break
+		}
+		for _, nb := range x.nodes[c.id].links[level] {
+			if visited[nb] {
+				continue
+			}
+			visited[nb] = true
+			d := x.distance(query, x.nodes[nb].item.Vector)
+			if found.Len() < ef || d < (*found)[0].dist {
+				heap.Push(queue, candidate{id: nb, dist: d})
+				heap.Push(found, candidate{id: nb, dist: d})
+				if found.Len() > ef {
+					heap.Pop(found)
+				}
+			}
+		}
+	}
+
+	result := []candidate(*found)
+	sortCandidates(result)
+	return result
+}
+
+// equalVectors сравнивает векторы с точностью float32, в которой они хранятся в базе
+func equalVectors(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if float32(a[i]) != float32(b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
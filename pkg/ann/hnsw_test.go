@@ -0,0 +1,82 @@
+package ann
+
+import (
+	"math/rand"
+	"strconv"
+	"testing"
+
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+)
+
+// benchDim размерность вектора CLIP ViT-B/32
+const benchDim = 512
+
+func randomItems(rnd *rand.Rand, n int) []Item {
+	return randomItemsDim(rnd, n, benchDim)
+}
+
+func randomItemsDim(rnd *rand.Rand, n, dim int) []Item {
+	items := make([]Item, n)
+	for i := range items {
+		vector := make([]float64, dim)
+		for j := range vector {
+			vector[j] = rnd.NormFloat64()
+		}
+		items[i] = Item{ID: uint(i + 1), ProductID: uint(i/3 + 1), Vector: vector}
+	}
+	return items
+}
+
+func benchIndex(b *testing.B, n int) (*Index, []Item) {
+	b.Helper()
+	items := randomItems(rand.New(rand.NewSource(1)), n)
+	x := New(recognize.DefaultMetric)
+	for _, item := range items {
+		if err := x.Add(item); err != nil {
+			b.Fatal(err)
+		}
+	}
+	return x, items
+}
+
+func BenchmarkAdd(b *testing.B) {
+	items := randomItems(rand.New(rand.NewSource(1)), b.N)
+	x := New(recognize.DefaultMetric)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := x.Add(items[i]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSearch(b *testing.B) {
+	for _, n := range []int{1000, 5000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			x, items := benchIndex(b, n)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := x.Search(items[i%len(items)].Vector, 32); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkBruteForce перебор всех векторов, который индекс заменяет
+func BenchmarkBruteForce(b *testing.B) {
+	for _, n := range []int{1000, 5000} {
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			items := randomItems(rand.New(rand.NewSource(1)), n)
+			distance := recognize.DefaultMetric.Func()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				query := items[i%len(items)].Vector
+				for _, item := range items {
+					distance(query, item.Vector)
+				}
+			}
+		})
+	}
+}
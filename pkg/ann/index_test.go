@@ -0,0 +1,242 @@
+package ann
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+)
+
+// testDim размерность векторов в тестах: поменьше, чтобы граф строился быстро
+const testDim = 32
+
+// testIndex индекс с воспроизводимыми уровнями узлов
+func testIndex(t *testing.T, metric recognize.Metric, items []Item) *Index {
+	t.Helper()
+	x := New(metric)
+	x.rnd = rand.New(rand.NewSource(1))
+	for _, item := range items {
+		if err := x.Add(item); err != nil {
+			t.Fatalf("Add(%d): %v", item.ID, err)
+		}
+	}
+	return x
+}
+
+// bruteForce k ближайших фото перебором
+func bruteForce(metric recognize.Metric, items []Item, query []float64, k int) []uint {
+	distance := metric.Func()
+	sorted := append([]Item(nil), items...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return distance(query, sorted[i].Vector) < distance(query, sorted[j].Vector)
+	})
+	ids := make([]uint, 0, k)
+	for _, item := range sorted[:k] {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func resultIDs(results []Result) map[uint]bool {
+	ids := make(map[uint]bool, len(results))
+	for _, r := range results {
+		ids[r.ID] = true
+	}
+	return ids
+}
+
+func TestSearchRecall(t *testing.T) {
+	const (
+		n       = 2000
+		k       = 10
+		queries = 100
+	)
+	for _, metric := range []recognize.Metric{recognize.MetricEuclidean, recognize.MetricCosine} {
+		t.Run(string(metric), func(t *testing.T) {
+			rnd := rand.New(rand.NewSource(2))
+			items := randomItemsDim(rnd, n, testDim)
+			x := testIndex(t, metric, items)
+
+			found, total := 0, 0
+			for _, query := range randomItemsDim(rnd, queries, testDim) {
+				results, err := x.Search(query.Vector, k)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(results) != k {
+					t.Fatalf("Search returned %d results, want %d", len(results), k)
+				}
+				for i := 1; i < len(results); i++ {
+					if results[i].Distance < results[i-1].Distance {
+						t.Fatalf("results are not sorted by distance: %v", results)
+					}
+				}
+				got := resultIDs(results)
+				for _, id := range bruteForce(metric, items, query.Vector, k) {
+					if got[id] {
+						found++
+					}
+					total++
+				}
+			}
+			if recall := float64(found) / float64(total); recall < 0.95 {
+				t.Errorf("recall@%d = %.3f, want at least 0.95", k, recall)
+			}
+		})
+	}
+}
+
+func TestRemoveCompacts(t *testing.T) {
+	items := randomItemsDim(rand.New(rand.NewSource(3)), 200, testDim)
+	x := testIndex(t, recognize.DefaultMetric, items)
+
+	// Удаляем три четверти: граф перестраивается, когда удалённых больше живых
+	removed := make(map[uint]bool)
+	for _, item := range items[:150] {
+		if !x.Remove(item.ID) {
+			t.Fatalf("Remove(%d) = false", item.ID)
+		}
+		removed[item.ID] = true
+	}
+	if x.Remove(items[0].ID) {
+		t.Error("second Remove of the same photo = true")
+	}
+	if x.Len() != 50 {
+		t.Errorf("Len() = %d, want 50", x.Len())
+	}
+	if x.deleted > x.Len() {
+		t.Errorf("graph was not compacted: %d deleted nodes, %d live", x.deleted, x.Len())
+	}
+
+	for _, item := range items {
+		results, err := x.Search(item.Vector, 20)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, r := range results {
+			if removed[r.ID] {
+				t.Fatalf("Search returned removed photo %d", r.ID)
+			}
+		}
+		if _, ok := x.Get(item.ID); ok == removed[item.ID] {
+			t.Errorf("Get(%d) = %v, removed %v", item.ID, ok, removed[item.ID])
+		}
+	}
+
+	// После удаления всех фото индекс принимает векторы другой размерности
+	for _, item := range items[150:] {
+		x.Remove(item.ID)
+	}
+	if x.Len() != 0 || x.Dim() != 0 {
+		t.Errorf("empty index: Len() = %d, Dim() = %d", x.Len(), x.Dim())
+	}
+	if err := x.Add(Item{ID: 1, Vector: []float64{1, 2}}); err != nil {
+		t.Errorf("Add to emptied index: %v", err)
+	}
+}
+
+func TestAddReplaces(t *testing.T) {
+	items := randomItemsDim(rand.New(rand.NewSource(4)), 50, testDim)
+	x := testIndex(t, recognize.DefaultMetric, items)
+
+	replacement := Item{ID: items[0].ID, ProductID: 99, Text: "новый", Vector: items[1].Vector}
+	if err := x.Add(replacement); err != nil {
+		t.Fatal(err)
+	}
+	if x.Len() != len(items) {
+		t.Errorf("Len() = %d after replace, want %d", x.Len(), len(items))
+	}
+	got, ok := x.Get(items[0].ID)
+	if !ok || got.ProductID != 99 || got.Text != "новый" || !equalVectors(got.Vector, items[1].Vector) {
+		t.Errorf("Get() = %+v, want the replacement", got)
+	}
+
+	// Старый вектор больше не находит фото вплотную
+	results, err := x.Search(items[0].Vector, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(results) == 1 && results[0].ID == items[0].ID && results[0].Distance == 0 {
+		t.Error("Search found the replaced vector")
+	}
+}
+
+func TestAddRejectsOtherDimension(t *testing.T) {
+	x := testIndex(t, recognize.DefaultMetric, []Item{{ID: 1, Vector: []float64{1, 0, 0}}})
+	if err := x.Add(Item{ID: 2, Vector: []float64{1, 0}}); err == nil {
+		t.Error("Add accepted a vector of another dimension")
+	}
+	if err := x.Add(Item{ID: 3}); err == nil {
+		t.Error("Add accepted a photo without a vector")
+	}
+	if _, err := x.Search([]float64{1, 0}, 1); err == nil {
+		t.Error("Search accepted a query of another dimension")
+	}
+	if x.Len() != 1 || x.Dim() != 3 {
+		t.Errorf("Len() = %d, Dim() = %d, want 1, 3", x.Len(), x.Dim())
+	}
+}
+
+func TestProducts(t *testing.T) {
+	items := []Item{
+		{ID: 1, ProductID: 10, Vector: []float64{0, 0}},
+		{ID: 2, ProductID: 10, Vector: []float64{1, 0}},
+		{ID: 3, ProductID: 20, Vector: []float64{0, 1}},
+		{ID: 4, ProductID: 30, Vector: []float64{1, 1}},
+	}
+	x := testIndex(t, recognize.DefaultMetric, items)
+
+	if moved := x.MoveProduct(10, 20); moved != 2 {
+		t.Errorf("MoveProduct = %d, want 2", moved)
+	}
+	for _, id := range []uint{1, 2, 3} {
+		if item, _ := x.Get(id); item.ProductID != 20 {
+			t.Errorf("photo %d belongs to product %d, want 20", id, item.ProductID)
+		}
+	}
+
+	if removed := x.RemoveProduct(20); removed != 3 {
+		t.Errorf("RemoveProduct = %d, want 3", removed)
+	}
+	if x.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", x.Len())
+	}
+	if results, _ := x.Search([]float64{0, 0}, 4); len(results) != 1 || results[0].ID != 4 {
+		t.Errorf("Search = %+v, want only photo 4", results)
+	}
+	if removed := x.RemoveProduct(20); removed != 0 {
+		t.Errorf("second RemoveProduct = %d, want 0", removed)
+	}
+}
+
+func TestSync(t *testing.T) {
+	x := testIndex(t, recognize.DefaultMetric, []Item{
+		{ID: 1, ProductID: 1, Vector: []float64{0, 0}},
+		{ID: 2, ProductID: 1, Vector: []float64{1, 0}},
+		{ID: 3, ProductID: 2, Vector: []float64{0, 1}},
+	})
+
+	// В базе фото 2 нет, у фото 3 сменился товар, фото 4 новое, фото 1 не изменилось
+	added, removed, err := x.Sync([]Item{
+		{ID: 1, ProductID: 1, Vector: []float64{0, 0}},
+		{ID: 3, ProductID: 5, Vector: []float64{0, 1}},
+		{ID: 4, ProductID: 6, Vector: []float64{1, 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if added != 2 || removed != 1 {
+		t.Errorf("Sync = added %d, removed %d, want 2, 1", added, removed)
+	}
+	if _, ok := x.Get(2); ok {
+		t.Error("photo 2 is still in the index")
+	}
+	if item, _ := x.Get(3); item.ProductID != 5 {
+		t.Errorf("photo 3 belongs to product %d, want 5", item.ProductID)
+	}
+
+	if added, removed, _ := x.Sync(x.Items()); added != 0 || removed != 0 {
+		t.Errorf("repeated Sync = added %d, removed %d, want 0, 0", added, removed)
+	}
+}
@@ -0,0 +1,172 @@
+package ann
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+)
+
+// snapshotExt расширение файлов снимков в каталоге Registry
+const snapshotExt = ".hnsw"
+
+// DefaultSnapshotInterval как часто Run сохраняет изменённые индексы
+const DefaultSnapshotInterval = time.Minute
+
+// Registry индексы фото по магазинам. Все индексы хранят векторы одной модели CLIP
+// и сравнивают их одной метрикой: снимки другой модели или метрики при загрузке отбрасываются
+type Registry struct {
+	Model  string
+	Metric recognize.Metric
+	dir    string
+
+	mu    sync.Mutex
+	shops map[string]*Index
+}
+
+// NewRegistry создаёт пустой набор индексов модели model с метрикой metric и снимками в каталоге dir
+func NewRegistry(dir, model string, metric recognize.Metric) (*Registry, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("не удалось создать каталог индексов: %w", err)
+	}
+	return &Registry{Model: model, Metric: metric, dir: dir, shops: make(map[string]*Index)}, nil
+}
+
+// Shop возвращает индекс магазина, создавая пустой при первом обращении
+func (r *Registry) Shop(name string) *Index {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	x, ok := r.shops[name]
+	if !ok {
+		x = New(r.Metric)
+		r.shops[name] = x
+	}
+	return x
+}
+
+// Shops имена магазинов, у которых есть индекс
+func (r *Registry) Shops() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.shops))
+	for name := range r.shops {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Load читает снимки из каталога. Повреждённые снимки и снимки другой модели
+// пропускаются: такие индексы строятся заново по базе
+func (r *Registry) Load() (int, error) {
+	files, err := filepath.Glob(filepath.Join(r.dir, "*"+snapshotExt))
+	if err != nil {
+		return 0, err
+	}
+	loaded := 0
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return loaded, fmt.Errorf("не удалось открыть снимок индекса: %w", err)
+		}
+		x, s, err := readSnapshot(f)
+		f.Close()
+		if err != nil {
+			log.Printf("снимок %s пропущен: %v", file, err)
+			continue
+		}
+		if s.Model != r.Model {
+			log.Printf("снимок %s пропущен: модель %s, нужна %s", file, s.Model, r.Model)
+			continue
+		}
+		if s.Metric != r.Metric {
+			log.Printf("снимок %s пропущен: метрика %s, нужна %s", file, s.Metric, r.Metric)
+			continue
+		}
+		if r.snapshotPath(s.Shop) != file {
+			log.Printf("снимок %s пропущен: записан для другого магазина", file)
+			continue
+		}
+		r.mu.Lock()
+		r.shops[s.Shop] = x
+		r.mu.Unlock()
+		loaded++
+	}
+	return loaded, nil
+}
+
+// Save записывает снимки индексов, изменившихся с прошлого сохранения. Файл
+// заменяется целиком, поэтому прерванная запись не портит прошлый снимок
+func (r *Registry) Save() error {
+	r.mu.Lock()
+	shops := make(map[string]*Index, len(r.shops))
+	for name, x := range r.shops {
+		shops[name] = x
+	}
+	r.mu.Unlock()
+
+	for name, x := range shops {
+		x.mu.RLock()
+		dirty := x.dirty
+		x.mu.RUnlock()
+		if !dirty {
+			continue
+		}
+
+		var buf bytes.Buffer
+		if err := x.writeSnapshot(&buf, name, r.Model); err != nil {
+			return err
+		}
+		path := r.snapshotPath(name)
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, buf.Bytes(), 0o644); err != nil {
+			x.markDirty()
+			return fmt.Errorf("не удалось сохранить снимок индекса: %w", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			x.markDirty()
+			return fmt.Errorf("не удалось сохранить снимок индекса: %w", err)
+		}
+	}
+	return nil
+}
+
+// Run сохраняет изменённые индексы каждые interval и последний раз при отмене ctx
+func (r *Registry) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultSnapshotInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			if err := r.Save(); err != nil {
+				log.Printf("не удалось сохранить индексы фото: %v", err)
+			}
+			return
+		case <-ticker.C:
+			if err := r.Save(); err != nil {
+				log.Printf("не удалось сохранить индексы фото: %v", err)
+			}
+		}
+	}
+}
+
+// snapshotPath файл снимка магазина: имя магазина кодируется, в нём могут быть любые символы
+func (r *Registry) snapshotPath(shop string) string {
+	return filepath.Join(r.dir, fmt.Sprintf("shop-%x%s", shop, snapshotExt))
+}
+
+func (x *Index) markDirty() {
+	x.mu.Lock()
+	x.dirty = true
+	x.mu.Unlock()
+}
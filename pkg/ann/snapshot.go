@@ -0,0 +1,99 @@
+package ann
+
+import (
+	"encoding/gob"
+	"fmt"
+	"io"
+
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+)
+
+// snapshotVersion версия формата снимка: снимки другой версии не читаются, индекс строится заново
+const snapshotVersion = 2
+
+// snapshot граф индекса вместе с магазином и моделью, векторы которой в нём лежат
+type snapshot struct {
+	Version        int
+	Shop           string
+	Model          string
+	Metric         recognize.Metric
+	M              int
+	EfConstruction int
+	EfSearch       int
+	Dim            int
+	Entry          int32
+	MaxLevel       int
+	Nodes          []snapshotNode
+}
+
+type snapshotNode struct {
+	Item    Item
+	Level   int
+	Links   [][]int32
+	Deleted bool
+}
+
+// writeSnapshot записывает граф и сбрасывает признак изменений
+func (x *Index) writeSnapshot(w io.Writer, shop, model string) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	s := snapshot{
+		Version:        snapshotVersion,
+		Shop:           shop,
+		Model:          model,
+		Metric:         x.metric,
+		M:              x.m,
+		EfConstruction: x.efConstruction,
+		EfSearch:       x.efSearch,
+		Dim:            x.dim,
+		Entry:          x.entry,
+		MaxLevel:       x.maxLevel,
+		Nodes:          make([]snapshotNode, len(x.nodes)),
+	}
+	for i, n := range x.nodes {
+		s.Nodes[i] = snapshotNode{Item: n.item, Level: n.level, Links: n.links, Deleted: n.deleted}
+	}
+	if err := gob.NewEncoder(w).Encode(&s); err != nil {
+		return fmt.Errorf("не удалось записать снимок индекса: %w", err)
+	}
+	x.dirty = false
+	return nil
+}
+
+// readSnapshot читает граф, сохранённый writeSnapshot
+func readSnapshot(r io.Reader) (*Index, *snapshot, error) {
+	var s snapshot
+	if err := gob.NewDecoder(r).Decode(&s); err != nil {
+		return nil, nil, fmt.Errorf("не удалось прочитать снимок индекса: %w", err)
+	}
+	if s.Version != snapshotVersion {
+		return nil, nil, fmt.Errorf("версия снимка %d, ожидается %d", s.Version, snapshotVersion)
+	}
+	if s.M < 2 || s.Entry >= int32(len(s.Nodes)) || s.Entry >= 0 && s.Nodes[s.Entry].Level < s.MaxLevel {
+		return nil, nil, fmt.Errorf("снимок индекса повреждён")
+	}
+
+	x := newIndex(s.M, s.EfConstruction, s.EfSearch, s.Metric)
+	x.dim, x.entry, x.maxLevel = s.Dim, s.Entry, s.MaxLevel
+	x.nodes = make([]*node, len(s.Nodes))
+	for i, sn := range s.Nodes {
+		if len(sn.Links) != sn.Level+1 || len(sn.Item.Vector) != s.Dim {
+			return nil, nil, fmt.Errorf("снимок индекса повреждён: узел %d", i)
+		}
+		for level, links := range sn.Links {
+			for _, nb := range links {
+				if nb < 0 || int(nb) >= len(s.Nodes) || s.Nodes[nb].Level < level {
+					return nil, nil, fmt.Errorf("снимок индекса повреждён: связь узла %d", i)
+				}
+			}
+		}
+		x.nodes[i] = &node{item: sn.Item, level: sn.Level, links: sn.Links, deleted: sn.Deleted}
+		if sn.Deleted {
+			x.deleted++
+		} else {
+			x.byID[sn.Item.ID] = int32(i)
+		}
+	}
+	return x, &s, nil
+}
@@ -0,0 +1,144 @@
+package ann
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+)
+
+func TestSnapshotRoundTrip(t *testing.T) {
+	items := randomItemsDim(rand.New(rand.NewSource(5)), 300, testDim)
+	x := testIndex(t, recognize.MetricCosine, items)
+	for _, item := range items[:20] {
+		x.Remove(item.ID)
+	}
+
+	var buf bytes.Buffer
+	if err := x.writeSnapshot(&buf, "shop", "model"); err != nil {
+		t.Fatal(err)
+	}
+	if x.dirty {
+		t.Error("writeSnapshot did not reset dirty")
+	}
+	loaded, s, err := readSnapshot(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Shop != "shop" || s.Model != "model" || s.Metric != recognize.MetricCosine {
+		t.Errorf("snapshot header = %q, %q, %q", s.Shop, s.Model, s.Metric)
+	}
+	if loaded.Len() != x.Len() || loaded.Dim() != x.Dim() || loaded.deleted != x.deleted {
+		t.Errorf("loaded Len/Dim/deleted = %d/%d/%d, want %d/%d/%d",
+			loaded.Len(), loaded.Dim(), loaded.deleted, x.Len(), x.Dim(), x.deleted)
+	}
+
+	// Граф тот же, поэтому и результаты поиска совпадают
+	for _, item := range items[:50] {
+		want, _ := x.Search(item.Vector, 5)
+		got, _ := loaded.Search(item.Vector, 5)
+		if len(got) != len(want) {
+			t.Fatalf("loaded Search returned %d results, want %d", len(got), len(want))
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID || got[i].Distance != want[i].Distance {
+				t.Fatalf("loaded Search = %+v, want %+v", got, want)
+			}
+		}
+	}
+}
+
+func TestReadSnapshotRejectsCorrupted(t *testing.T) {
+	x := testIndex(t, recognize.DefaultMetric, randomItemsDim(rand.New(rand.NewSource(6)), 10, testDim))
+	var buf bytes.Buffer
+	if err := x.writeSnapshot(&buf, "shop", "model"); err != nil {
+		t.Fatal(err)
+	}
+	data := buf.Bytes()
+	if _, _, err := readSnapshot(bytes.NewReader(data[:len(data)/2])); err == nil {
+		t.Error("readSnapshot accepted a truncated snapshot")
+	}
+}
+
+func TestRegistryLoad(t *testing.T) {
+	items := randomItemsDim(rand.New(rand.NewSource(7)), 30, testDim)
+	tests := []struct {
+		name   string
+		model  string
+		metric recognize.Metric
+		loaded int
+	}{
+		{name: "same model and metric", model: "clip", metric: recognize.MetricEuclidean, loaded: 1},
+		{name: "other model", model: "clip:norm", metric: recognize.MetricEuclidean},
+		{name: "other metric", model: "clip", metric: recognize.MetricCosine},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			saved, err := NewRegistry(dir, "clip", recognize.MetricEuclidean)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range items {
+				if err := saved.Shop("shop/1").Add(item); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := saved.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			r, err := NewRegistry(dir, tt.model, tt.metric)
+			if err != nil {
+				t.Fatal(err)
+			}
+			loaded, err := r.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loaded != tt.loaded {
+				t.Fatalf("Load() = %d, want %d", loaded, tt.loaded)
+			}
+			want := 0
+			if tt.loaded > 0 {
+				want = len(items)
+			}
+			if n := r.Shop("shop/1").Len(); n != want {
+				t.Errorf("shop index has %d photos, want %d", n, want)
+			}
+		})
+	}
+}
+
+func TestRegistryLoadSkipsRenamedSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	r, err := NewRegistry(dir, "clip", recognize.DefaultMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Shop("a").Add(Item{ID: 1, Vector: []float64{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(); err != nil {
+		t.Fatal(err)
+	}
+	// Снимок магазина a под именем файла магазина b не загружается
+	if err := os.Rename(r.snapshotPath("a"), r.snapshotPath("b")); err != nil {
+		t.Fatal(err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*"+snapshotExt)); len(files) != 1 {
+		t.Fatalf("snapshots on disk: %v", files)
+	}
+
+	loaded, err := NewRegistry(dir, "clip", recognize.DefaultMetric)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := loaded.Load(); err != nil || n != 0 {
+		t.Errorf("Load() = %d, %v, want 0", n, err)
+	}
+}
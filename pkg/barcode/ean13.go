@@ -0,0 +1,238 @@
+// Package barcode распознаёт штрихкоды EAN-13 (и UPC-A как EAN-13 с ведущим нулём) на фото.
+// QR-коды не поддерживаются: для них нужен отдельный декодер с коррекцией ошибок
+package barcode
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"math"
+)
+
+// ErrNotFound на фото нет читаемого штрихкода
+var ErrNotFound = errors.New("barcode not found")
+
+// scanLines сколько горизонталей и вертикалей просматривается на фото
+const scanLines = 24
+
+// Ширины штрихов цифр в модулях для кодов L (и R), G — те же ширины в обратном порядке
+var digitWidths = [10][4]float64{
+	{3, 2, 1, 1}, {2, 2, 2, 1}, {2, 1, 2, 2}, {1, 4, 1, 1}, {1, 1, 3, 2},
+	{1, 2, 3, 1}, {1, 1, 1, 4}, {1, 3, 1, 2}, {1, 2, 1, 3}, {3, 1, 1, 2},
+}
+
+// Чётность левых цифр (бит 1 — код G) задаёт первую цифру EAN-13
+var firstDigitParity = [10]int{0x00, 0x0b, 0x0d, 0x0e, 0x13, 0x19, 0x1c, 0x15, 0x16, 0x1a}
+
+// Decode ищет EAN-13 на фото в JPEG или PNG
+func Decode(data []byte) (string, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return "", fmt.Errorf("can't decode image: %w", err)
+	}
+	return DecodeImage(img)
+}
+
+// DecodeImage ищет EAN-13 вдоль горизонталей, а затем вертикалей изображения,
+// штрихкод может быть перевёрнут
+func DecodeImage(img image.Image) (string, error) {
+	b := img.Bounds()
+	for _, vertical := range []bool{false, true} {
+		length, lines := b.Dx(), b.Dy()
+		if vertical {
+			length, lines = lines, length
+		}
+		for i := 0; i < scanLines; i++ {
+			// Сначала середина, затем всё дальше от неё
+			offset := (i + 1) / 2 * lines / (scanLines + 1)
+			if i%2 == 1 {
+				offset = -offset
+			}
+			line := lines/2 + offset
+
+			row := make([]float64, length)
+			for j := range row {
+				x, y := b.Min.X+j, b.Min.Y+line
+				if vertical {
+					x, y = b.Min.X+line, b.Min.Y+j
+				}
+				row[j] = luminance(img, x, y)
+			}
+			if code, ok := decodeRow(row); ok {
+				return code, nil
+			}
+		}
+	}
+	return "", ErrNotFound
+}
+
+func luminance(img image.Image, x, y int) float64 {
+	r, g, b, _ := img.At(x, y).RGBA()
+	return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+}
+
+// decodeRow бинаризует строку пикселей и ищет в ней штрихкод в обоих направлениях
+func decodeRow(row []float64) (string, bool) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range row {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	// Слишком низкий контраст — штрихов нет
+	if hi-lo < 0.2*0xffff {
+		return "", false
+	}
+	threshold := (lo + hi) / 2
+
+	// Длины серий одного цвета, первая серия — светлая (возможно, нулевой длины).
+	// Граница серий ищется между пикселями по яркости: при дробной ширине модуля
+	// серые пиксели на краях штрихов иначе сдвигают границу на целый пиксель
+	var runs []float64
+	dark, last := false, 0.0
+	for i, v := range row {
+		if (v < threshold) == dark {
+			continue
+		}
+		edge := float64(i)
+		if i > 0 && row[i] != row[i-1] {
+			edge = float64(i-1) + (threshold-row[i-1])/(row[i]-row[i-1]) + 0.5
+		}
+		runs = append(runs, edge-last)
+		dark, last = !dark, edge
+	}
+	runs = append(runs, float64(len(row))-last)
+
+	if code, ok := findEAN13(runs); ok {
+		return code, true
+	}
+	reversed := make([]float64, len(runs))
+	for i, r := range runs {
+		reversed[len(runs)-1-i] = r
+	}
+	// После разворота светлые серии должны остаться на чётных позициях
+	if len(reversed)%2 == 0 {
+		reversed = append([]float64{0}, reversed...)
+	}
+	return findEAN13(reversed)
+}
+
+// findEAN13 перебирает тёмные серии как начало штрихкода: 59 серий = 3 + 6×4 + 5 + 6×4 + 3
+func findEAN13(runs []float64) (string, bool) {
+	for start := 1; start+59 <= len(runs); start += 2 {
+		if code, ok := decodeEAN13(runs[start:start+59], runs[start-1]); ok {
+			return code, true
+		}
+	}
+	return "", false
+}
+
+func decodeEAN13(runs []float64, quiet float64) (string, bool) {
+	var total float64
+	for _, r := range runs {
+		total += r
+	}
+	module := total / 95
+	if module < 1 || quiet < 3*module {
+		return "", false
+	}
+	if !isGuard(runs[0:3], module) || !isGuard(runs[27:32], module) || !isGuard(runs[56:59], module) {
+		return "", false
+	}
+
+	var digits [13]int
+	parity := 0
+	for i := 0; i < 6; i++ {
+		d, g, ok := decodeDigit(runs[3+i*4:7+i*4], true)
+		if !ok {
+			return "", false
+		}
+		digits[1+i] = d
+		if g {
+			parity |= 1 << (5 - i)
+		}
+	}
+	for i := 0; i < 6; i++ {
+		d, _, ok := decodeDigit(runs[32+i*4:36+i*4], false)
+		if !ok {
+			return "", false
+		}
+		digits[7+i] = d
+	}
+
+	first := -1
+	for d, p := range firstDigitParity {
+		if p == parity {
+			first = d
+		}
+	}
+	if first < 0 {
+		return "", false
+	}
+	digits[0] = first
+
+	code := make([]byte, 13)
+	for i, d := range digits {
+		code[i] = byte('0' + d)
+	}
+	if !Valid(string(code)) {
+		return "", false
+	}
+	return string(code), true
+}
+
+// isGuard проверяет, что все серии охранного знака шириной в один модуль
+func isGuard(runs []float64, module float64) bool {
+	for _, r := range runs {
+		if r < 0.5*module || r > 1.5*module+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// decodeDigit подбирает цифру с наименьшим отклонением ширин. Для левой половины
+// проверяются коды L и G, второй результат — true для G
+func decodeDigit(runs []float64, left bool) (int, bool, bool) {
+	sum := runs[0] + runs[1] + runs[2] + runs[3]
+	unit := sum / 7
+
+	best, bestG, bestErr := -1, false, math.Inf(1)
+	for d, w := range digitWidths {
+		patterns := [][4]float64{w}
+		if left {
+			patterns = append(patterns, [4]float64{w[3], w[2], w[1], w[0]})
+		}
+		for p, pattern := range patterns {
+			var e float64
+			for i := range pattern {
+				e += math.Abs(runs[i]/unit - pattern[i])
+			}
+			if e < bestErr {
+				best, bestG, bestErr = d, p == 1, e
+			}
+		}
+	}
+	return best, bestG, bestErr < 1.5
+}
+
+// Valid проверяет длину и контрольную цифру EAN-13
+func Valid(code string) bool {
+	if len(code) != 13 {
+		return false
+	}
+	sum := 0
+	for i := 0; i < 12; i++ {
+		c := code[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		d := int(c - '0')
+		if i%2 == 1 {
+			d *= 3
+		}
+		sum += d
+	}
+	return code[12] == byte('0'+(10-sum%10)%10)
+}
@@ -0,0 +1,173 @@
+package barcode_test
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"image/png"
+	"math/rand"
+	"testing"
+
+	"github.com/Bariban/vector-shop-bot/pkg/barcode"
+	"github.com/Bariban/vector-shop-bot/pkg/barcode/barcodetest"
+)
+
+// Коды с каждой первой цифрой: она задаётся только чётностью левой половины
+var codes = []string{
+	"0036000291452", "1234567890128", "2000000000008", "3014260115531", "4006381333931",
+	"5000112637922", "6291041500213", "7622210449283", "8710398503121", "9780201379624",
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func encodeJPEG(t *testing.T, img image.Image, quality int) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// rotate поворачивает изображение на 90° по часовой стрелке
+func rotate(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	rotated := image.NewGray(image.Rect(0, 0, b.Dy(), b.Dx()))
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			rotated.SetGray(b.Dy()-1-y, x, img.GrayAt(x, y))
+		}
+	}
+	return rotated
+}
+
+// flip отражает изображение по горизонтали, как штрихкод вверх ногами
+func flip(img *image.Gray) *image.Gray {
+	b := img.Bounds()
+	flipped := image.NewGray(b)
+	for y := 0; y < b.Dy(); y++ {
+		for x := 0; x < b.Dx(); x++ {
+			flipped.SetGray(b.Dx()-1-x, y, img.GrayAt(x, y))
+		}
+	}
+	return flipped
+}
+
+func TestDecode(t *testing.T) {
+	for _, code := range codes {
+		t.Run(code, func(t *testing.T) {
+			got, err := barcode.Decode(encodePNG(t, barcodetest.Image(code, 3)))
+			if err != nil || got != code {
+				t.Errorf("Decode = %q, %v, want %q", got, err, code)
+			}
+		})
+	}
+}
+
+func TestDecodeDistorted(t *testing.T) {
+	const code = "4006381333931"
+	tests := []struct {
+		name string
+		data func(t *testing.T) []byte
+	}{
+		{name: "scale 1.2", data: func(t *testing.T) []byte { return encodePNG(t, barcodetest.Image(code, 1.2)) }},
+		{name: "scale 1.5", data: func(t *testing.T) []byte { return encodePNG(t, barcodetest.Image(code, 1.5)) }},
+		{name: "scale 2.3", data: func(t *testing.T) []byte { return encodePNG(t, barcodetest.Image(code, 2.3)) }},
+		{name: "scale 4.7", data: func(t *testing.T) []byte { return encodePNG(t, barcodetest.Image(code, 4.7)) }},
+		{name: "jpeg quality 60", data: func(t *testing.T) []byte { return encodeJPEG(t, barcodetest.Image(code, 3), 60) }},
+		{name: "jpeg scale 2.6 quality 40", data: func(t *testing.T) []byte { return encodeJPEG(t, barcodetest.Image(code, 2.6), 40) }},
+		{name: "vertical", data: func(t *testing.T) []byte { return encodePNG(t, rotate(barcodetest.Image(code, 3))) }},
+		{name: "upside down", data: func(t *testing.T) []byte { return encodePNG(t, flip(barcodetest.Image(code, 3))) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := barcode.Decode(tt.data(t))
+			if err != nil || got != code {
+				t.Errorf("Decode = %q, %v, want %q", got, err, code)
+			}
+		})
+	}
+}
+
+func TestDecodeNotFound(t *testing.T) {
+	blank := image.NewGray(image.Rect(0, 0, 400, 200))
+	draw.Draw(blank, blank.Bounds(), image.White, image.Point{}, draw.Src)
+
+	noise := image.NewGray(image.Rect(0, 0, 400, 200))
+	rnd := rand.New(rand.NewSource(1))
+	for i := range noise.Pix {
+		noise.Pix[i] = uint8(rnd.Intn(256))
+	}
+
+	stripes := image.NewGray(image.Rect(0, 0, 400, 200))
+	for x := 0; x < 400; x++ {
+		for y := 0; y < 200; y++ {
+			if x/4%2 == 0 {
+				stripes.SetGray(x, y, color.Gray{})
+			} else {
+				stripes.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		img  image.Image
+	}{
+		{name: "blank", img: blank},
+		{name: "noise", img: noise},
+		{name: "even stripes", img: stripes},
+		// Последняя цифра не сходится с контрольной суммой
+		{name: "bad checksum", img: barcodetest.Image("4006381333932", 3)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := barcode.Decode(encodePNG(t, tt.img)); !errors.Is(err, barcode.ErrNotFound) {
+				t.Errorf("Decode = %q, %v, want ErrNotFound", got, err)
+			}
+		})
+	}
+}
+
+func TestDecodeNotImage(t *testing.T) {
+	if _, err := barcode.Decode([]byte("not an image")); err == nil || errors.Is(err, barcode.ErrNotFound) {
+		t.Errorf("Decode err = %v, want an image decoding error", err)
+	}
+}
+
+func TestValid(t *testing.T) {
+	tests := []struct {
+		code string
+		want bool
+	}{
+		{"4006381333931", true},
+		{"0036000291452", true},
+		{"4006381333932", false},
+		{"400638133393", false},
+		{"40063813339310", false},
+		{"40063813339a1", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := barcode.Valid(tt.code); got != tt.want {
+			t.Errorf("Valid(%q) = %v, want %v", tt.code, got, tt.want)
+		}
+	}
+	for _, code := range codes {
+		if !barcode.Valid(code) {
+			t.Errorf("Valid(%q) = false", code)
+		}
+	}
+}
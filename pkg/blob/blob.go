@@ -0,0 +1,38 @@
+// Package blob хранит байты фото вне базы: на диске или в S3-совместимом хранилище.
+// Ключи адресуются по содержимому, поэтому одинаковые фото хранятся один раз.
+package blob
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+)
+
+// ErrNotFound объект с таким ключом не найден
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore хранилище бинарных объектов
+type BlobStore interface {
+	// Put сохраняет данные и возвращает ключ по их содержимому
+	Put(ctx context.Context, data []byte) (string, error)
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Key возвращает ключ объекта: sha256 содержимого в hex,
+// первые два символа — каталог, чтобы не складывать все файлы в один
+func Key(data []byte) string {
+	sum := sha256.Sum256(data)
+	h := hex.EncodeToString(sum[:])
+	return h[:2] + "/" + h
+}
+
+// validKey проверяет, что ключ получен из Key и не выходит за пределы хранилища
+func validKey(key string) bool {
+	if len(key) != 2+1+sha256.Size*2 || key[2] != '/' || key[:2] != key[3:5] {
+		return false
+	}
+	_, err := hex.DecodeString(key[3:])
+	return err == nil
+}
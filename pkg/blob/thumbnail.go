@@ -0,0 +1,107 @@
+package blob
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	_ "image/png"
+)
+
+// ThumbnailSize длинная сторона превью в пикселях
+const ThumbnailSize = 320
+
+// Thumbnail уменьшает JPEG или PNG так, чтобы длинная сторона была не больше size,
+// и возвращает JPEG. Маленькие изображения только перекодируются
+func Thumbnail(data []byte, size int) ([]byte, error) {
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("can't decode image: %w", err)
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > size || h > size {
+		if w >= h {
+			h = h * size / w
+			w = size
+		} else {
+			w = w * size / h
+			h = size
+		}
+		if w < 1 {
+			w = 1
+		}
+		if h < 1 {
+			h = 1
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	scale(dst, src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, fmt.Errorf("can't encode thumbnail: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// scale уменьшает src в dst усреднением пикселей исходной области (box filter)
+func scale(dst *image.RGBA, src image.Image) {
+	sb := src.Bounds()
+	dw, dh := dst.Bounds().Dx(), dst.Bounds().Dy()
+	sw, sh := sb.Dx(), sb.Dy()
+
+	for y := 0; y < dh; y++ {
+		y0 := sb.Min.Y + y*sh/dh
+		y1 := sb.Min.Y + (y+1)*sh/dh
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < dw; x++ {
+			x0 := sb.Min.X + x*sw/dw
+			x1 := sb.Min.X + (x+1)*sw/dw
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var r, g, bl, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					cr, cg, cb, ca := src.At(sx, sy).RGBA()
+					r += uint64(cr)
+					g += uint64(cg)
+					bl += uint64(cb)
+					a += uint64(ca)
+					n++
+				}
+			}
+			i := dst.PixOffset(x, y)
+			dst.Pix[i+0] = uint8(r / n >> 8)
+			dst.Pix[i+1] = uint8(g / n >> 8)
+			dst.Pix[i+2] = uint8(bl / n >> 8)
+			dst.Pix[i+3] = uint8(a / n >> 8)
+		}
+	}
+}
+
+// PutImage сохраняет фото и его превью и возвращает их ключи.
+// Если фото не удаётся декодировать, превью — само фото
+func PutImage(ctx context.Context, store BlobStore, data []byte) (key, thumbKey string, err error) {
+	key, err = store.Put(ctx, data)
+	if err != nil {
+		return "", "", err
+	}
+
+	thumb, err := Thumbnail(data, ThumbnailSize)
+	if err != nil {
+		return key, key, nil
+	}
+	thumbKey, err = store.Put(ctx, thumb)
+	if err != nil {
+		return "", "", err
+	}
+	return key, thumbKey, nil
+}
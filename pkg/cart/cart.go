@@ -0,0 +1,375 @@
+// Package cart содержит модель корзины продажи: строки, количества, скидки и итоги.
+// Суммы всегда пересчитываются из строк, Telegram-слой только отображает корзину.
+package cart
+
+import (
+	"errors"
+
+	"github.com/shopspring/decimal"
+)
+
+// Places количество знаков после запятой в ценах и суммах
+const Places = 2
+
+var (
+	ErrNotFound           = errors.New("товар не найден в корзине")
+	ErrOutOfStock         = errors.New("недостаточно товара на складе")
+	ErrInvalidQuantity    = errors.New("количество не может быть отрицательным")
+	ErrFractionalQuantity = errors.New("количество штучного товара должно быть целым")
+	ErrInvalidDiscount    = errors.New("скидка должна быть от 0 до 100")
+	ErrInvalidPrice       = errors.New("цена не может быть отрицательной")
+)
+
+var hundred = decimal.NewFromInt(100)
+
+// Line строка корзины
+type Line struct {
+	ProductID uint
+	Name      string
+	Quantity  decimal.Decimal
+	Stock     decimal.Decimal // остаток на складе на момент добавления
+	// Fractional товар продаётся на вес или метраж, Unit — подпись единицы измерения
+	Fractional bool
+	Unit       string
+	UnitPrice  decimal.Decimal // цена продажи из каталога
+	Discount   uint            // скидка в процентах
+	// PriceOverride фиксированная цена единицы, заменяет цену и процентную скидку
+	PriceOverride *decimal.Decimal
+	// Pending скидка, которая ждёт подтверждения администратора
+	Pending *Pending
+}
+
+// Pending запрошенная скидка на строку: процент или фиксированная цена
+type Pending struct {
+	Discount uint
+	Price    *decimal.Decimal
+}
+
+// EffectiveDiscount скидка в процентах от цены каталога, которую даёт запрос
+func (p Pending) EffectiveDiscount(unitPrice decimal.Decimal) uint {
+	if p.Price == nil {
+		return p.Discount
+	}
+	if !unitPrice.IsPositive() || !p.Price.LessThan(unitPrice) {
+		return 0
+	}
+	percent := hundred.Sub(p.Price.Mul(hundred).Div(unitPrice)).Ceil()
+	if percent.GreaterThan(hundred) {
+		return 100
+	}
+	return uint(percent.IntPart())
+}
+
+// Price цена единицы со скидкой, округлённая до Places знаков
+func (l Line) Price() decimal.Decimal {
+	if l.PriceOverride != nil {
+		return l.PriceOverride.Round(Places)
+	}
+	if l.Discount == 0 {
+		return l.UnitPrice.Round(Places)
+	}
+	factor := hundred.Sub(decimal.NewFromInt(int64(l.Discount)))
+	return l.UnitPrice.Mul(factor).Div(hundred).Round(Places)
+}
+
+// Total сумма строки, округлённая до Places знаков
+func (l Line) Total() decimal.Decimal {
+	return l.Price().Mul(l.Quantity).Round(Places)
+}
+
+// DiscountSum сумма скидки по строке. Цена выше каталожной скидкой не считается
+func (l Line) DiscountSum() decimal.Decimal {
+	full := l.UnitPrice.Round(Places).Mul(l.Quantity).Round(Places)
+	if sum := full.Sub(l.Total()); sum.IsPositive() {
+		return sum
+	}
+	return decimal.Zero
+}
+
+// OrderDiscount скидка на весь чек: процент и/или фиксированная сумма
+type OrderDiscount struct {
+	Percent   decimal.Decimal
+	Amount    decimal.Decimal
+	PromoCode string // промокод, по которому дана скидка
+}
+
+// IsZero сообщает, что скидки на чек нет
+func (d OrderDiscount) IsZero() bool {
+	return d.Percent.IsZero() && d.Amount.IsZero()
+}
+
+// Equal сравнивает скидки по значению
+func (d OrderDiscount) Equal(o OrderDiscount) bool {
+	return d.Percent.Equal(o.Percent) && d.Amount.Equal(o.Amount) && d.PromoCode == o.PromoCode
+}
+
+// sum сумма скидки от суммы строк subtotal, не больше неё
+func (d OrderDiscount) sum(subtotal decimal.Decimal) decimal.Decimal {
+	sum := subtotal.Mul(d.Percent).Div(hundred).Round(Places).Add(d.Amount)
+	if sum.GreaterThan(subtotal) {
+		return subtotal
+	}
+	return sum
+}
+
+// Cart корзина одного чата
+type Cart struct {
+	lines    map[uint]*Line
+	order    []uint
+	discount OrderDiscount
+	// pendingDiscount скидка на чек, которая ждёт подтверждения администратора
+	pendingDiscount *OrderDiscount
+}
+
+func New() *Cart {
+	return &Cart{lines: make(map[uint]*Line)}
+}
+
+// Put добавляет товар в корзину с нулевым количеством или обновляет
+// название, остаток и цену уже добавленного, сохраняя количество и скидку
+func (c *Cart) Put(line Line) {
+	if l, ok := c.lines[line.ProductID]; ok {
+		l.Name = line.Name
+		l.Stock = line.Stock
+		l.Fractional = line.Fractional
+		l.Unit = line.Unit
+		l.UnitPrice = line.UnitPrice
+		return
+	}
+	line.Quantity = decimal.Zero
+	c.lines[line.ProductID] = &line
+	c.order = append(c.order, line.ProductID)
+}
+
+// Add увеличивает количество товара на n
+func (c *Cart) Add(productID uint, n decimal.Decimal) error {
+	l, ok := c.lines[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	return c.SetQuantity(productID, l.Quantity.Add(n))
+}
+
+// Remove уменьшает количество товара на n
+func (c *Cart) Remove(productID uint, n decimal.Decimal) error {
+	l, ok := c.lines[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	return c.SetQuantity(productID, l.Quantity.Sub(n))
+}
+
+// SetQuantity устанавливает количество товара, 0 убирает товар из итога
+func (c *Cart) SetQuantity(productID uint, quantity decimal.Decimal) error {
+	l, ok := c.lines[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	if quantity.IsNegative() {
+		return ErrInvalidQuantity
+	}
+	if !l.Fractional && !quantity.IsInteger() {
+		return ErrFractionalQuantity
+	}
+	if quantity.GreaterThan(l.Stock) {
+		return ErrOutOfStock
+	}
+	l.Quantity = quantity
+	return nil
+}
+
+// ApplyDiscount устанавливает скидку на строку в процентах
+func (c *Cart) ApplyDiscount(productID, percent uint) error {
+	l, ok := c.lines[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	if percent > 100 {
+		return ErrInvalidDiscount
+	}
+	l.Discount = percent
+	l.PriceOverride = nil
+	return nil
+}
+
+// SetPrice устанавливает фиксированную цену единицы товара вместо скидки
+func (c *Cart) SetPrice(productID uint, price decimal.Decimal) error {
+	l, ok := c.lines[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	if price.IsNegative() {
+		return ErrInvalidPrice
+	}
+	price = price.Round(Places)
+	l.PriceOverride = &price
+	l.Discount = 0
+	return nil
+}
+
+// SetPending откладывает скидку на строку до решения администратора
+func (c *Cart) SetPending(productID uint, p Pending) error {
+	l, ok := c.lines[productID]
+	if !ok {
+		return ErrNotFound
+	}
+	if p.Discount > 100 {
+		return ErrInvalidDiscount
+	}
+	if p.Price != nil && p.Price.IsNegative() {
+		return ErrInvalidPrice
+	}
+	l.Pending = &p
+	return nil
+}
+
+// ResolvePending применяет или отклоняет отложенную скидку строки
+func (c *Cart) ResolvePending(productID uint, approve bool) error {
+	l, ok := c.lines[productID]
+	if !ok || l.Pending == nil {
+		return ErrNotFound
+	}
+	p := *l.Pending
+	l.Pending = nil
+	if !approve {
+		return nil
+	}
+	if p.Price != nil {
+		return c.SetPrice(productID, *p.Price)
+	}
+	return c.ApplyDiscount(productID, p.Discount)
+}
+
+// HasPending сообщает, что в корзине есть скидки без решения администратора
+func (c *Cart) HasPending() bool {
+	if c.pendingDiscount != nil {
+		return true
+	}
+	for _, l := range c.Lines() {
+		if l.Pending != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SetOrderDiscount устанавливает скидку на весь чек, заменяя предыдущую и отложенную
+func (c *Cart) SetOrderDiscount(d OrderDiscount) error {
+	d, err := validOrderDiscount(d)
+	if err != nil {
+		return err
+	}
+	c.discount = d
+	c.pendingDiscount = nil
+	return nil
+}
+
+// SetPendingOrderDiscount откладывает скидку на чек до решения администратора
+func (c *Cart) SetPendingOrderDiscount(d OrderDiscount) error {
+	d, err := validOrderDiscount(d)
+	if err != nil {
+		return err
+	}
+	c.pendingDiscount = &d
+	return nil
+}
+
+// PendingOrderDiscount возвращает скидку на чек, которая ждёт решения администратора
+func (c *Cart) PendingOrderDiscount() (OrderDiscount, bool) {
+	if c.pendingDiscount == nil {
+		return OrderDiscount{}, false
+	}
+	return *c.pendingDiscount, true
+}
+
+// ResolvePendingOrderDiscount применяет или отклоняет отложенную скидку на чек
+func (c *Cart) ResolvePendingOrderDiscount(approve bool) error {
+	if c.pendingDiscount == nil {
+		return ErrNotFound
+	}
+	d := *c.pendingDiscount
+	c.pendingDiscount = nil
+	if approve {
+		c.discount = d
+	}
+	return nil
+}
+
+// OrderDiscountPercent доля скидки d от текущей суммы строк в процентах, с округлением вверх
+func (c *Cart) OrderDiscountPercent(d OrderDiscount) uint {
+	subtotal := c.Subtotal()
+	if !subtotal.IsPositive() {
+		return 0
+	}
+	percent := d.sum(subtotal).Mul(hundred).Div(subtotal).Ceil()
+	return uint(percent.IntPart())
+}
+
+func validOrderDiscount(d OrderDiscount) (OrderDiscount, error) {
+	if d.Percent.IsNegative() || d.Percent.GreaterThan(hundred) {
+		return d, ErrInvalidDiscount
+	}
+	if d.Amount.IsNegative() {
+		return d, ErrInvalidPrice
+	}
+	d.Amount = d.Amount.Round(Places)
+	return d, nil
+}
+
+// OrderDiscount возвращает текущую скидку на чек
+func (c *Cart) OrderDiscount() OrderDiscount {
+	return c.discount
+}
+
+// Line возвращает строку корзины по товару
+func (c *Cart) Line(productID uint) (Line, bool) {
+	l, ok := c.lines[productID]
+	if !ok {
+		return Line{}, false
+	}
+	return *l, true
+}
+
+// Lines возвращает строки с ненулевым количеством в порядке добавления
+func (c *Cart) Lines() []Line {
+	lines := make([]Line, 0, len(c.order))
+	for _, id := range c.order {
+		if l := c.lines[id]; l.Quantity.IsPositive() {
+			lines = append(lines, *l)
+		}
+	}
+	return lines
+}
+
+// IsEmpty сообщает, что в корзине нет ни одного товара
+func (c *Cart) IsEmpty() bool {
+	return len(c.Lines()) == 0
+}
+
+// Subtotal сумма строк до скидки на чек
+func (c *Cart) Subtotal() decimal.Decimal {
+	total := decimal.Zero
+	for _, l := range c.Lines() {
+		total = total.Add(l.Total())
+	}
+	return total
+}
+
+// OrderDiscountSum сумма скидки на чек, не больше суммы строк
+func (c *Cart) OrderDiscountSum() decimal.Decimal {
+	return c.discount.sum(c.Subtotal())
+}
+
+// Total итоговая сумма корзины
+func (c *Cart) Total() decimal.Decimal {
+	return c.Subtotal().Sub(c.OrderDiscountSum())
+}
+
+// DiscountTotal сумма всех скидок корзины: по строкам и на чек
+func (c *Cart) DiscountTotal() decimal.Decimal {
+	total := c.OrderDiscountSum()
+	for _, l := range c.Lines() {
+		total = total.Add(l.DiscountSum())
+	}
+	return total
+}
@@ -0,0 +1,402 @@
+package cart
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func decPtr(s string) *decimal.Decimal {
+	d := dec(s)
+	return &d
+}
+
+// newCart корзина с одной строкой товара 1 в количестве quantity
+func newCart(t *testing.T, line Line, quantity string) *Cart {
+	t.Helper()
+	line.ProductID = 1
+	c := New()
+	c.Put(line)
+	if err := c.SetQuantity(1, dec(quantity)); err != nil {
+		t.Fatalf("SetQuantity(%s): %v", quantity, err)
+	}
+	return c
+}
+
+func TestLineRounding(t *testing.T) {
+	tests := []struct {
+		name     string
+		line     Line
+		price    string
+		total    string
+		discount string
+	}{
+		{
+			name:  "price rounded to places",
+			line:  Line{UnitPrice: dec("10.005"), Quantity: dec("3")},
+			price: "10.01", total: "30.03", discount: "0",
+		},
+		{
+			name:  "percent discount rounded per unit",
+			line:  Line{UnitPrice: dec("99.99"), Discount: 15, Quantity: dec("3")},
+			price: "84.99", total: "254.97", discount: "45",
+		},
+		{
+			name:  "fractional quantity total rounded",
+			line:  Line{UnitPrice: dec("99.99"), Quantity: dec("0.333"), Fractional: true},
+			price: "99.99", total: "33.3", discount: "0",
+		},
+		{
+			name:  "price override",
+			line:  Line{UnitPrice: dec("150"), PriceOverride: decPtr("120"), Quantity: dec("2")},
+			price: "120", total: "240", discount: "60",
+		},
+		{
+			name:  "full discount",
+			line:  Line{UnitPrice: dec("150"), Discount: 100, Quantity: dec("2")},
+			price: "0", total: "0", discount: "300",
+		},
+		{
+			name:  "override above list price is no discount",
+			line:  Line{UnitPrice: dec("100"), PriceOverride: decPtr("130"), Quantity: dec("2")},
+			price: "130", total: "260", discount: "0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.line.Price(); !got.Equal(dec(tt.price)) {
+				t.Errorf("Price() = %s, want %s", got, tt.price)
+			}
+			if got := tt.line.Total(); !got.Equal(dec(tt.total)) {
+				t.Errorf("Total() = %s, want %s", got, tt.total)
+			}
+			if got := tt.line.DiscountSum(); !got.Equal(dec(tt.discount)) {
+				t.Errorf("DiscountSum() = %s, want %s", got, tt.discount)
+			}
+		})
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	piece := Line{UnitPrice: dec("10"), Stock: dec("5"), Unit: "шт."}
+	weight := Line{UnitPrice: dec("10"), Stock: dec("2.5"), Unit: "кг", Fractional: true}
+
+	tests := []struct {
+		name string
+		line Line
+		op   func(c *Cart) error
+		err  error
+		want string
+	}{
+		{name: "add within stock", line: piece, op: func(c *Cart) error { return c.Add(1, dec("4")) }, want: "5"},
+		{name: "add over stock", line: piece, op: func(c *Cart) error { return c.Add(1, dec("5")) }, err: ErrOutOfStock, want: "1"},
+		{name: "set over stock", line: piece, op: func(c *Cart) error { return c.SetQuantity(1, dec("6")) }, err: ErrOutOfStock, want: "1"},
+		{name: "set to stock", line: piece, op: func(c *Cart) error { return c.SetQuantity(1, dec("5")) }, want: "5"},
+		{name: "remove below zero", line: piece, op: func(c *Cart) error { return c.Remove(1, dec("2")) }, err: ErrInvalidQuantity, want: "1"},
+		{name: "set zero", line: piece, op: func(c *Cart) error { return c.SetQuantity(1, decimal.Zero) }, want: "0"},
+		{name: "fractional pieces rejected", line: piece, op: func(c *Cart) error { return c.SetQuantity(1, dec("1.5")) }, err: ErrFractionalQuantity, want: "1"},
+		{name: "fractional weight", line: weight, op: func(c *Cart) error { return c.SetQuantity(1, dec("1.25")) }, want: "1.25"},
+		{name: "add weight", line: weight, op: func(c *Cart) error { return c.Add(1, dec("0.5")) }, want: "1.5"},
+		{name: "weight over stock", line: weight, op: func(c *Cart) error { return c.Add(1, dec("1.501")) }, err: ErrOutOfStock, want: "1"},
+		{name: "unknown product", line: piece, op: func(c *Cart) error { return c.Add(2, dec("1")) }, err: ErrNotFound, want: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCart(t, tt.line, "1")
+			if err := tt.op(c); err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			line, _ := c.Line(1)
+			if !line.Quantity.Equal(dec(tt.want)) {
+				t.Errorf("quantity = %s, want %s", line.Quantity, tt.want)
+			}
+		})
+	}
+}
+
+func TestPutKeepsQuantity(t *testing.T) {
+	c := newCart(t, Line{UnitPrice: dec("10"), Stock: dec("5")}, "3")
+	c.Put(Line{ProductID: 1, UnitPrice: dec("12"), Stock: dec("4")})
+
+	line, _ := c.Line(1)
+	if !line.Quantity.Equal(dec("3")) || !line.UnitPrice.Equal(dec("12")) {
+		t.Errorf("line = %+v, want quantity 3 and price 12", line)
+	}
+	if !c.Total().Equal(dec("36")) {
+		t.Errorf("Total() = %s, want 36", c.Total())
+	}
+}
+
+func TestLineDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		op       func(c *Cart) error
+		err      error
+		price    string
+		discount uint
+		override bool
+	}{
+		{name: "percent", op: func(c *Cart) error { return c.ApplyDiscount(1, 10) }, price: "90", discount: 10},
+		{name: "percent over 100", op: func(c *Cart) error { return c.ApplyDiscount(1, 101) }, err: ErrInvalidDiscount, price: "100"},
+		{name: "override", op: func(c *Cart) error { return c.SetPrice(1, dec("79.999")) }, price: "80", override: true},
+		{name: "negative override", op: func(c *Cart) error { return c.SetPrice(1, dec("-1")) }, err: ErrInvalidPrice, price: "100"},
+		{
+			name: "override replaces percent",
+			op: func(c *Cart) error {
+				if err := c.ApplyDiscount(1, 10); err != nil {
+					return err
+				}
+				return c.SetPrice(1, dec("95"))
+			},
+			price: "95", override: true,
+		},
+		{
+			name: "percent replaces override",
+			op: func(c *Cart) error {
+				if err := c.SetPrice(1, dec("95")); err != nil {
+					return err
+				}
+				return c.ApplyDiscount(1, 20)
+			},
+			price: "80", discount: 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCart(t, Line{UnitPrice: dec("100"), Stock: dec("10")}, "1")
+			if err := tt.op(c); err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			line, _ := c.Line(1)
+			if !line.Price().Equal(dec(tt.price)) {
+				t.Errorf("Price() = %s, want %s", line.Price(), tt.price)
+			}
+			if line.Discount != tt.discount || (line.PriceOverride != nil) != tt.override {
+				t.Errorf("discount = %d, override = %v, want %d, %v", line.Discount, line.PriceOverride, tt.discount, tt.override)
+			}
+		})
+	}
+}
+
+func TestOrderDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		discount OrderDiscount
+		err      error
+		sum      string
+		total    string
+	}{
+		{name: "none", sum: "0", total: "250"},
+		{name: "percent", discount: OrderDiscount{Percent: dec("10")}, sum: "25", total: "225"},
+		{name: "amount", discount: OrderDiscount{Amount: dec("30.555")}, sum: "30.56", total: "219.44"},
+		{name: "percent and amount", discount: OrderDiscount{Percent: dec("10"), Amount: dec("5")}, sum: "30", total: "220"},
+		{name: "amount capped at subtotal", discount: OrderDiscount{Amount: dec("1000")}, sum: "250", total: "0"},
+		{name: "percent and amount capped", discount: OrderDiscount{Percent: dec("100"), Amount: dec("1")}, sum: "250", total: "0"},
+		{name: "percent over 100", discount: OrderDiscount{Percent: dec("101")}, err: ErrInvalidDiscount, sum: "0", total: "250"},
+		{name: "negative amount", discount: OrderDiscount{Amount: dec("-1")}, err: ErrInvalidPrice, sum: "0", total: "250"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCart(t, Line{UnitPrice: dec("100"), Stock: dec("10")}, "2")
+			c.Put(Line{ProductID: 2, UnitPrice: dec("50"), Stock: dec("10")})
+			if err := c.SetQuantity(2, dec("1")); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := c.SetOrderDiscount(tt.discount); err != tt.err {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if !c.Subtotal().Equal(dec("250")) {
+				t.Errorf("Subtotal() = %s, want 250", c.Subtotal())
+			}
+			if got := c.OrderDiscountSum(); !got.Equal(dec(tt.sum)) {
+				t.Errorf("OrderDiscountSum() = %s, want %s", got, tt.sum)
+			}
+			if got := c.Total(); !got.Equal(dec(tt.total)) {
+				t.Errorf("Total() = %s, want %s", got, tt.total)
+			}
+		})
+	}
+}
+
+func TestDiscountTotal(t *testing.T) {
+	c := newCart(t, Line{UnitPrice: dec("100"), Stock: dec("10")}, "2")
+	if err := c.ApplyDiscount(1, 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetOrderDiscount(OrderDiscount{Amount: dec("30")}); err != nil {
+		t.Fatal(err)
+	}
+	// 20 по строке и 30 на чек
+	if got := c.DiscountTotal(); !got.Equal(dec("50")) {
+		t.Errorf("DiscountTotal() = %s, want 50", got)
+	}
+	if got := c.Total(); !got.Equal(dec("150")) {
+		t.Errorf("Total() = %s, want 150", got)
+	}
+}
+
+func TestPending(t *testing.T) {
+	tests := []struct {
+		name      string
+		pending   Pending
+		approve   bool
+		err       error
+		price     string
+		effective uint
+	}{
+		{name: "approved percent", pending: Pending{Discount: 30}, approve: true, price: "70", effective: 30},
+		{name: "rejected percent", pending: Pending{Discount: 30}, price: "100", effective: 30},
+		{name: "approved price", pending: Pending{Price: decPtr("66.5")}, approve: true, price: "66.5", effective: 34},
+		{name: "rejected price", pending: Pending{Price: decPtr("66.5")}, price: "100", effective: 34},
+		{name: "price above list", pending: Pending{Price: decPtr("120")}, approve: true, price: "120", effective: 0},
+		{name: "percent over 100", pending: Pending{Discount: 101}, err: ErrInvalidDiscount, price: "100", effective: 101},
+		{name: "negative price", pending: Pending{Price: decPtr("-1")}, err: ErrInvalidPrice, price: "100", effective: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newCart(t, Line{UnitPrice: dec("100"), Stock: dec("10")}, "1")
+			if got := tt.pending.EffectiveDiscount(dec("100")); got != tt.effective {
+				t.Errorf("EffectiveDiscount() = %d, want %d", got, tt.effective)
+			}
+
+			if err := c.SetPending(1, tt.pending); err != tt.err {
+				t.Fatalf("SetPending err = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if c.HasPending() {
+					t.Error("invalid pending discount was kept")
+				}
+				return
+			}
+			if !c.HasPending() {
+				t.Fatal("HasPending() = false after SetPending")
+			}
+			// Пока скидка ждёт решения, строка считается по старой цене
+			if line, _ := c.Line(1); !line.Price().Equal(dec("100")) {
+				t.Errorf("price while pending = %s, want 100", line.Price())
+			}
+
+			if err := c.ResolvePending(1, tt.approve); err != nil {
+				t.Fatalf("ResolvePending: %v", err)
+			}
+			if c.HasPending() {
+				t.Error("HasPending() = true after ResolvePending")
+			}
+			if line, _ := c.Line(1); !line.Price().Equal(dec(tt.price)) {
+				t.Errorf("Price() = %s, want %s", line.Price(), tt.price)
+			}
+			if err := c.ResolvePending(1, true); err != ErrNotFound {
+				t.Errorf("second ResolvePending err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestPendingOrderDiscount(t *testing.T) {
+	tests := []struct {
+		name     string
+		discount OrderDiscount
+		approve  bool
+		err      error
+		percent  uint
+		total    string
+	}{
+		{name: "approved percent", discount: OrderDiscount{Percent: dec("15")}, approve: true, percent: 15, total: "170"},
+		{name: "rejected percent", discount: OrderDiscount{Percent: dec("15")}, percent: 15, total: "190"},
+		{name: "amount rounds up", discount: OrderDiscount{Amount: dec("25")}, approve: true, percent: 13, total: "175"},
+		{name: "amount above subtotal", discount: OrderDiscount{Amount: dec("500")}, approve: true, percent: 100, total: "0"},
+		{name: "percent over 100", discount: OrderDiscount{Percent: dec("101")}, err: ErrInvalidDiscount},
+		{name: "negative amount", discount: OrderDiscount{Amount: dec("-1")}, err: ErrInvalidPrice},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Сумма строк 200, действующая скидка на чек 10
+			c := newCart(t, Line{UnitPrice: dec("100"), Stock: dec("10")}, "2")
+			if err := c.SetOrderDiscount(OrderDiscount{Amount: dec("10")}); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := c.SetPendingOrderDiscount(tt.discount); err != tt.err {
+				t.Fatalf("SetPendingOrderDiscount err = %v, want %v", err, tt.err)
+			}
+			if tt.err != nil {
+				if c.HasPending() {
+					t.Error("invalid order discount was kept")
+				}
+				return
+			}
+			if got := c.OrderDiscountPercent(tt.discount); got != tt.percent {
+				t.Errorf("OrderDiscountPercent() = %d, want %d", got, tt.percent)
+			}
+			if d, ok := c.PendingOrderDiscount(); !ok || !d.Equal(tt.discount) {
+				t.Fatalf("PendingOrderDiscount() = %+v, %v", d, ok)
+			}
+			if !c.HasPending() {
+				t.Fatal("HasPending() = false with pending order discount")
+			}
+			// Пока скидка ждёт решения, действует прежняя
+			if !c.Total().Equal(dec("190")) {
+				t.Errorf("Total() while pending = %s, want 190", c.Total())
+			}
+
+			if err := c.ResolvePendingOrderDiscount(tt.approve); err != nil {
+				t.Fatalf("ResolvePendingOrderDiscount: %v", err)
+			}
+			if c.HasPending() {
+				t.Error("HasPending() = true after ResolvePendingOrderDiscount")
+			}
+			if !c.Total().Equal(dec(tt.total)) {
+				t.Errorf("Total() = %s, want %s", c.Total(), tt.total)
+			}
+			if err := c.ResolvePendingOrderDiscount(true); err != ErrNotFound {
+				t.Errorf("second ResolvePendingOrderDiscount err = %v, want ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestSetOrderDiscountDropsPending(t *testing.T) {
+	c := newCart(t, Line{UnitPrice: dec("100"), Stock: dec("10")}, "1")
+	if err := c.SetPendingOrderDiscount(OrderDiscount{Percent: dec("50")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SetOrderDiscount(OrderDiscount{Percent: dec("5"), PromoCode: "SALE"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := c.PendingOrderDiscount(); ok || c.HasPending() {
+		t.Error("promo code did not replace the pending order discount")
+	}
+}
+
+func TestLinesAndEmpty(t *testing.T) {
+	c := New()
+	if !c.IsEmpty() {
+		t.Fatal("new cart is not empty")
+	}
+	c.Put(Line{ProductID: 2, UnitPrice: dec("1"), Stock: dec("5")})
+	c.Put(Line{ProductID: 1, UnitPrice: dec("1"), Stock: dec("5")})
+	if !c.IsEmpty() {
+		t.Fatal("lines with zero quantity make the cart non-empty")
+	}
+	for _, id := range []uint{2, 1} {
+		if err := c.SetQuantity(id, dec("1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	lines := c.Lines()
+	if len(lines) != 2 || lines[0].ProductID != 2 || lines[1].ProductID != 1 {
+		t.Errorf("Lines() = %+v, want products 2, 1 in insertion order", lines)
+	}
+}
@@ -1,6 +1,10 @@
 package config
 
-import "github.com/spf13/viper"
+import (
+	"time"
+
+	"github.com/spf13/viper"
+)
 
 type Messages struct {
 	Responses
@@ -20,13 +24,51 @@ type Errors struct {
 	UnableToSave string `mapstructure:"unable_to_save"`
 }
 
+// Blob настройки хранилища фото: каталог на диске или S3, если задан s3_endpoint
+type Blob struct {
+	Dir         string `mapstructure:"dir"`
+	S3Endpoint  string `mapstructure:"s3_endpoint"`
+	S3Bucket    string `mapstructure:"s3_bucket"`
+	S3Region    string `mapstructure:"s3_region"`
+	S3AccessKey string `mapstructure:"s3_access_key"`
+	S3SecretKey string `mapstructure:"s3_secret_key"`
+}
+
+// VectorIndex индекс похожих фото в памяти процесса со снимками в каталоге dir.
+// Пустой dir — поиск перебором всех векторов магазина
+type VectorIndex struct {
+	Dir              string        `mapstructure:"dir"`
+	SnapshotInterval time.Duration `mapstructure:"snapshot_interval"`
+}
+
+// Matching сравнение фото при поиске товара: метрика euclidean, cosine или dot,
+// порог совпадения (0 — по умолчанию для метрики) и нормализация векторов при сохранении.
+// Метрика dot верна только для нормализованных векторов
+type Matching struct {
+	Metric    string  `mapstructure:"metric"`
+	Threshold float64 `mapstructure:"threshold"`
+	Normalize bool    `mapstructure:"normalize"`
+}
+
 type Config struct {
 	TelegramToken     string
 	PocketConsumerKey string
 	AuthServerURL     string
 
-	BotURL     string `mapstructure:"bot_url"`
-	BoltDBFile string `mapstructure:"db_file"`
+	BotURL      string `mapstructure:"bot_url"`
+	BoltDBFile  string `mapstructure:"db_file"`
+	DatabaseURL string `mapstructure:"database_url"`
+	ClipURL     string `mapstructure:"clip_url"`
+	// ClipModel модель CLIP-сервиса, которой помечаются векторы фото
+	ClipModel string `mapstructure:"clip_model"`
+
+	Blob Blob `mapstructure:"blob"`
+
+	VectorIndex VectorIndex `mapstructure:"vector_index"`
+	Matching    Matching    `mapstructure:"matching"`
+
+	// MaxDiscount наибольшая скидка в процентах по ролям, выше — только с подтверждением администратора
+	MaxDiscount map[string]uint `mapstructure:"max_discount"`
 
 	Messages Messages
 }
@@ -79,6 +121,42 @@ func fromEnv(cfg *Config) error {
 		return err
 	}
 
+	if err := viper.BindEnv("database_url"); err != nil {
+		return err
+	}
+	if url := viper.GetString("database_url"); url != "" {
+		cfg.DatabaseURL = url
+	}
+
+	if err := viper.BindEnv("clip_url"); err != nil {
+		return err
+	}
+	if url := viper.GetString("clip_url"); url != "" {
+		cfg.ClipURL = url
+	}
+
+	if err := viper.BindEnv("clip_model"); err != nil {
+		return err
+	}
+	if model := viper.GetString("clip_model"); model != "" {
+		cfg.ClipModel = model
+	}
+
+	// Ключи S3 не храним в конфиге
+	if err := viper.BindEnv("s3_access_key"); err != nil {
+		return err
+	}
+	if key := viper.GetString("s3_access_key"); key != "" {
+		cfg.Blob.S3AccessKey = key
+	}
+
+	if err := viper.BindEnv("s3_secret_key"); err != nil {
+		return err
+	}
+	if key := viper.GetString("s3_secret_key"); key != "" {
+		cfg.Blob.S3SecretKey = key
+	}
+
 	return nil
 }
 
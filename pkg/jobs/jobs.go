@@ -0,0 +1,36 @@
+// Package jobs очередь фоновых задач индексации фото: вектор, текст и байты фото
+// считаются воркерами, а при ошибке задача повторяется позже. Очередь хранится в Postgres
+// или в памяти процесса.
+package jobs
+
+import (
+	"context"
+	"time"
+)
+
+// Job задача индексации одного фото
+type Job struct {
+	ID        uint
+	ImageID   uint
+	Attempts  int // сколько раз задача уже выдавалась воркеру, включая текущий
+	Version   int // растёт при каждой повторной постановке фото в очередь
+	LastError string
+	RunAt     time.Time
+}
+
+// Queue очередь задач индексации
+type Queue interface {
+	// Enqueue ставит фото в очередь. Повторная постановка того же фото задачу не дублирует,
+	// а увеличивает её версию, чтобы постановку во время выполнения не потерять
+	Enqueue(ctx context.Context, imageID uint) error
+	// Claim выдаёт задачу, срок которой наступил, на время lease. Невыполненная за это время
+	// задача выдаётся снова. nil — задач нет
+	Claim(ctx context.Context, lease time.Duration) (*Job, error)
+	// Complete удаляет выполненную задачу. Если фото поставили в очередь снова, пока задача
+	// выполнялась, задача остаётся и выдаётся ещё раз
+	Complete(ctx context.Context, job *Job) error
+	// Retry откладывает задачу до runAt и запоминает ошибку
+	Retry(ctx context.Context, jobID uint, cause error, runAt time.Time) error
+	// Pending число задач в очереди, включая отложенные
+	Pending(ctx context.Context) (int, error)
+}
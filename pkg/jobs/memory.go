@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory очередь в памяти процесса: задачи теряются при перезапуске
+type Memory struct {
+	mu     sync.Mutex
+	jobs   []*memoryJob
+	nextID uint
+}
+
+type memoryJob struct {
+	Job
+	lockedUntil time.Time
+}
+
+// NewMemory создаёт пустую очередь в памяти
+func NewMemory() *Memory {
+	return &Memory{nextID: 1}
+}
+
+func (m *Memory) Enqueue(ctx context.Context, imageID uint) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, j := range m.jobs {
+		if j.ImageID == imageID {
+			j.Version++
+			j.Attempts = 0
+			if j.RunAt.After(now) {
+				j.RunAt = now
+			}
+			return nil
+		}
+	}
+	m.jobs = append(m.jobs, &memoryJob{Job: Job{ID: m.nextID, ImageID: imageID, RunAt: now}})
+	m.nextID++
+	return nil
+}
+
+func (m *Memory) Claim(ctx context.Context, lease time.Duration) (*Job, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	var next *memoryJob
+	for _, j := range m.jobs {
+		if j.RunAt.After(now) || j.lockedUntil.After(now) {
+			continue
+		}
+		if next == nil || j.RunAt.Before(next.RunAt) {
+			next = j
+		}
+	}
+	if next == nil {
+		return nil, nil
+	}
+	next.Attempts++
+	next.lockedUntil = now.Add(lease)
+	job := next.Job
+	return &job, nil
+}
+
+func (m *Memory) Complete(ctx context.Context, job *Job) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i, j := range m.jobs {
+		if j.ID != job.ID {
+			continue
+		}
+		if j.Version != job.Version {
+			j.lockedUntil = time.Time{}
+			break
+		}
+		m.jobs = append(m.jobs[:i], m.jobs[i+1:]...)
+		break
+	}
+	return nil
+}
+
+func (m *Memory) Retry(ctx context.Context, jobID uint, cause error, runAt time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, j := range m.jobs {
+		if j.ID == jobID {
+			j.RunAt, j.lockedUntil = runAt, time.Time{}
+			if cause != nil {
+				j.LastError = cause.Error()
+			}
+			break
+		}
+	}
+	return nil
+}
+
+func (m *Memory) Pending(ctx context.Context) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.jobs), nil
+}
@@ -0,0 +1,41 @@
+package jobs
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestMemoryRequeueWhileRunning(t *testing.T) {
+	ctx := context.Background()
+	q := NewMemory()
+	if err := q.Enqueue(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+
+	job, err := q.Claim(ctx, time.Minute)
+	if err != nil || job == nil {
+		t.Fatalf("Claim = %v, %v", job, err)
+	}
+	// Фото поставили в очередь снова, пока задача выполняется
+	if err := q.Enqueue(ctx, 7); err != nil {
+		t.Fatal(err)
+	}
+	if err := q.Complete(ctx, job); err != nil {
+		t.Fatal(err)
+	}
+
+	again, err := q.Claim(ctx, time.Minute)
+	if err != nil || again == nil {
+		t.Fatalf("requeued job was lost: %v, %v", again, err)
+	}
+	if again.ImageID != 7 || again.Version == job.Version {
+		t.Errorf("Claim = %+v, want image 7 with a new version", again)
+	}
+	if err := q.Complete(ctx, again); err != nil {
+		t.Fatal(err)
+	}
+	if n, _ := q.Pending(ctx); n != 0 {
+		t.Errorf("Pending() = %d after completion, want 0", n)
+	}
+}
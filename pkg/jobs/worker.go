@@ -0,0 +1,135 @@
+package jobs
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+// Настройки Worker по умолчанию
+const (
+	DefaultWorkers    = 2
+	DefaultPoll       = 5 * time.Second
+	DefaultLease      = 2 * time.Minute
+	DefaultMinBackoff = 5 * time.Second
+	DefaultMaxBackoff = 10 * time.Minute
+)
+
+// Handler выполняет задачу, ошибка откладывает её повтор
+type Handler func(ctx context.Context, job *Job) error
+
+// Worker разбирает очередь несколькими горутинами. Задачи с ошибкой повторяются
+// с растущей паузой без ограничения числа попыток: фото проиндексируется, когда
+// CLIP-сервис снова станет доступен
+type Worker struct {
+	Queue      Queue
+	Handle     Handler
+	Workers    int
+	Poll       time.Duration // как часто проверять очередь без уведомлений
+	Lease      time.Duration
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+
+	wake chan struct{}
+}
+
+// NewWorker создаёт воркер с настройками по умолчанию
+func NewWorker(queue Queue, handle Handler) *Worker {
+	return &Worker{
+		Queue:      queue,
+		Handle:     handle,
+		Workers:    DefaultWorkers,
+		Poll:       DefaultPoll,
+		Lease:      DefaultLease,
+		MinBackoff: DefaultMinBackoff,
+		MaxBackoff: DefaultMaxBackoff,
+		wake:       make(chan struct{}, 1),
+	}
+}
+
+// Enqueue ставит фото в очередь и будит воркеры
+func (w *Worker) Enqueue(ctx context.Context, imageID uint) error {
+	if err := w.Queue.Enqueue(ctx, imageID); err != nil {
+		return err
+	}
+	select {
+	case w.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Run разбирает очередь до отмены ctx
+func (w *Worker) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < w.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.loop(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func (w *Worker) loop(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		case <-w.wake:
+		}
+
+		// Разбираем всё, что готово, потом ждём уведомления или следующей проверки
+		for ctx.Err() == nil && w.runOne(ctx) {
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(w.Poll)
+	}
+}
+
+// runOne выполняет одну задачу и сообщает, была ли она
+func (w *Worker) runOne(ctx context.Context) bool {
+	job, err := w.Queue.Claim(ctx, w.Lease)
+	if err != nil {
+		log.Printf("не удалось взять задачу индексации: %v", err)
+		return false
+	}
+	if job == nil {
+		return false
+	}
+
+	if err := w.Handle(ctx, job); err != nil {
+		delay := w.backoff(job.Attempts)
+		log.Printf("задача индексации фото %d, попытка %d: %v; повтор через %s", job.ImageID, job.Attempts, err, delay)
+		if err := w.Queue.Retry(ctx, job.ID, err, time.Now().Add(delay)); err != nil {
+			log.Printf("не удалось отложить задачу %d: %v", job.ID, err)
+		}
+		return true
+	}
+	if err := w.Queue.Complete(ctx, job); err != nil {
+		log.Printf("не удалось завершить задачу %d: %v", job.ID, err)
+	}
+	return true
+}
+
+// backoff пауза перед следующей попыткой: удваивается с каждой попыткой до MaxBackoff
+func (w *Worker) backoff(attempts int) time.Duration {
+	delay := w.MinBackoff
+	for i := 1; i < attempts && delay < w.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > w.MaxBackoff {
+		delay = w.MaxBackoff
+	}
+	return delay
+}
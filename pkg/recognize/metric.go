@@ -0,0 +1,137 @@
+package recognize
+
+import (
+	"fmt"
+	"math"
+)
+
+// Metric способ сравнения векторов. Distance любой метрики тем меньше, чем похожее фото
+type Metric string
+
+const (
+	// MetricEuclidean евклидово расстояние, как сравнивались векторы изначально
+	MetricEuclidean Metric = "euclidean"
+	// MetricCosine 1 − косинусное сходство: не зависит от длины векторов, CLIP обучен под него
+	MetricCosine Metric = "cosine"
+	// MetricDot 1 − скалярное произведение: совпадает с cosine на нормализованных векторах и считается быстрее
+	MetricDot Metric = "dot"
+)
+
+// DefaultMetric метрика по умолчанию
+const DefaultMetric = MetricEuclidean
+
+// ParseMetric разбирает имя метрики, пустое имя — DefaultMetric
+func ParseMetric(name string) (Metric, error) {
+	switch m := Metric(name); m {
+	case "":
+		return DefaultMetric, nil
+	case MetricEuclidean, MetricCosine, MetricDot:
+		return m, nil
+	default:
+		return "", fmt.Errorf("неизвестная метрика %q: нужна euclidean, cosine или dot", name)
+	}
+}
+
+// DefaultThreshold порог гибридного расстояния, при котором фото считаются одним товаром.
+// Для нормализованных векторов евклидов порог 0.5 соответствует 0.5²/2 в cosine и dot
+func (m Metric) DefaultThreshold() float64 {
+	if m == MetricCosine || m == MetricDot {
+		return 0.125
+	}
+	return 0.5
+}
+
+// textWeightShare доля порога метрики, на которую совпавшие надписи приближают фото.
+// Подобрана для евклидова расстояния: 0.3 при пороге 0.5
+const textWeightShare = 0.6
+
+// TextWeight на сколько совпадение надписей уменьшает расстояние между фото. Вес считается
+// от порога метрики, чтобы надписи значили одинаково при любой шкале расстояний
+func (m Metric) TextWeight() float64 {
+	return textWeightShare * m.DefaultThreshold()
+}
+
+// HybridDistance объединяет расстояние между векторами фото и совпадение распознанного на них текста.
+// Полностью совпавшие надписи приближают фото на TextWeight, фото без текста сравниваются только по вектору
+func (m Metric) HybridDistance(distance float64, text1, text2 string) float64 {
+	return distance - m.TextWeight()*TextSimilarity(text1, text2)
+}
+
+// Distance расстояние между векторами по метрике
+func (m Metric) Distance(vector1, vector2 []float64) (float64, error) {
+	if len(vector1) != len(vector2) {
+		return 0, fmt.Errorf("Vectors have different dimensions: %d vs %d", len(vector1), len(vector2))
+	}
+	return m.Func()(vector1, vector2), nil
+}
+
+// Func функция расстояния без проверки размерности, для индексов и массовых сравнений
+func (m Metric) Func() func(vector1, vector2 []float64) float64 {
+	switch m {
+	case MetricCosine:
+		return cosineDistance
+	case MetricDot:
+		return func(vector1, vector2 []float64) float64 { return 1 - dot(vector1, vector2) }
+	default:
+		return euclidean
+	}
+}
+
+// CosineSimilarity косинус угла между векторами: от −1 до 1, у нулевого вектора 0
+func CosineSimilarity(vector1, vector2 []float64) (float64, error) {
+	if len(vector1) != len(vector2) {
+		return 0, fmt.Errorf("Vectors have different dimensions: %d vs %d", len(vector1), len(vector2))
+	}
+	return 1 - cosineDistance(vector1, vector2), nil
+}
+
+// DotProduct скалярное произведение векторов
+func DotProduct(vector1, vector2 []float64) (float64, error) {
+	if len(vector1) != len(vector2) {
+		return 0, fmt.Errorf("Vectors have different dimensions: %d vs %d", len(vector1), len(vector2))
+	}
+	return dot(vector1, vector2), nil
+}
+
+// Normalize возвращает копию вектора единичной длины, нулевой вектор остаётся нулевым
+func Normalize(vector []float64) []float64 {
+	norm := math.Sqrt(dot(vector, vector))
+	normalized := make([]float64, len(vector))
+	if norm == 0 {
+		return normalized
+	}
+	for i, v := range vector {
+		normalized[i] = v / norm
+	}
+	return normalized
+}
+
+func dot(vector1, vector2 []float64) float64 {
+	var sum float64
+	for i := range vector1 {
+		sum += vector1[i] * vector2[i]
+	}
+	return sum
+}
+
+func euclidean(vector1, vector2 []float64) float64 {
+	var sum float64
+	for i := range vector1 {
+		diff := vector1[i] - vector2[i]
+		sum += diff * diff
+	}
+	return math.Sqrt(sum)
+}
+
+func cosineDistance(vector1, vector2 []float64) float64 {
+	var d, n1, n2 float64
+	for i := range vector1 {
+		d += vector1[i] * vector2[i]
+		n1 += vector1[i] * vector1[i]
+		n2 += vector2[i] * vector2[i]
+	}
+	if n1 == 0 || n2 == 0 {
+		return 1
+	}
+	return 1 - d/math.Sqrt(n1*n2)
+}
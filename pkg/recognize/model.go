@@ -1,15 +1,19 @@
 package recognize
+
 // Модель ответа из clip
 
 type Recognize interface {
-	GetImageVector(fileID string) ([]float64, error)
 	ExtractFromModel(imageURL string) ([]float64, error)
+	Recognize(imageURL string) (*Response, error)
+	// ActiveModel метка векторов, которые сейчас возвращает сервис: модель и нормализация
+	ActiveModel() string
 }
 
 type Response struct {
+	// Model модель, которую называет сам сервис. Векторы помечаются ActiveModel клиента
+	Model         string             `json:"model"`
 	BestCategory  string             `json:"best_category"`
 	ExtractedText string             `json:"extracted_text"`
 	Features      []float64          `json:"features"`
 	Similarities  map[string]float64 `json:"similarities"`
 }
-
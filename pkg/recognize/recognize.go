@@ -1,69 +1,165 @@
 package recognize
 
 import (
-	"bytes"
-	"encoding/base64"
-	"encoding/gob"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"math"
+	"log"
 	"net/http"
 	"net/url"
 	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-//EncodeVector принимает массив чисел и возвращает строку в формате base64
-func EncodeVector(vector []float64) (string, error) {
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(vector); err != nil {
-		return "", fmt.Errorf("ошибка кодирования вектора: %w", err)
+// CompareFeatureVectors сравнивает два вектора по метрике и возвращает true, если они сходятся.
+func CompareFeatureVectors(metric Metric, vector1, vector2 []float64, d float64) (bool, error) {
+	distance, err := metric.Distance(vector1, vector2)
+	if err != nil {
+		return false, err
 	}
-	return base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+
+	// Возвращаем true, если расстояние меньше или равно порогу
+	return distance <= d, nil
 }
 
-//DecodeVector принимает строку в формате base64 и возвращает массив float32
-func DecodeVector(encoded string) ([]float64, error) {
-	data, err := base64.StdEncoding.DecodeString(encoded)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка декодирования base64: %w", err)
+// Distance возвращает евклидово расстояние между векторами
+func Distance(vector1, vector2 []float64) (float64, error) {
+	// Проверяем, совпадает ли размерность векторов
+	if len(vector1) != len(vector2) {
+		return 0, fmt.Errorf("Vectors have different dimensions: %d vs %d", len(vector1), len(vector2))
 	}
 
-	var vector []float64
-	dec := gob.NewDecoder(bytes.NewReader(data))
-	if err := dec.Decode(&vector); err != nil {
-		return nil, fmt.Errorf("ошибка декодирования вектора: %w", err)
+	return euclidean(vector1, vector2), nil
+}
+
+// TextSimilarity доля слов более короткой надписи, которые есть в другой: от 0 до 1
+func TextSimilarity(text1, text2 string) float64 {
+	words1, words2 := textWords(text1), textWords(text2)
+	if len(words1) == 0 || len(words2) == 0 {
+		return 0
+	}
+	if len(words1) > len(words2) {
+		words1, words2 = words2, words1
 	}
 
-	return vector, nil
+	common := 0
+	for w := range words1 {
+		if words2[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(words1))
 }
 
-// CompareFeatureVectors сравнивает два вектора и возвращает true, если они сходятся.
-func CompareFeatureVectors(vector1, vector2 []float64, d float64) (bool, error) {
-	// Проверяем, совпадает ли размерность векторов
-	if len(vector1) != len(vector2) {
-		return false, fmt.Errorf("Vectors have different dimensions: %d vs %d", len(vector1), len(vector2))
+// NameSimilarity доля общих слов двух названий среди всех их слов: от 0 до 1.
+// В отличие от TextSimilarity короткое название не совпадает полностью с длинным, которое его содержит
+func NameSimilarity(name1, name2 string) float64 {
+	words1, words2 := textWords(name1), textWords(name2)
+	if len(words1) == 0 || len(words2) == 0 {
+		return 0
 	}
 
-	// Вычисляем евклидово расстояние
-	var sum float64
-	for i := range vector1 {
-		diff := vector1[i] - vector2[i]
-		sum += diff * diff
+	common := 0
+	for w := range words1 {
+		if words2[w] {
+			common++
+		}
 	}
-	distance := math.Sqrt(sum)
+	return float64(common) / float64(len(words1)+len(words2)-common)
+}
 
-	// Возвращаем true, если расстояние меньше или равно порогу
-	return distance <= d, nil
+// textWords разбивает OCR-текст на слова в нижнем регистре. Короткие обрывки без цифр
+// отбрасываются: OCR часто выдаёт их из шума, а «500мл» и «7» на упаковке значимы
+func textWords(text string) map[string]bool {
+	words := make(map[string]bool)
+	for _, w := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if utf8.RuneCountInString(w) >= 3 || strings.IndexFunc(w, unicode.IsDigit) >= 0 {
+			words[w] = true
+		}
+	}
+	return words
 }
 
+// SuggestName делает из OCR-текста предложение названия товара: первые строки надписи
+// без лишних пробелов, не длиннее maxRunes с обрезкой по слову
+func SuggestName(text string, maxRunes int) string {
+	var words []string
+	length := 0
+	for _, w := range strings.Fields(text) {
+		n := utf8.RuneCountInString(w)
+		if length > 0 && length+1+n > maxRunes {
+			break
+		}
+		if length > 0 {
+			length++
+		}
+		length += n
+		words = append(words, w)
+	}
+	return strings.Join(words, " ")
+}
+
+// DefaultURL адрес CLIP-сервиса по умолчанию
+const DefaultURL = "http://127.0.0.1:5000/extract_features"
+
+// DefaultModel модель CLIP-сервиса по умолчанию
+const DefaultModel = "clip-vit-b-32"
 
-//ExtractFromModel извлекает вектор из изображения в URL
+// NormalizedSuffix дописывается к модели нормализованных векторов, чтобы /reindex пересчитывал
+// векторы после включения или выключения нормализации так же, как после смены модели
+const NormalizedSuffix = ":norm"
+
+// ModelTag метка векторов модели model, нормализованных, если normalize
+func ModelTag(model string, normalize bool) string {
+	if normalize {
+		return model + NormalizedSuffix
+	}
+	return model
+}
+
+// Client обращается к CLIP-сервису по HTTP. Model из конфига помечает все векторы: при смене
+// версии CLIP её меняют в конфиге и запускают /reindex. Модель, которую называет сам сервис,
+// остаётся в ответе справочно.
+// Normalize приводит векторы к единичной длине до сохранения
+type Client struct {
+	URL       string
+	Model     string
+	Normalize bool
+	HTTP      *http.Client
+}
+
+// NewClient создаёт клиента CLIP-сервиса, пустой url заменяется на DefaultURL
+func NewClient(url string) *Client {
+	if url == "" {
+		url = DefaultURL
+	}
+	return &Client{URL: url, Model: DefaultModel, HTTP: &http.Client{}}
+}
+
+// ActiveModel метка новых векторов: модель и нормализация
+func (c *Client) ActiveModel() string {
+	return ModelTag(c.Model, c.Normalize)
+}
+
+// ExtractFromModel извлекает вектор из изображения в URL сервисом по умолчанию
 func ExtractFromModel(imageURL string) ([]float64, error) {
+	return NewClient(DefaultURL).ExtractFromModel(imageURL)
+}
+
+// ExtractFromModel извлекает вектор из изображения в URL
+func (c *Client) ExtractFromModel(imageURL string) ([]float64, error) {
+	response, err := c.Recognize(imageURL)
+	if err != nil {
+		return nil, err
+	}
+	return response.Features, nil
+}
 
-	// Получаем вектор изображения по URL
-	urlClip := "http://127.0.0.1:5000/extract_features"
+// Recognize возвращает полный ответ CLIP-сервиса: вектор, категорию и текст на фото
+func (c *Client) Recognize(imageURL string) (*Response, error) {
 
 	// Создание тела запроса
 	form := url.Values{}
@@ -71,15 +167,14 @@ func ExtractFromModel(imageURL string) ([]float64, error) {
 	requestBody := strings.NewReader(form.Encode())
 
 	// Создание POST запроса
-	req, err := http.NewRequest("POST", urlClip, requestBody)
+	req, err := http.NewRequest("POST", c.URL, requestBody)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка создания запроса: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
 	// Отправка запроса
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := c.HTTP.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("ошибка отправки запроса: %w", err)
 	}
@@ -96,11 +191,12 @@ func ExtractFromModel(imageURL string) ([]float64, error) {
 	if err := json.Unmarshal(body, &response); err != nil {
 		return nil, fmt.Errorf("ошибка разбора JSON ответа: %w", err)
 	}
+	if response.Model != "" && response.Model != c.Model {
+		log.Printf("CLIP-сервис сообщает модель %s, векторы помечаются моделью из конфига %s", response.Model, c.Model)
+	}
+	if c.Normalize && len(response.Features) > 0 {
+		response.Features = Normalize(response.Features)
+	}
 
-	// Вывод полученных данных
-	// fmt.Println("Лучшая категория:", response.BestCategory)
-	// fmt.Println("Извлеченный текст:", response.ExtractedText)
-	// fmt.Println("Признаки изображения:", response.Features)
-	// fmt.Println("Сходство с категориями:", response.Similarities)
-	return response.Features, nil
+	return &response, nil
 }
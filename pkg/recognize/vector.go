@@ -0,0 +1,61 @@
+package recognize
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+// VectorVersion версия двоичного формата вектора
+const VectorVersion = 1
+
+// vectorMagic начало каждого закодированного вектора
+const vectorMagic = "VEC"
+
+// vectorHeader длина заголовка без имени модели: метка, версия, размерность, длина имени модели
+const vectorHeader = len(vectorMagic) + 1 + 4 + 2
+
+// EncodeVector кодирует вектор модели model в двоичный формат версии VectorVersion.
+// Все числа little-endian: метка "VEC", версия (1 байт), размерность (uint32),
+// длина имени модели (uint16), имя модели, затем компоненты вектора в float32.
+// float32 вдвое компактнее float64, а точности хватает для сравнения фото
+func EncodeVector(vector []float64, model string) ([]byte, error) {
+	if len(model) > math.MaxUint16 {
+		return nil, fmt.Errorf("слишком длинное имя модели: %d байт", len(model))
+	}
+	data := make([]byte, vectorHeader+len(model)+4*len(vector))
+	copy(data, vectorMagic)
+	data[3] = VectorVersion
+	binary.LittleEndian.PutUint32(data[4:], uint32(len(vector)))
+	binary.LittleEndian.PutUint16(data[8:], uint16(len(model)))
+	copy(data[vectorHeader:], model)
+
+	body := data[vectorHeader+len(model):]
+	for i, v := range vector {
+		binary.LittleEndian.PutUint32(body[4*i:], math.Float32bits(float32(v)))
+	}
+	return data, nil
+}
+
+// DecodeVector разбирает вектор, закодированный EncodeVector, и модель, которая его посчитала
+func DecodeVector(data []byte) (vector []float64, model string, err error) {
+	if len(data) < vectorHeader || string(data[:3]) != vectorMagic {
+		return nil, "", fmt.Errorf("данные не являются вектором")
+	}
+	if data[3] != VectorVersion {
+		return nil, "", fmt.Errorf("версия вектора %d не поддерживается", data[3])
+	}
+	dim := int(binary.LittleEndian.Uint32(data[4:]))
+	modelLen := int(binary.LittleEndian.Uint16(data[8:]))
+	if len(data) != vectorHeader+modelLen+4*dim {
+		return nil, "", fmt.Errorf("длина вектора %d байт не совпадает с заголовком", len(data))
+	}
+	model = string(data[vectorHeader : vectorHeader+modelLen])
+
+	body := data[vectorHeader+modelLen:]
+	vector = make([]float64, dim)
+	for i := range vector {
+		vector[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(body[4*i:])))
+	}
+	return vector, model, nil
+}
@@ -0,0 +1,127 @@
+package recognize
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"testing"
+)
+
+// benchDim размерность вектора CLIP ViT-B/32
+const benchDim = 512
+
+func randomVector(rnd *rand.Rand, dim int) []float64 {
+	v := make([]float64, dim)
+	for i := range v {
+		v[i] = rnd.NormFloat64()
+	}
+	return v
+}
+
+func TestVectorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		vector []float64
+		model  string
+	}{
+		{name: "clip", vector: randomVector(rand.New(rand.NewSource(1)), benchDim), model: DefaultModel},
+		{name: "normalized tag", vector: []float64{0.6, -0.8}, model: ModelTag(DefaultModel, true)},
+		{name: "no model", vector: []float64{1, math.MaxFloat32, -math.SmallestNonzeroFloat32}},
+		{name: "empty vector", vector: []float64{}, model: "m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := EncodeVector(tt.vector, tt.model)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := vectorHeader + len(tt.model) + 4*len(tt.vector); len(data) != want {
+				t.Errorf("encoded %d bytes, want %d", len(data), want)
+			}
+			vector, model, err := DecodeVector(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if model != tt.model {
+				t.Errorf("model = %q, want %q", model, tt.model)
+			}
+			if len(vector) != len(tt.vector) {
+				t.Fatalf("decoded %d values, want %d", len(vector), len(tt.vector))
+			}
+			for i := range tt.vector {
+				if vector[i] != float64(float32(tt.vector[i])) {
+					t.Errorf("vector[%d] = %v, want %v", i, vector[i], float32(tt.vector[i]))
+				}
+			}
+		})
+	}
+}
+
+func TestEncodeVectorLongModel(t *testing.T) {
+	if _, err := EncodeVector([]float64{1}, string(make([]byte, math.MaxUint16+1))); err == nil {
+		t.Error("EncodeVector accepted a model name longer than uint16")
+	}
+}
+
+func TestDecodeVectorErrors(t *testing.T) {
+	valid, err := EncodeVector([]float64{1, 2, 3}, "clip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	modify := func(change func(data []byte) []byte) []byte {
+		return change(append([]byte(nil), valid...))
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{name: "empty", data: nil},
+		{name: "shorter than header", data: valid[:vectorHeader-1]},
+		{name: "truncated body", data: valid[:len(valid)-1]},
+		{name: "extra bytes", data: append(append([]byte(nil), valid...), 0)},
+		{name: "bad magic", data: modify(func(d []byte) []byte { d[0] = 'X'; return d })},
+		{name: "text vector", data: []byte("0.1,0.2,0.3,0.4,0.5")},
+		{name: "unknown version", data: modify(func(d []byte) []byte { d[3] = VectorVersion + 1; return d })},
+		{name: "dimension disagrees", data: modify(func(d []byte) []byte {
+			binary.LittleEndian.PutUint32(d[4:], 4)
+			return d
+		})},
+		{name: "model length disagrees", data: modify(func(d []byte) []byte {
+			binary.LittleEndian.PutUint16(d[8:], 100)
+			return d
+		})},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if vector, model, err := DecodeVector(tt.data); err == nil {
+				t.Errorf("DecodeVector = %v, %q, want an error", vector, model)
+			}
+		})
+	}
+}
+
+func BenchmarkEncodeVector(b *testing.B) {
+	vector := randomVector(rand.New(rand.NewSource(1)), benchDim)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := EncodeVector(vector, DefaultModel); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecodeVector(b *testing.B) {
+	data, err := EncodeVector(randomVector(rand.New(rand.NewSource(1)), benchDim), DefaultModel)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := DecodeVector(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package postgres
+
+import (
+	"math"
+	"testing"
+
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+)
+
+func TestLegacyEmbedding(t *testing.T) {
+	tests := []struct {
+		name   string
+		text   string
+		want   []float64
+		ok     bool
+		isNull bool
+	}{
+		{name: "values", text: "0.125,-2.5,3e-3,0", want: []float64{0.125, -2.5, 3e-3, 0}, ok: true},
+		{name: "single value", text: "1", want: []float64{1}, ok: true},
+		{name: "empty", text: "", ok: true, isNull: true},
+		{name: "garbage", text: "0.1,abc", isNull: true},
+		{name: "trailing comma", text: "0.1,", isNull: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embedding, dim, ok, err := legacyEmbedding(1, tt.text, "clip")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != tt.ok {
+				t.Errorf("ok = %v, want %v", ok, tt.ok)
+			}
+			if tt.isNull {
+				if embedding != nil || dim != 0 {
+					t.Errorf("embedding = %v, dim = %d, want NULL", embedding, dim)
+				}
+				return
+			}
+
+			vector, model, err := recognize.DecodeVector(embedding.([]byte))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if model != "clip" || dim != len(tt.want) || len(vector) != len(tt.want) {
+				t.Fatalf("decoded %d values of %q, dim %d, want %d of clip", len(vector), model, dim, len(tt.want))
+			}
+			// Векторы хранятся в float32
+			for i := range tt.want {
+				if math.Abs(vector[i]-tt.want[i]) > 1e-6*math.Max(1, math.Abs(tt.want[i])) {
+					t.Errorf("vector[%d] = %v, want %v", i, vector[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
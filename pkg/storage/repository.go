@@ -3,6 +3,7 @@ package storage
 import (
 	"context"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/shopspring/decimal"
@@ -15,7 +16,7 @@ type Storage interface {
 	GetProducts(ctx context.Context, userName string) ([]*Product, error)
 	SaveImage(ctx context.Context, p *Product) error
 	SearchVector(ctx context.Context, vector []float64) ([]*Product, error)
-	GetPhotosByProductID(ctx context.Context, productID uint) ([][]byte, error)
+	GetImages(ctx context.Context, productID uint) ([]*ImageMeta, error)
 	GetNextProductID(ctx context.Context) (uint, error)
 	UpdPhoto(ctx context.Context, p *Product) error
 	UpdProduct(ctx context.Context, productID uint, param string, value string) error
@@ -25,15 +26,103 @@ type Storage interface {
 
 var ErrNoSavedProducts = errors.New("no saved Products")
 
+// ErrLastImage фото не найдено или это единственное фото товара
+var ErrLastImage = errors.New("can't remove the only photo of a product")
+
+// ErrImageNotFound фото удалено
+var ErrImageNotFound = errors.New("photo not found")
+
+// ErrBarcodeExists у пользователя уже есть товар с таким штрихкодом
+var ErrBarcodeExists = errors.New("product with this barcode already exists")
+
+// ErrPromoCodeUnavailable промокод не найден, истёк или исчерпан
+var ErrPromoCodeUnavailable = errors.New("promo code unavailable")
+
+// ErrMergeUnits у объединяемых товаров разные единицы измерения
+var ErrMergeUnits = errors.New("products have different units")
+
+// ErrMergeVariants варианты есть только у одного из объединяемых товаров
+var ErrMergeVariants = errors.New("only one of the products has variants")
+
 type Product struct {
 	ProductID     uint
 	UserName      string
 	Name          string
 	Description   string
-	Count         uint
+	Count         decimal.Decimal
+	Unit          string // Unit*, для штучного товара количество целое
 	PurchasePrice decimal.Decimal
 	SellingPrice  decimal.Decimal
+	CategoryID    uint   // 0 — без категории
+	Barcode       string // EAN-13, уникален у пользователя
 	Image         []*ImageMeta
+	// Вариант товара (размер, цвет): свои артикул, остаток и, если задана, цена.
+	// Фото, категория и описание берутся у основного товара ParentID
+	ParentID uint
+	Variant  string
+	SKU      string
+}
+
+// Единицы измерения товара
+const (
+	UnitPiece = "pcs"
+	UnitKg    = "kg"
+	UnitMetre = "m"
+	UnitLitre = "l"
+)
+
+// unitLabels подписи единиц измерения
+var unitLabels = map[string]string{
+	UnitPiece: "шт.",
+	UnitKg:    "кг",
+	UnitMetre: "м",
+	UnitLitre: "л",
+}
+
+// UnitLabel подпись единицы измерения, пустая единица — штуки
+func UnitLabel(unit string) string {
+	if label, ok := unitLabels[unit]; ok {
+		return label
+	}
+	return unitLabels[UnitPiece]
+}
+
+// ParseUnit распознаёт единицу измерения по коду или подписи: «kg», «кг», «шт»
+func ParseUnit(s string) (string, bool) {
+	s = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(s)), ".")
+	for unit, label := range unitLabels {
+		if s == unit || s == strings.TrimSuffix(label, ".") {
+			return unit, true
+		}
+	}
+	return "", false
+}
+
+// Fractional можно ли продавать дробное количество товара в этой единице
+func Fractional(unit string) bool {
+	return unit != "" && unit != UnitPiece
+}
+
+// Сортировка и фильтры каталога
+const (
+	SortByName  = "name"
+	SortByStock = "stock"
+	SortByPrice = "price"
+
+	FilterLowStock   = "low"
+	FilterOutOfStock = "out"
+)
+
+// ProductQuery параметры поиска товаров пользователя
+type ProductQuery struct {
+	UserName string
+	Text     string // подстрока названия или описания либо штрихкод целиком
+	Sort     string // SortBy*, по умолчанию по названию
+	Filter   string // Filter* или пусто
+	LowStock uint   // остаток, который считается малым для FilterLowStock
+	Category uint   // категория вместе с подкатегориями, 0 — все
+	Limit    int
+	Offset   int
 }
 
 type ImageMeta struct {
@@ -41,30 +130,115 @@ type ImageMeta struct {
 	ProductID uint
 	Byte      []byte
 	Float     []float64
+	Model     string // модель CLIP, посчитавшая Float
 	Url       string
+	// Ключи фото и превью в blob-хранилище
+	BlobKey  string
+	ThumbKey string
+	// file_id уже загруженных в Telegram фото и превью
+	FileID      string
+	ThumbFileID string
+	// Text надписи на фото, распознанные OCR
+	Text string
+	// SourceFileID file_id присланного фото: по нему фоновая индексация получает свежую ссылку.
+	// Url фото, у которых нет file_id, например из импорта по ссылке
+	SourceFileID string
+	// UserName магазин фото, заполняется при чтении одного фото и векторов всех магазинов
+	UserName string
+	// Подсказки распознавания для мастера добавления, в БД не сохраняются
+	Category     string
+	Similarities map[string]float64
+	Barcode      string
 }
 
 type Order struct {
-	ID  uint
-	UserName string
-	Amount   decimal.Decimal
-	Date     *time.Time
-	PayType  *PayType
-	Details  []*OrderDetail
+	ID         uint
+	UserName   string
+	Amount     decimal.Decimal
+	Date       *time.Time
+	PayType    *PayType
+	Details    []*OrderDetail
 	BuersPhone string
+	// Скидка на чек: Amount = Subtotal - DiscountAmount
+	Subtotal        decimal.Decimal
+	DiscountPercent decimal.Decimal
+	DiscountAmount  decimal.Decimal
+	PromoCode       string
 }
 
 type PayType struct {
-	ID   uint
+	ID          uint
 	Description string
 }
 
 type OrderDetail struct {
-	ID  uint
+	ID        uint
 	OrderID   uint
 	ProductID uint
 	Amount    decimal.Decimal
-	Count     uint
+	Count     decimal.Decimal
 	Discount  uint
 	FactSum   decimal.Decimal
+	// ListPrice цена из каталога, DiscountAmount — скидка по строке в деньгах
+	ListPrice      decimal.Decimal
+	DiscountAmount decimal.Decimal
+	// Название и единица измерения товара, заполняются при выгрузке заказов
+	Name string
+	Unit string
+}
+
+// PromoCode промокод магазина: скидка в процентах или фиксированной суммой
+type PromoCode struct {
+	ID         uint
+	UserName   string
+	Code       string
+	Percent    decimal.Decimal
+	Amount     decimal.Decimal
+	ValidFrom  *time.Time
+	ValidTo    *time.Time
+	UsageLimit uint // 0 — без ограничения
+	UsedCount  uint
+}
+
+// Available проверяет срок действия и лимит использований промокода
+func (p *PromoCode) Available(now time.Time) bool {
+	if p.ValidFrom != nil && now.Before(*p.ValidFrom) {
+		return false
+	}
+	if p.ValidTo != nil && now.After(p.ValidTo.AddDate(0, 0, 1)) {
+		return false
+	}
+	return p.UsageLimit == 0 || p.UsedCount < p.UsageLimit
+}
+
+// Роли пользователей магазина
+const (
+	RoleAdmin  = "admin"
+	RoleSeller = "seller"
+	RoleViewer = "viewer"
+)
+
+// ShopUser участник магазина, ChatID известен после его /start
+type ShopUser struct {
+	ShopID   int
+	UserName string
+	Role     string
+	ChatID   int64
+}
+
+// Category категория товаров, ParentID 0 — корневая
+type Category struct {
+	ID       uint
+	UserName string
+	ParentID uint
+	Name     string
+}
+
+// CategorySales продажи товаров категории в одной единице измерения за период,
+// CategoryID 0 — товары без категории
+type CategorySales struct {
+	CategoryID uint
+	Unit       string
+	Quantity   decimal.Decimal
+	Revenue    decimal.Decimal
 }
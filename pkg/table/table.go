@@ -0,0 +1,121 @@
+// Package table читает и записывает таблицы CSV и XLSX для импорта и выгрузки каталога.
+// XLSX разбирается и собирается вручную: это zip с XML-частями, внешние библиотеки не нужны
+package table
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// ErrUnsupported формат файла не поддерживается
+var ErrUnsupported = errors.New("unsupported table format")
+
+// IsTable сообщает, что файл с таким именем можно прочитать через Read
+func IsTable(name string) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv", ".xlsx":
+		return true
+	}
+	return false
+}
+
+// Read читает строки таблицы из CSV или первого листа XLSX по расширению имени файла
+func Read(name string, data []byte) ([][]string, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return ReadCSV(data)
+	case ".xlsx":
+		return ReadXLSX(data)
+	}
+	return nil, ErrUnsupported
+}
+
+// ReadCSV читает CSV с разделителем «,», «;» или табуляцией: разделитель определяется
+// по первой строке, BOM от Excel отбрасывается
+func ReadCSV(data []byte) ([][]string, error) {
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	first := data
+	if i := bytes.IndexByte(data, '\n'); i >= 0 {
+		first = data[:i]
+	}
+	delimiter, best := ',', bytes.Count(first, []byte(","))
+	for _, d := range []rune{';', '\t'} {
+		if n := bytes.Count(first, []byte(string(d))); n > best {
+			delimiter, best = d, n
+		}
+	}
+
+	r := csv.NewReader(bytes.NewReader(data))
+	r.Comma = delimiter
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	rows, err := r.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("can't read csv: %w", err)
+	}
+	return rows, nil
+}
+
+// Write записывает строки в CSV или XLSX по расширению имени файла. Значения ячеек — строки,
+// целые и дробные числа, decimal.Decimal и time.Time (дата ГГГГ-ММ-ДД); в XLSX числа
+// остаются числами, чтобы по ним можно было считать
+func Write(name string, rows [][]interface{}) ([]byte, error) {
+	switch strings.ToLower(path.Ext(name)) {
+	case ".csv":
+		return WriteCSV(rows)
+	case ".xlsx":
+		return WriteXLSX(path.Base(strings.TrimSuffix(name, path.Ext(name))), rows)
+	}
+	return nil, ErrUnsupported
+}
+
+// WriteCSV записывает CSV с разделителем «,» и BOM, по которому Excel узнаёт UTF-8
+func WriteCSV(rows [][]interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString("\xef\xbb\xbf")
+	w := csv.NewWriter(&buf)
+	for _, row := range rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i], _ = cellValue(v)
+		}
+		if err := w.Write(record); err != nil {
+			return nil, fmt.Errorf("can't write csv: %w", err)
+		}
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		return nil, fmt.Errorf("can't write csv: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// cellValue текст ячейки и признак числа
+func cellValue(v interface{}) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "", false
+	case string:
+		return v, false
+	case decimal.Decimal:
+		return v.String(), true
+	case int, int64, uint, uint64:
+		return fmt.Sprint(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case time.Time:
+		return v.Format("2006-01-02"), false
+	case fmt.Stringer:
+		return v.String(), false
+	}
+	return fmt.Sprint(v), false
+}
@@ -0,0 +1,268 @@
+package table
+
+import (
+	"archive/zip"
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+func TestReadCSV(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		want [][]string
+	}{
+		{
+			name: "comma",
+			data: "название,цена продажи\nМыло,100\n",
+			want: [][]string{{"название", "цена продажи"}, {"Мыло", "100"}},
+		},
+		{
+			name: "semicolon from excel",
+			data: "\xef\xbb\xbfназвание;цена продажи;описание\nМыло;100,50;для рук, с маслом\n",
+			want: [][]string{{"название", "цена продажи", "описание"}, {"Мыло", "100,50", "для рук, с маслом"}},
+		},
+		{
+			name: "tab",
+			data: "название\tцена\nМыло\t100\n",
+			want: [][]string{{"название", "цена"}, {"Мыло", "100"}},
+		},
+		{
+			name: "delimiter from header only",
+			data: "название;цена\n\"Мыло, жидкое\";100\n",
+			want: [][]string{{"название", "цена"}, {"Мыло, жидкое", "100"}},
+		},
+		{
+			name: "ragged and empty rows",
+			data: "название,цена,штрихкод\n\nМыло,100\n,,\n",
+			want: [][]string{{"название", "цена", "штрихкод"}, {"Мыло", "100"}, {"", "", ""}},
+		},
+		{
+			name: "no trailing newline",
+			data: "название,цена",
+			want: [][]string{{"название", "цена"}},
+		},
+		{
+			name: "empty",
+			data: "",
+			want: nil,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadCSV([]byte(tt.data))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadCSV() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// xlsxFile собирает книгу из частей; workbook и связи добавляются, если их нет в parts
+func xlsxFile(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	all := map[string]string{
+		"xl/workbook.xml": `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="Товары" sheetId="1" r:id="rId1"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": xlsxWorkbookRels,
+	}
+	for name, data := range parts {
+		all[name] = data
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, data := range all {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(data)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func sheetXML(rows string) string {
+	return `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>` + rows + `</sheetData></worksheet>`
+}
+
+func TestReadXLSX(t *testing.T) {
+	shared := `<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<si><t>название</t></si>` +
+		`<si><t>цена продажи</t></si>` +
+		`<si><r><t>Мыло </t></r><r><rPr><b/></rPr><t>жидкое</t></r></si>` +
+		`</sst>`
+
+	tests := []struct {
+		name  string
+		parts map[string]string
+		want  [][]string
+	}{
+		{
+			name: "shared strings and numbers",
+			parts: map[string]string{
+				"xl/sharedStrings.xml": shared,
+				"xl/worksheets/sheet1.xml": sheetXML(
+					`<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c><c r="C1"><v>0.1</v></c></row>` +
+						`<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2"><v>199.99</v></c><c r="C2"><v>4600000000001</v></c></row>`),
+			},
+			want: [][]string{
+				{"название", "цена продажи", "0.1"},
+				{"Мыло жидкое", "199.99", "4600000000001"},
+			},
+		},
+		{
+			name: "inline strings, booleans and formulas",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": sheetXML(
+					`<row r="1"><c r="A1" t="inlineStr"><is><t>Мыло</t></is></c><c r="B1" t="b"><v>1</v></c>` +
+						`<c r="C1" t="b"><v>0</v></c><c r="D1"><f>2*50</f><v>100</v></c></row>`),
+			},
+			want: [][]string{{"Мыло", "TRUE", "FALSE", "100"}},
+		},
+		{
+			name: "skipped rows and cells",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": sheetXML(
+					`<row r="2"><c r="A2" t="inlineStr"><is><t>название</t></is></c><c r="C2"><v>5</v></c></row>` +
+						`<row r="5"><c r="AB5"><v>1</v></c></row>`),
+			},
+			want: [][]string{
+				nil,
+				{"название", "", "5"},
+				nil,
+				nil,
+				append(make([]string, 27), "1"),
+			},
+		},
+		{
+			name: "cells without references",
+			parts: map[string]string{
+				"xl/worksheets/sheet1.xml": sheetXML(
+					`<row><c t="inlineStr"><is><t>a</t></is></c><c><v>2</v></c></row>`),
+			},
+			want: [][]string{{"a", "2"}},
+		},
+		{
+			name: "workbook without relationships",
+			parts: map[string]string{
+				"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships"/>`,
+				"xl/worksheets/sheet1.xml":   sheetXML(`<row r="1"><c r="A1"><v>7</v></c></row>`),
+			},
+			want: [][]string{{"7"}},
+		},
+		{
+			name: "sheet with absolute target",
+			parts: map[string]string{
+				"xl/_rels/workbook.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+					`<Relationship Id="rId1" Target="/xl/worksheets/data.xml"/></Relationships>`,
+				"xl/worksheets/data.xml": sheetXML(`<row r="1"><c r="A1"><v>8</v></c></row>`),
+			},
+			want: [][]string{{"8"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadXLSX(xlsxFile(t, tt.parts))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ReadXLSX() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadXLSXErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"not zip", []byte("название,цена")},
+		{"no workbook", xlsxFile(t, map[string]string{"xl/workbook.xml": `<workbook/>`})},
+		{"no sheet", xlsxFile(t, nil)},
+		{"bad shared string", xlsxFile(t, map[string]string{
+			"xl/worksheets/sheet1.xml": sheetXML(`<row r="1"><c r="A1" t="s"><v>3</v></c></row>`),
+		})},
+		{"broken xml", xlsxFile(t, map[string]string{"xl/worksheets/sheet1.xml": `<worksheet><sheetData>`})},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rows, err := ReadXLSX(tt.data); err == nil {
+				t.Errorf("ReadXLSX() = %q, want error", rows)
+			}
+		})
+	}
+}
+
+func TestWriteRead(t *testing.T) {
+	rows := [][]interface{}{
+		{"Название", "Количество", "Цена", "Дата", "Пусто"},
+		{"Мыло <жидкое> & «с маслом»", decimal.RequireFromString("1.5"), 199.99, time.Date(2024, 3, 8, 15, 0, 0, 0, time.UTC), nil},
+		{"  пробелы  ", 3, uint(0), "", "x"},
+	}
+	want := [][]string{
+		{"Название", "Количество", "Цена", "Дата", "Пусто"},
+		{"Мыло <жидкое> & «с маслом»", "1.5", "199.99", "2024-03-08"},
+		{"  пробелы  ", "3", "0", "", "x"},
+	}
+
+	for _, name := range []string{"catalog.csv", "catalog.xlsx", "CATALOG.XLSX"} {
+		t.Run(name, func(t *testing.T) {
+			data, err := Write(name, rows)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := Read(name, data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// CSV сохраняет пустые ячейки в конце строки, XLSX их не пишет
+			if len(got) > 1 && len(got[1]) == 5 && got[1][4] == "" {
+				got[1] = got[1][:4]
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Read(Write()) = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestUnsupported(t *testing.T) {
+	if IsTable("catalog.xls") || !IsTable("Catalog.CSV") || !IsTable("dir/catalog.xlsx") {
+		t.Error("IsTable() recognises wrong extensions")
+	}
+	if _, err := Read("catalog.xls", nil); err != ErrUnsupported {
+		t.Errorf("Read() error = %v, want ErrUnsupported", err)
+	}
+	if _, err := Write("catalog.ods", nil); err != ErrUnsupported {
+		t.Errorf("Write() error = %v, want ErrUnsupported", err)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	for col, name := range map[int]string{0: "A", 25: "Z", 26: "AA", 27: "AB", 701: "ZZ", 702: "AAA"} {
+		if got := columnName(col); got != name {
+			t.Errorf("columnName(%d) = %s, want %s", col, got, name)
+		}
+		if got := columnIndex(name + "12"); got != col {
+			t.Errorf("columnIndex(%s12) = %d, want %d", name, got, col)
+		}
+	}
+	if got := columnIndex("12"); got != -1 {
+		t.Errorf("columnIndex(12) = %d, want -1", got)
+	}
+}
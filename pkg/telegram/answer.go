@@ -0,0 +1,49 @@
+package telegram
+
+import (
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// toast показывает короткое всплывающее уведомление в ответ на нажатие кнопки
+func (b *Bot) toast(callback *tgbotapi.CallbackQuery, text string) {
+	b.answerCallback(callback, text, false)
+}
+
+// alert показывает окно с ошибкой, которое пользователь должен закрыть
+func (b *Bot) alert(callback *tgbotapi.CallbackQuery, text string) {
+	b.answerCallback(callback, text, true)
+}
+
+func (b *Bot) answerCallback(callback *tgbotapi.CallbackQuery, text string, alert bool) {
+	if callback == nil || b.answered[callback.ID] {
+		return
+	}
+	b.answered[callback.ID] = true
+	if err := b.bot.AnswerCallback(callback.ID, text, alert); err != nil {
+		log.Printf("не удалось ответить на callback %s: %v", callback.ID, err)
+	}
+}
+
+// answerCallbacks гарантирует ответ на каждое нажатие inline-кнопки,
+// иначе клиент Telegram продолжает показывать индикатор загрузки
+func (b *Bot) answerCallbacks() Middleware {
+	return func(next Handler) Handler {
+		return func(update tgbotapi.Update) error {
+			callback := update.CallbackQuery
+			if callback == nil {
+				return next(update)
+			}
+
+			err := next(update)
+			if err != nil {
+				b.alert(callback, b.messages.Errors.Default)
+			} else {
+				b.toast(callback, "")
+			}
+			delete(b.answered, callback.ID)
+			return err
+		}
+	}
+}
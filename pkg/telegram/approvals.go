@@ -0,0 +1,211 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// approval запрос продавца на скидку сверх лимита его роли.
+// Запросы живут в памяти: после перезапуска бота продавец запрашивает скидку заново
+type approval struct {
+	shopID    int
+	chatID    int64 // чат продавца
+	seller    string
+	productID uint
+	name      string
+	discount  uint
+	pending   cart.Pending
+	order     *cart.OrderDiscount // скидка на весь чек вместо скидки на строку
+	messages  map[int64]int       // сообщения с запросом у администраторов
+}
+
+// current сообщает, что корзина продавца всё ещё ждёт решения по этому запросу
+func (a *approval) current(c *cart.Cart) bool {
+	if a.order != nil {
+		d, ok := c.PendingOrderDiscount()
+		return ok && d.Equal(*a.order)
+	}
+	line, exists := c.Line(a.productID)
+	return exists && line.Pending != nil && *line.Pending == a.pending
+}
+
+// resolve применяет или отклоняет отложенную скидку в корзине продавца
+func (a *approval) resolve(c *cart.Cart, approve bool) error {
+	if a.order != nil {
+		return c.ResolvePendingOrderDiscount(approve)
+	}
+	return c.ResolvePending(a.productID, approve)
+}
+
+// maxDiscountFor возвращает лимит скидки пользователя и его магазин.
+// Пользователь вне магазина ведёт свои товары сам и ограничений не имеет
+func (b *Bot) maxDiscountFor(username string) (uint, int, error) {
+	shopID, role, err := b.storage.GetUserShop(context.Background(), username)
+	if err != nil {
+		return 0, 0, err
+	}
+	if shopID == 0 {
+		return 100, 0, nil
+	}
+	limit, ok := b.maxDiscount[role]
+	if !ok {
+		return 100, shopID, nil
+	}
+	return limit, shopID, nil
+}
+
+// isShopAdmin сообщает, что пользователь администратор своего магазина
+// или ведёт товары сам, вне магазина
+func (b *Bot) isShopAdmin(ctx context.Context, username string) (bool, error) {
+	shopID, role, err := b.storage.GetUserShop(ctx, username)
+	if err != nil {
+		return false, err
+	}
+	return shopID == 0 || role == storage.RoleAdmin, nil
+}
+
+// requestDiscountApproval отправляет администраторам магазина запрос на скидку
+func (b *Bot) requestDiscountApproval(message *tgbotapi.Message, shopID int, line cart.Line, discount, limit uint) error {
+	a := &approval{
+		shopID:    shopID,
+		chatID:    message.Chat.ID,
+		seller:    message.Chat.UserName,
+		productID: line.ProductID,
+		name:      line.Name,
+		discount:  discount,
+		pending:   *line.Pending,
+		messages:  make(map[int64]int),
+	}
+
+	text := fmt.Sprintf("🔔 @%s просит скидку %d%% на «%s» (%s × %s)\nЛимит продавца: %d%%",
+		a.seller, discount, a.name, lineQuantity(line), line.UnitPrice.StringFixed(cart.Places), limit)
+	if a.pending.Price != nil {
+		text += "\nНовая цена: " + a.pending.Price.StringFixed(cart.Places)
+	}
+	return b.sendApproval(a, text, limit)
+}
+
+// requestOrderDiscountApproval отправляет администраторам магазина запрос на скидку на чек
+func (b *Bot) requestOrderDiscountApproval(message *tgbotapi.Message, shopID int, order cart.OrderDiscount, discount, limit uint) error {
+	a := &approval{
+		shopID:   shopID,
+		chatID:   message.Chat.ID,
+		seller:   message.Chat.UserName,
+		name:     "весь чек",
+		discount: discount,
+		order:    &order,
+		messages: make(map[int64]int),
+	}
+
+	text := fmt.Sprintf("🔔 @%s просит скидку на чек %s (%d%% от %s)\nЛимит продавца: %d%%",
+		a.seller, describeDiscount(order.Percent, order.Amount), discount,
+		b.cart(a.chatID).Subtotal().StringFixed(cart.Places), limit)
+	return b.sendApproval(a, text, limit)
+}
+
+// sendApproval рассылает запрос администраторам магазина и сообщает продавцу, что скидка ждёт решения
+func (b *Bot) sendApproval(a *approval, text string, limit uint) error {
+	users, err := b.storage.GetShopUsers(context.Background(), a.shopID)
+	if err != nil {
+		return err
+	}
+
+	b.nextApproval++
+	id := b.nextApproval
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("✅ Одобрить", fmt.Sprintf("%s_%d", ApproveDiscountCmd, id)),
+			tgbotapi.NewInlineKeyboardButtonData("❌ Отклонить", fmt.Sprintf("%s_%d", RejectDiscountCmd, id)),
+		),
+	)
+
+	for _, u := range users {
+		if u.Role != storage.RoleAdmin || u.ChatID == 0 || u.UserName == a.seller {
+			continue
+		}
+		msg := tgbotapi.NewMessage(u.ChatID, text)
+		msg.ReplyMarkup = keyboard
+		sent, err := b.bot.Send(msg)
+		if err != nil {
+			log.Printf("не удалось отправить запрос скидки @%s: %v", u.UserName, err)
+			continue
+		}
+		a.messages[u.ChatID] = sent.MessageID
+	}
+
+	if len(a.messages) == 0 {
+		a.resolve(b.cart(a.chatID), false)
+		_, err := b.bot.Send(tgbotapi.NewMessage(a.chatID, fmt.Sprintf(
+			"Скидка %d%% превышает ваш лимит %d%%, а администраторов для подтверждения нет. Они должны написать боту /start.", a.discount, limit)))
+		return err
+	}
+
+	b.approvals[id] = a
+	_, err = b.bot.Send(tgbotapi.NewMessage(a.chatID, fmt.Sprintf(
+		"Скидка %d%% превышает ваш лимит %d%%. Запрос отправлен администратору.", a.discount, limit)))
+	return err
+}
+
+// handleDiscountDecision обрабатывает решение администратора по запросу скидки
+func (b *Bot) handleDiscountDecision(approve bool) CallbackHandler {
+	return func(callback *tgbotapi.CallbackQuery) error {
+		data := callback.Data
+		id, _ := strconv.Atoi(data[strings.LastIndex(data, "_")+1:])
+
+		a, ok := b.approvals[id]
+		if !ok {
+			b.alert(callback, "Запрос уже обработан или устарел")
+			return nil
+		}
+
+		role, err := b.storage.GetUserRole(context.Background(), a.shopID, callback.From.UserName)
+		if err != nil {
+			return err
+		}
+		if role != storage.RoleAdmin {
+			b.alert(callback, "Решать может только администратор магазина")
+			return nil
+		}
+		delete(b.approvals, id)
+
+		c := b.cart(a.chatID)
+		if !a.current(c) {
+			b.closeApproval(a, "Запрос устарел: корзина продавца изменилась")
+			return nil
+		}
+		if err := a.resolve(c, approve); err != nil {
+			return err
+		}
+
+		verdict, reply := "❌ Отклонено", "отклонил"
+		if approve {
+			verdict, reply = "✅ Одобрено", "одобрил"
+		}
+		b.closeApproval(a, fmt.Sprintf("%s @%s", verdict, callback.From.UserName))
+		b.toast(callback, verdict)
+
+		b.bot.Send(tgbotapi.NewMessage(a.chatID, fmt.Sprintf("@%s %s скидку %d%% на «%s»",
+			callback.From.UserName, reply, a.discount, a.name)))
+		if a.order != nil {
+			return b.renderCart(a.chatID)
+		}
+		return b.refreshCartLine(a.chatID, a.productID)
+	}
+}
+
+// closeApproval убирает кнопки у всех администраторов и дописывает итог
+func (b *Bot) closeApproval(a *approval, result string) {
+	text := fmt.Sprintf("Скидка %d%% на «%s» для @%s\n%s", a.discount, a.name, a.seller, result)
+	for chatID, msgID := range a.messages {
+		if _, err := b.bot.Send(tgbotapi.NewEditMessageText(chatID, msgID, text)); err != nil {
+			log.Printf("не удалось обновить запрос скидки %d: %v", msgID, err)
+		}
+	}
+}
@@ -2,36 +2,105 @@ package telegram
 
 import (
 	"log"
+	"sync"
 
+	"github.com/Bariban/vector-shop-bot/pkg/ann"
+	"github.com/Bariban/vector-shop-bot/pkg/blob"
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
 	"github.com/Bariban/vector-shop-bot/pkg/config"
+	"github.com/Bariban/vector-shop-bot/pkg/jobs"
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
 	s "github.com/Bariban/vector-shop-bot/pkg/storage"
 	"github.com/Bariban/vector-shop-bot/pkg/storage/postgres"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
-	"github.com/shopspring/decimal"
 )
 
 type Bot struct {
-	bot            *tgbotapi.BotAPI
+	bot            Messenger
+	tracker        *trackingMessenger
 	storage        postgres.Storage
+	recognizer     recognize.Recognize
+	blobs          blob.BlobStore
 	messages       config.Messages
 	states         map[int64]int
 	tempProduct    map[int64]*s.Product
 	tempMsgID      map[int64]int
 	selectedParams map[int64]map[string]bool
-	cartItems      map[int64]Cart
+	carts          map[int64]*cart.Cart
+	catalogs       map[int64]*catalogState
+	maxDiscount    map[string]uint
+	approvals      map[int]*approval
+	nextApproval   int
+	imports        map[int64]*importBatch
+	recognitions   map[int64][]*recognition
+	recognized     chan *recognition // завершённые распознавания первого фото мастера
+	duplicates     map[int64]*duplicateReview
+	indexer        *jobs.Worker
+	reindexing     sync.Map // магазины, в которых идёт переиндексация
+	vectors        *ann.Registry
+	metric         recognize.Metric
+	matchThreshold float64 // наибольшее гибридное расстояние, при котором фото считаются одним товаром
+
+	messageHandlers  map[string]Handler
+	commandHandlers  map[string]Handler
+	callbackHandlers map[string]Handler
+	middlewares      []Middleware
+	answered         map[string]bool
 }
 
-func NewBot(bot *tgbotapi.BotAPI, storage *postgres.Storage, messages config.Messages) *Bot {
-	return &Bot{
-		bot:            bot,
+func NewBot(bot Messenger, storage *postgres.Storage, recognizer recognize.Recognize, blobs blob.BlobStore, messages config.Messages) *Bot {
+	tracker := newTrackingMessenger(bot)
+	b := &Bot{
+		bot:            tracker,
+		tracker:        tracker,
 		storage:        *storage,
+		recognizer:     recognizer,
+		blobs:          blobs,
 		messages:       messages,
 		states:         make(map[int64]int),
 		tempProduct:    make(map[int64]*s.Product),
 		tempMsgID:      make(map[int64]int),
 		selectedParams: make(map[int64]map[string]bool),
-		cartItems:      make(map[int64]Cart),
+		carts:          make(map[int64]*cart.Cart),
+		catalogs:       make(map[int64]*catalogState),
+		answered:       make(map[string]bool),
+		approvals:      make(map[int]*approval),
+		imports:        make(map[int64]*importBatch),
+		recognitions:   make(map[int64][]*recognition),
+		recognized:     make(chan *recognition),
+		duplicates:     make(map[int64]*duplicateReview),
+		metric:         recognize.DefaultMetric,
+		matchThreshold: recognize.DefaultMetric.DefaultThreshold(),
 	}
+	b.indexer = jobs.NewWorker(jobs.NewMemory(), b.indexImage)
+	b.middlewares = []Middleware{b.answerCallbacks(), Recover(), Logging()}
+	b.registerHandlers()
+	return b
+}
+
+// Use добавляет middlewares, которые оборачивают обработку каждого обновления
+func (b *Bot) Use(middlewares ...Middleware) {
+	b.middlewares = append(b.middlewares, middlewares...)
+}
+
+// SetMatching задаёт метрику сравнения фото и порог совпадения, 0 — порог метрики по умолчанию.
+// Индекс SetVectorIndex должен использовать ту же метрику
+func (b *Bot) SetMatching(metric recognize.Metric, threshold float64) {
+	if threshold == 0 {
+		threshold = metric.DefaultThreshold()
+	}
+	b.metric, b.matchThreshold = metric, threshold
+}
+
+// SetMaxDiscount задаёт наибольшую скидку в процентах по ролям магазина,
+// роли без ограничения могут давать любую скидку
+func (b *Bot) SetMaxDiscount(limits map[string]uint) {
+	b.maxDiscount = limits
+}
+
+// HandleUpdate прогоняет обновление через цепочку middlewares и обработчик
+func (b *Bot) HandleUpdate(update tgbotapi.Update) error {
+	return chain(b.route, b.middlewares...)(update)
 }
 
 func (b *Bot) Start() error {
@@ -43,30 +112,20 @@ func (b *Bot) Start() error {
 		return err
 	}
 
-	for update := range updates {
-		if update.Message != nil {
-			if err := b.handleMessageCommand(update.Message); err != nil {
-				log.Printf("Error handling command: %v", err)
+	handler := chain(b.route, b.middlewares...)
+	for {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				return nil
 			}
-		} else if update.CallbackQuery != nil {
-			if err := b.handleCallbackCommand(update.CallbackQuery); err != nil {
-				log.Printf("Error handling callback query: %v", err)
+			// Ошибки уже записаны в лог middleware Logging
+			_ = handler(update)
+		case r := <-b.recognized:
+			// Подсказки мастера меняют его состояние, поэтому обрабатываются здесь же, между обновлениями
+			if err := b.showRecognition(r); err != nil {
+				log.Printf("не удалось показать результат распознавания: %v", err)
 			}
 		}
 	}
-	return nil
-}
-
-type Cart struct {
-	Amount    decimal.Decimal
-	CartItems map[uint]CartItem
-}
-
-type CartItem struct {
-	MsgID      int
-	CountStore uint
-	CountCart  uint
-	Discount   uint
-	PriceStore decimal.Decimal
-	Price      decimal.Decimal
 }
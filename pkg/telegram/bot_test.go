@@ -0,0 +1,124 @@
+package telegram_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Bariban/vector-shop-bot/pkg/config"
+	"github.com/Bariban/vector-shop-bot/pkg/storage/postgres"
+	"github.com/Bariban/vector-shop-bot/pkg/telegram"
+	"github.com/Bariban/vector-shop-bot/pkg/telegram/telegramtest"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const testChatID = 42
+
+var testMessages = config.Messages{
+	Responses: config.Responses{
+		Start:          "start",
+		UnknownCommand: "unknown",
+		SendPhoto:      "Отправьте фото товара",
+	},
+	Errors: config.Errors{Default: "error"},
+}
+
+func textUpdate(text string) tgbotapi.Update {
+	message := &tgbotapi.Message{
+		MessageID: 1,
+		From:      &tgbotapi.User{ID: testChatID, UserName: "seller"},
+		Chat:      &tgbotapi.Chat{ID: testChatID, Type: "private", UserName: "seller"},
+		Text:      text,
+	}
+	if len(text) > 0 && text[0] == '/' {
+		message.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(text)}}
+	}
+	return tgbotapi.Update{Message: message}
+}
+
+func callbackUpdate(data string) tgbotapi.Update {
+	return tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   "cb-" + data,
+		From: &tgbotapi.User{ID: testChatID, UserName: "seller"},
+		Message: &tgbotapi.Message{
+			MessageID: 2,
+			Chat:      &tgbotapi.Chat{ID: testChatID, Type: "private", UserName: "seller"},
+		},
+		Data: data,
+	}}
+}
+
+// Обработчики в таблице не обращаются к базе и CLIP, поэтому бот собирается без них
+func TestHandleUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		updates []tgbotapi.Update
+		texts   []string
+		answers int
+	}{
+		{
+			name:    "menu",
+			updates: []tgbotapi.Update{textUpdate(telegram.MenuText)},
+			texts:   []string{"Меню"},
+		},
+		{
+			name:    "unknown text",
+			updates: []tgbotapi.Update{textUpdate("привет")},
+			texts:   []string{"unknown"},
+		},
+		{
+			name:    "unknown command",
+			updates: []tgbotapi.Update{textUpdate("/nope")},
+			texts:   []string{"unknown"},
+		},
+		{
+			name:    "add product asks for photo",
+			updates: []tgbotapi.Update{textUpdate(telegram.AddProductText)},
+			texts:   []string{"Отправьте фото товара"},
+		},
+		{
+			name: "cancel leaves the wizard",
+			updates: []tgbotapi.Update{
+				textUpdate(telegram.AddProductText),
+				textUpdate(telegram.CancelOperationsText),
+				textUpdate("Шампунь"),
+			},
+			texts: []string{"Отправьте фото товара", "unknown"},
+		},
+		{
+			name:    "callback is answered",
+			updates: []tgbotapi.Update{callbackUpdate(telegram.AddProductCmd)},
+			texts:   []string{"Отправьте фото товара"},
+			answers: 1,
+		},
+		{
+			name:    "unknown callback is answered",
+			updates: []tgbotapi.Update{callbackUpdate("nope")},
+			answers: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := telegramtest.NewRecordingMessenger()
+			bot := telegram.NewBot(m, &postgres.Storage{}, nil, nil, testMessages)
+			for _, update := range tt.updates {
+				if err := bot.HandleUpdate(update); err != nil {
+					t.Fatalf("HandleUpdate: %v", err)
+				}
+			}
+
+			if texts := m.Texts(); !reflect.DeepEqual(texts, tt.texts) {
+				t.Errorf("texts = %q, want %q", texts, tt.texts)
+			}
+			answers := 0
+			for _, c := range m.Calls() {
+				if c.Method == "answerCallbackQuery" {
+					answers++
+				}
+			}
+			if answers != tt.answers {
+				t.Errorf("answered %d callbacks, want %d", answers, tt.answers)
+			}
+		})
+	}
+}
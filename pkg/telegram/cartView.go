@@ -0,0 +1,250 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// maxTrackedMessages сколько последних подсказок продажи помнить в каждом чате,
+// старые сообщения Telegram всё равно не даёт удалить
+const maxTrackedMessages = 200
+
+// cartView сообщения бота, из которых состоит продажа в чате. Остальные сообщения
+// (запросы скидок, импорт, дубли) очистка чата не трогает
+type cartView struct {
+	summaryID int            // живое сообщение со сводкой корзины
+	sent      []int          // подсказки и вопросы продажи по порядку
+	cards     map[uint][]int // сообщения карточек товаров (фото и описание)
+}
+
+// trackingMessenger помнит по чатам идентификаторы сообщений продажи
+type trackingMessenger struct {
+	Messenger
+
+	mu    sync.Mutex
+	views map[int64]*cartView
+}
+
+func newTrackingMessenger(m Messenger) *trackingMessenger {
+	return &trackingMessenger{Messenger: m, views: make(map[int64]*cartView)}
+}
+
+// track запоминает подсказку продажи, которую удалит следующая очистка чата
+func (t *trackingMessenger) track(chatID int64, messageID int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v := t.view(chatID)
+	v.sent = append(v.sent, messageID)
+	if len(v.sent) > maxTrackedMessages {
+		v.sent = v.sent[len(v.sent)-maxTrackedMessages:]
+	}
+}
+
+func (t *trackingMessenger) Delete(chatID int64, messageID int) error {
+	t.mu.Lock()
+	v := t.view(chatID)
+	for i, id := range v.sent {
+		if id == messageID {
+			v.sent = append(v.sent[:i], v.sent[i+1:]...)
+			break
+		}
+	}
+	t.mu.Unlock()
+	return t.Messenger.Delete(chatID, messageID)
+}
+
+// view возвращает состояние чата, вызывать под t.mu
+func (t *trackingMessenger) view(chatID int64) *cartView {
+	v, ok := t.views[chatID]
+	if !ok {
+		v = &cartView{cards: make(map[uint][]int)}
+		t.views[chatID] = v
+	}
+	return v
+}
+
+// rememberCard связывает отправленные сообщения с карточкой товара
+func (t *trackingMessenger) rememberCard(chatID int64, productID uint, messageIDs ...int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	v := t.view(chatID)
+	v.cards[productID] = append(v.cards[productID], messageIDs...)
+}
+
+// cleanUp удаляет подсказки продажи и карточки товаров, которых нет в корзине
+func (t *trackingMessenger) cleanUp(chatID int64, inCart map[uint]bool) {
+	t.mu.Lock()
+	v := t.view(chatID)
+	keep := map[int]bool{v.summaryID: true}
+	for productID, ids := range v.cards {
+		if inCart[productID] {
+			for _, id := range ids {
+				keep[id] = true
+			}
+		}
+	}
+	var remove []int
+	for productID, ids := range v.cards {
+		if !inCart[productID] {
+			remove = append(remove, ids...)
+			delete(v.cards, productID)
+		}
+	}
+	remove = append(remove, v.sent...)
+	t.mu.Unlock()
+
+	removed := make(map[int]bool, len(remove))
+	for _, id := range remove {
+		if keep[id] || removed[id] {
+			continue
+		}
+		removed[id] = true
+		if err := t.Delete(chatID, id); err != nil {
+			log.Printf("Не удалось удалить сообщение %d: %v", id, err)
+		}
+	}
+}
+
+// forget сбрасывает состояние чата после оплаты или отмены
+func (t *trackingMessenger) forget(chatID int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.views, chatID)
+}
+
+// cardMsgID возвращает сообщение карточки товара с кнопками корзины
+func (t *trackingMessenger) cardMsgID(chatID int64, productID uint) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ids := t.view(chatID).cards[productID]
+	if len(ids) == 0 {
+		return 0
+	}
+	return ids[len(ids)-1]
+}
+
+func (t *trackingMessenger) summaryID(chatID int64) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.view(chatID).summaryID
+}
+
+func (t *trackingMessenger) setSummaryID(chatID int64, id int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.view(chatID).summaryID = id
+}
+
+// cartSummaryText формирует текст сводки корзины
+func (b *Bot) cartSummaryText(chatID int64) string {
+	c := b.cart(chatID)
+	lines := c.Lines()
+	if len(lines) == 0 {
+		return "🛍 Корзина пуста"
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🛍 Корзина\n")
+	for i, line := range lines {
+		discount := ""
+		if line.Pending != nil {
+			discount = " (⏳ скидка на подтверждении)"
+		} else if line.PriceOverride != nil {
+			discount = " (цена изменена)"
+		} else if line.Discount != 0 {
+			discount = fmt.Sprintf(" (−%d%%)", line.Discount)
+		}
+		fmt.Fprintf(&sb, "%d. %s — %s × %s%s = %s\n",
+			i+1, line.Name, lineQuantity(line), line.Price().StringFixed(cart.Places), discount, line.Total().StringFixed(cart.Places))
+	}
+	if d := c.OrderDiscount(); !d.IsZero() {
+		fmt.Fprintf(&sb, "\nСумма: %s", c.Subtotal().StringFixed(cart.Places))
+		label := "Скидка на чек"
+		if d.PromoCode != "" {
+			label = "Промокод " + d.PromoCode
+		}
+		fmt.Fprintf(&sb, "\n%s (%s): −%s", label, describeDiscount(d.Percent, d.Amount), c.OrderDiscountSum().StringFixed(cart.Places))
+	}
+	if d, ok := c.PendingOrderDiscount(); ok {
+		fmt.Fprintf(&sb, "\n⏳ Скидка на чек %s на подтверждении", describeDiscount(d.Percent, d.Amount))
+	}
+	if d := c.DiscountTotal(); d.IsPositive() {
+		fmt.Fprintf(&sb, "\nСкидка: %s", d.StringFixed(cart.Places))
+	}
+	fmt.Fprintf(&sb, "\nИтого: %s", c.Total().StringFixed(cart.Places))
+	return sb.String()
+}
+
+// getCartSummaryKeyboard возвращает кнопки под сводкой корзины
+func (b *Bot) getCartSummaryKeyboard() tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🏷 Скидка на чек", OrderDiscountCmd),
+			tgbotapi.NewInlineKeyboardButtonData("🎟 Промокод", PromoCodeCmd),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("💳 Оплата", PaymentCmd),
+			tgbotapi.NewInlineKeyboardButtonData("✖️ Отмена", CancelCartCmd),
+		),
+	)
+}
+
+// renderCart отправляет или обновляет на месте сводку корзины
+func (b *Bot) renderCart(chatID int64) error {
+	text := b.cartSummaryText(chatID)
+	keyboard := b.getCartSummaryKeyboard()
+
+	if id := b.tracker.summaryID(chatID); id != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, id, text)
+		edit.ReplyMarkup = &keyboard
+		_, err := b.bot.Send(edit)
+		if err == nil || strings.Contains(err.Error(), "message is not modified") {
+			return nil
+		}
+		log.Printf("не удалось обновить сводку корзины %d: %v", id, err)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	sent, err := b.bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	b.tracker.setSummaryID(chatID, sent.MessageID)
+	return nil
+}
+
+// sendCartPrompt отправляет подсказку продажи, которую уберёт очистка чата
+func (b *Bot) sendCartPrompt(chatID int64, text string) {
+	sent, err := b.bot.Send(tgbotapi.NewMessage(chatID, text))
+	if err != nil {
+		log.Printf("не удалось отправить подсказку: %v", err)
+		return
+	}
+	b.tracker.track(chatID, sent.MessageID)
+}
+
+// cleanUpMessages удаляет лишние сообщения бота в чате продажи
+func (b *Bot) cleanUpMessages(chatID int64) {
+	inCart := make(map[uint]bool)
+	for _, line := range b.cart(chatID).Lines() {
+		inCart[line.ProductID] = true
+	}
+	b.tracker.cleanUp(chatID, inCart)
+}
+
+// clearCartView удаляет сообщения продажи вместе со сводкой корзины
+func (b *Bot) clearCartView(chatID int64) {
+	b.tracker.cleanUp(chatID, nil)
+	if id := b.tracker.summaryID(chatID); id != 0 {
+		if err := b.bot.Delete(chatID, id); err != nil {
+			log.Printf("Не удалось удалить сводку корзины %d: %v", id, err)
+		}
+	}
+	b.tracker.forget(chatID)
+}
@@ -0,0 +1,341 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	// catalogPageSize товаров на странице каталога
+	catalogPageSize = 8
+	// lowStockThreshold остаток, при котором товар попадает в фильтр «мало»
+	lowStockThreshold = 3
+)
+
+// catalogState параметры каталога, которые пользователь выбрал в чате
+type catalogState struct {
+	query    storage.ProductQuery
+	page     int
+	category string // путь выбранной категории для заголовка
+}
+
+var catalogSorts = []struct{ key, title string }{
+	{storage.SortByName, "По имени"},
+	{storage.SortByStock, "По остатку"},
+	{storage.SortByPrice, "По цене"},
+}
+
+var catalogFilters = []struct{ key, title string }{
+	{"", "Все"},
+	{storage.FilterLowStock, "Мало"},
+	{storage.FilterOutOfStock, "Нет в наличии"},
+}
+
+// registerCatalogHandlers добавляет кнопки сортировки и фильтров каталога
+func (b *Bot) registerCatalogHandlers() {
+	for _, s := range catalogSorts {
+		sort := s.key
+		b.callbackHandlers[CatalogSortCmd+"_"+sort] = onCallback(func(callback *tgbotapi.CallbackQuery) error {
+			c := b.catalog(callback.Message.Chat.ID, callback.From.UserName)
+			c.query.Sort, c.page = sort, 0
+			return b.renderCatalog(callback.Message.Chat.ID, callback.Message.MessageID)
+		})
+	}
+	for _, f := range catalogFilters {
+		filter := f.key
+		b.callbackHandlers[CatalogFilterCmd+"_"+filter] = onCallback(func(callback *tgbotapi.CallbackQuery) error {
+			c := b.catalog(callback.Message.Chat.ID, callback.From.UserName)
+			c.query.Filter, c.page = filter, 0
+			return b.renderCatalog(callback.Message.Chat.ID, callback.Message.MessageID)
+		})
+	}
+}
+
+// catalog возвращает состояние каталога чата, создавая его при необходимости
+func (b *Bot) catalog(chatID int64, userName string) *catalogState {
+	c, ok := b.catalogs[chatID]
+	if !ok {
+		c = &catalogState{query: storage.ProductQuery{Sort: storage.SortByName}}
+		b.catalogs[chatID] = c
+	}
+	c.query.UserName = userName
+	return c
+}
+
+// handleProductList показывает первую страницу каталога новым сообщением
+func (b *Bot) handleProductList(callback *tgbotapi.CallbackQuery) error {
+	c := b.catalog(callback.Message.Chat.ID, callback.From.UserName)
+	c.page = 0
+	return b.renderCatalog(callback.Message.Chat.ID, 0)
+}
+
+// handleCatalogPage листает каталог
+func (b *Bot) handleCatalogPage(callback *tgbotapi.CallbackQuery) error {
+	var page int
+	fmt.Sscanf(callback.Data[len(CatalogPageCmd)+1:], "%d", &page)
+	c := b.catalog(callback.Message.Chat.ID, callback.From.UserName)
+	c.page = page
+	return b.renderCatalog(callback.Message.Chat.ID, callback.Message.MessageID)
+}
+
+// handleCatalogSearch запрашивает текст поиска и показывает найденное
+func (b *Bot) handleCatalogSearch(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	if b.states[chatID] != stateCatalogSearch {
+		b.states[chatID] = stateCatalogSearch
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Введите название или часть описания товара:"))
+		return err
+	}
+
+	delete(b.states, chatID)
+	c := b.catalog(chatID, message.From.UserName)
+	c.query.Text, c.page = strings.TrimSpace(message.Text), 0
+	return b.renderCatalog(chatID, 0)
+}
+
+// handleCatalogReset сбрасывает поиск и фильтр
+func (b *Bot) handleCatalogReset(callback *tgbotapi.CallbackQuery) error {
+	c := b.catalog(callback.Message.Chat.ID, callback.From.UserName)
+	c.query.Text, c.query.Filter, c.query.Category, c.category, c.page = "", "", 0, "", 0
+	return b.renderCatalog(callback.Message.Chat.ID, callback.Message.MessageID)
+}
+
+// handleCatalogCategories показывает под каталогом выбор категории
+func (b *Bot) handleCatalogCategories(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	tree, err := b.categories(callback.From.UserName)
+	if err != nil {
+		return err
+	}
+	if len(tree.list) == 0 {
+		b.alert(callback, "Категорий пока нет. Создать: /category Уход / Волосы")
+		return nil
+	}
+
+	rows := [][]tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Все категории", fmt.Sprintf("%s_%d", CatalogCategoryCmd, 0))),
+	}
+	for _, c := range tree.list {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			strings.Repeat("  ", tree.depth[c.ID])+"📂 "+c.Name, fmt.Sprintf("%s_%d", CatalogCategoryCmd, c.ID))))
+	}
+	return b.bot.EditMarkup(chatID, callback.Message.MessageID, tgbotapi.NewInlineKeyboardMarkup(rows...))
+}
+
+// handleCatalogCategory показывает товары категории вместе с подкатегориями
+func (b *Bot) handleCatalogCategory(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	data := callback.Data
+	categoryID, _ := strconv.Atoi(data[strings.LastIndex(data, "_")+1:])
+
+	c := b.catalog(chatID, callback.From.UserName)
+	c.query.Category, c.category, c.page = 0, "", 0
+	if categoryID != 0 {
+		tree, err := b.categories(callback.From.UserName)
+		if err != nil {
+			return err
+		}
+		if _, ok := tree.byID[uint(categoryID)]; ok {
+			c.query.Category, c.category = uint(categoryID), tree.paths[uint(categoryID)]
+		}
+	}
+	return b.renderCatalog(chatID, callback.Message.MessageID)
+}
+
+// handleCatalogItem показывает карточку товара с фото по запросу
+func (b *Bot) handleCatalogItem(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	product, err := b.storage.GetProductByID(context.Background(), b.tempProduct[chatID].ProductID)
+	if err != nil || product == nil {
+		b.alert(callback, "Товар не найден")
+		return nil
+	}
+	return b.showProductCard(chatID, product)
+}
+
+// showProductCard отправляет превью фото и карточку товара с действиями
+func (b *Bot) showProductCard(chatID int64, product *storage.Product) error {
+	images, err := b.storage.GetImages(context.Background(), product.ProductID)
+	if err != nil {
+		return err
+	}
+	for _, image := range images {
+		if _, err := b.sendImage(chatID, image, true, "", nil); err != nil {
+			log.Printf("не удалось отправить фото: %v", err)
+		}
+	}
+
+	text := productCardText(product)
+	variants, err := b.storage.GetVariants(context.Background(), product.ProductID)
+	if err != nil {
+		return err
+	}
+	if len(variants) > 0 {
+		text += "\n🎨 Варианты:\n" + variantLines(variants)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "Markdown"
+	msg.ReplyMarkup = b.getProductActionKeyboard(product.ProductID)
+	sent, err := b.bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	b.tempMsgID[chatID] = sent.MessageID
+	return nil
+}
+
+// productCardText текст карточки товара
+func productCardText(product *storage.Product) string {
+	text := fmt.Sprintf(
+		"🛒 *%s*\n📦 Наличие: %s\n💰 Цена продажи: %s\n",
+		product.Name,
+		formatQuantity(product.Count, product.Unit),
+		product.SellingPrice.StringFixed(cart.Places),
+	)
+	if product.Barcode != "" {
+		text += fmt.Sprintf("🏷 Штрихкод: %s\n", product.Barcode)
+	}
+	return text
+}
+
+// renderCatalog показывает страницу каталога: редактирует сообщение msgID или отправляет новое
+func (b *Bot) renderCatalog(chatID int64, msgID int) error {
+	c := b.catalogs[chatID]
+	query := c.query
+	query.LowStock = lowStockThreshold
+	query.Limit = catalogPageSize
+	query.Offset = c.page * catalogPageSize
+
+	products, total, err := b.storage.SearchProducts(context.Background(), query)
+	if err != nil {
+		return err
+	}
+
+	pages := (total + catalogPageSize - 1) / catalogPageSize
+	if pages == 0 {
+		pages = 1
+	}
+	if c.page >= pages {
+		c.page = pages - 1
+		return b.renderCatalog(chatID, msgID)
+	}
+
+	text := catalogText(query, c.category, products, total, c.page, pages)
+	keyboard := b.getCatalogKeyboard(c, products, pages)
+
+	if msgID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+		edit.ReplyMarkup = &keyboard
+		_, err := b.bot.Send(edit)
+		if err == nil || strings.Contains(err.Error(), "message is not modified") {
+			return nil
+		}
+		log.Printf("не удалось обновить каталог %d: %v", msgID, err)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	_, err = b.bot.Send(msg)
+	return err
+}
+
+func catalogText(query storage.ProductQuery, category string, products []*storage.Product, total, page, pages int) string {
+	var sb strings.Builder
+	sb.WriteString("📋 Каталог")
+	if category != "" {
+		fmt.Fprintf(&sb, " · 📂 %s", category)
+	}
+	if query.Text != "" {
+		fmt.Fprintf(&sb, " · 🔎 «%s»", query.Text)
+	}
+	for _, f := range catalogFilters {
+		if f.key != "" && f.key == query.Filter {
+			fmt.Fprintf(&sb, " · %s", f.title)
+		}
+	}
+	fmt.Fprintf(&sb, "\nНайдено: %d, страница %d из %d\n", total, page+1, pages)
+
+	if len(products) == 0 {
+		sb.WriteString("\nНичего не найдено.")
+		return sb.String()
+	}
+
+	for i, p := range products {
+		stock := formatQuantity(p.Count, p.Unit)
+		if !p.Count.IsPositive() {
+			stock = "нет в наличии"
+		}
+		fmt.Fprintf(&sb, "\n%d. %s — %s · %s", query.Offset+i+1, p.Name, p.SellingPrice.StringFixed(cart.Places), stock)
+	}
+	return sb.String()
+}
+
+// getCatalogKeyboard кнопки товаров страницы, навигации, сортировки и фильтров
+func (b *Bot) getCatalogKeyboard(c *catalogState, products []*storage.Product, pages int) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	// Кнопки товаров по четыре в ряд, номер совпадает с номером в списке
+	var row []tgbotapi.InlineKeyboardButton
+	for i, p := range products {
+		label := fmt.Sprintf("🔍 %d", c.page*catalogPageSize+i+1)
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s_%d", CatalogItemCmd, p.ProductID)))
+		if len(row) == 4 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	if pages > 1 {
+		var nav []tgbotapi.InlineKeyboardButton
+		if c.page > 0 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", fmt.Sprintf("%s_%d", CatalogPageCmd, c.page-1)))
+		}
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("%d/%d", c.page+1, pages), fmt.Sprintf("%s_%d", CatalogPageCmd, c.page)))
+		if c.page < pages-1 {
+			nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", fmt.Sprintf("%s_%d", CatalogPageCmd, c.page+1)))
+		}
+		rows = append(rows, nav)
+	}
+
+	var sorts []tgbotapi.InlineKeyboardButton
+	for _, s := range catalogSorts {
+		title := s.title
+		if s.key == c.query.Sort {
+			title = "✅ " + title
+		}
+		sorts = append(sorts, tgbotapi.NewInlineKeyboardButtonData(title, CatalogSortCmd+"_"+s.key))
+	}
+	rows = append(rows, sorts)
+
+	var filters []tgbotapi.InlineKeyboardButton
+	for _, f := range catalogFilters {
+		title := f.title
+		if f.key == c.query.Filter {
+			title = "✅ " + title
+		}
+		filters = append(filters, tgbotapi.NewInlineKeyboardButtonData(title, CatalogFilterCmd+"_"+f.key))
+	}
+	rows = append(rows, filters)
+
+	search := []tgbotapi.InlineKeyboardButton{
+		tgbotapi.NewInlineKeyboardButtonData("🔎 Поиск", CatalogSearchCmd),
+		tgbotapi.NewInlineKeyboardButtonData("📂 Категории", CatalogCategoriesCmd),
+	}
+	if c.query.Text != "" || c.query.Filter != "" || c.query.Category != 0 {
+		search = append(search, tgbotapi.NewInlineKeyboardButtonData("✖️ Сбросить", CatalogResetCmd))
+	}
+	rows = append(rows, search)
+
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
@@ -0,0 +1,467 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/shopspring/decimal"
+)
+
+// categoryTree категории пользователя с путями вида «Уход / Волосы»
+type categoryTree struct {
+	list  []*storage.Category // родители раньше детей
+	byID  map[uint]*storage.Category
+	paths map[uint]string
+	depth map[uint]int
+}
+
+func newCategoryTree(categories []*storage.Category) *categoryTree {
+	t := &categoryTree{
+		list:  categories,
+		byID:  make(map[uint]*storage.Category, len(categories)),
+		paths: make(map[uint]string, len(categories)),
+		depth: make(map[uint]int, len(categories)),
+	}
+	for _, c := range categories {
+		t.byID[c.ID] = c
+		if parent, ok := t.byID[c.ParentID]; ok {
+			t.paths[c.ID] = t.paths[parent.ID] + " / " + c.Name
+			t.depth[c.ID] = t.depth[parent.ID] + 1
+		} else {
+			t.paths[c.ID] = c.Name
+		}
+	}
+	return t
+}
+
+// find ищет категорию по пути без учёта регистра
+func (t *categoryTree) find(path []string) *storage.Category {
+	var parentID uint
+	var found *storage.Category
+	for _, name := range path {
+		found = nil
+		for _, c := range t.list {
+			if c.ParentID == parentID && strings.EqualFold(c.Name, name) {
+				found = c
+				break
+			}
+		}
+		if found == nil {
+			return nil
+		}
+		parentID = found.ID
+	}
+	return found
+}
+
+// suggest выбирает категорию по ответу распознавания: сначала лучшую, затем остальные по убыванию сходства.
+// Если ни одна не заведена, возвращает название лучшей, чтобы предложить её создать
+func (t *categoryTree) suggest(image *storage.ImageMeta) (uint, string) {
+	if image == nil {
+		return 0, ""
+	}
+	names := make([]string, 0, len(image.Similarities)+1)
+	if image.Category != "" {
+		names = append(names, image.Category)
+	}
+	var rest []string
+	for name := range image.Similarities {
+		rest = append(rest, name)
+	}
+	sort.Slice(rest, func(i, j int) bool { return image.Similarities[rest[i]] > image.Similarities[rest[j]] })
+	names = append(names, rest...)
+
+	for _, name := range names {
+		for _, c := range t.list {
+			if strings.EqualFold(c.Name, name) {
+				return c.ID, ""
+			}
+		}
+	}
+	return 0, image.Category
+}
+
+// parseCategoryPath разбирает путь «Уход / Волосы» на названия
+func parseCategoryPath(text string) []string {
+	var path []string
+	for _, name := range strings.Split(text, "/") {
+		if name = strings.TrimSpace(name); name != "" {
+			path = append(path, name)
+		}
+	}
+	return path
+}
+
+// categories загружает дерево категорий пользователя
+func (b *Bot) categories(userName string) (*categoryTree, error) {
+	categories, err := b.storage.GetCategories(context.Background(), userName)
+	if err != nil {
+		return nil, err
+	}
+	return newCategoryTree(categories), nil
+}
+
+// ensureCategory находит категорию по пути, создавая недостающие уровни
+func (b *Bot) ensureCategory(userName string, path []string) (uint, error) {
+	var parentID uint
+	for _, name := range path {
+		id, err := b.storage.CreateCategory(context.Background(), &storage.Category{
+			UserName: userName,
+			ParentID: parentID,
+			Name:     name,
+		})
+		if err != nil {
+			return 0, err
+		}
+		parentID = id
+	}
+	return parentID, nil
+}
+
+// getCategoryPickKeyboard кнопки выбора категории товара, предложенная распознаванием идёт первой
+func getCategoryPickKeyboard(tree *categoryTree, suggestedID uint, suggestedName string) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if suggestedID != 0 {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			"✨ "+tree.paths[suggestedID], fmt.Sprintf("%s_%d", PickCategoryCmd, suggestedID))))
+	} else if suggestedName != "" {
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData(
+			fmt.Sprintf("✨ Создать «%s»", suggestedName), PickSuggestedCategoryCmd)))
+	}
+
+	var row []tgbotapi.InlineKeyboardButton
+	for _, c := range tree.list {
+		if c.ID == suggestedID {
+			continue
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(tree.paths[c.ID], fmt.Sprintf("%s_%d", PickCategoryCmd, c.ID)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("Без категории", fmt.Sprintf("%s_%d", PickCategoryCmd, 0))))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// askWizardCategory предлагает выбрать категорию нового товара
+func (b *Bot) askWizardCategory(chatID int64, product *storage.Product) error {
+	tree, err := b.categories(product.UserName)
+	if err != nil {
+		return err
+	}
+
+	var image *storage.ImageMeta
+	if len(product.Image) > 0 {
+		image = product.Image[0]
+	}
+	suggestedID, suggestedName := tree.suggest(image)
+
+	text := "Выберите категорию или отправьте путь новой, например: Уход / Волосы. «-» — без категории."
+	if suggestedID != 0 {
+		text = fmt.Sprintf("Похоже на категорию «%s».\n%s", tree.paths[suggestedID], text)
+	} else if suggestedName != "" {
+		text = fmt.Sprintf("Похоже на «%s», такой категории пока нет.\n%s", suggestedName, text)
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = getCategoryPickKeyboard(tree, suggestedID, suggestedName)
+	_, err = b.bot.Send(msg)
+	return err
+}
+
+// setWizardCategory запоминает категорию нового товара и переходит к количеству
+func (b *Bot) setWizardCategory(chatID int64, product *storage.Product, categoryID uint) error {
+	product.CategoryID = categoryID
+	b.states[chatID] = stateWaitingForCount
+	_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Введите количество товара, например 5, 2,5 кг или 10 м:"))
+	return err
+}
+
+// handleWizardCategoryText принимает путь категории текстом на шаге мастера
+func (b *Bot) handleWizardCategoryText(message *tgbotapi.Message, product *storage.Product) error {
+	text := strings.TrimSpace(message.Text)
+	if text == "-" {
+		return b.setWizardCategory(message.Chat.ID, product, 0)
+	}
+	path := parseCategoryPath(text)
+	if len(path) == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(message.Chat.ID, "Введите название категории или «-»."))
+		return err
+	}
+	id, err := b.ensureCategory(product.UserName, path)
+	if err != nil {
+		return err
+	}
+	return b.setWizardCategory(message.Chat.ID, product, id)
+}
+
+// handlePickCategory выбирает категорию кнопкой: в мастере добавления или для существующего товара
+func (b *Bot) handlePickCategory(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	data := callback.Data
+	categoryID, _ := strconv.Atoi(data[strings.LastIndex(data, "_")+1:])
+	product := b.tempProduct[chatID]
+	if product == nil {
+		b.alert(callback, "Товар не выбран")
+		return nil
+	}
+
+	if categoryID != 0 {
+		tree, err := b.categories(callback.From.UserName)
+		if err != nil {
+			return err
+		}
+		if _, ok := tree.byID[uint(categoryID)]; !ok {
+			b.alert(callback, "Категория не найдена")
+			return nil
+		}
+	}
+
+	if b.states[chatID] == stateWaitingForCategory {
+		b.bot.EditMarkup(chatID, callback.Message.MessageID, tgbotapi.NewInlineKeyboardMarkup())
+		return b.setWizardCategory(chatID, product, uint(categoryID))
+	}
+
+	if product.ProductID == 0 {
+		b.alert(callback, "Товар не выбран")
+		return nil
+	}
+	if err := b.storage.SetProductCategory(context.Background(), product.ProductID, uint(categoryID)); err != nil {
+		return err
+	}
+	b.toast(callback, "Категория изменена")
+	return b.bot.EditMarkup(chatID, callback.Message.MessageID, b.getProductActionKeyboard(product.ProductID))
+}
+
+// handlePickSuggestedCategory создаёт категорию, предложенную распознаванием, и выбирает её
+func (b *Bot) handlePickSuggestedCategory(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	product := b.tempProduct[chatID]
+	if b.states[chatID] != stateWaitingForCategory || product == nil || len(product.Image) == 0 || product.Image[0].Category == "" {
+		b.alert(callback, "Предложение устарело")
+		return nil
+	}
+
+	id, err := b.ensureCategory(product.UserName, []string{product.Image[0].Category})
+	if err != nil {
+		return err
+	}
+	b.bot.EditMarkup(chatID, callback.Message.MessageID, tgbotapi.NewInlineKeyboardMarkup())
+	return b.setWizardCategory(chatID, product, id)
+}
+
+// handleEditCategory показывает выбор категории под карточкой товара
+func (b *Bot) handleEditCategory(callback *tgbotapi.CallbackQuery) error {
+	tree, err := b.categories(callback.From.UserName)
+	if err != nil {
+		return err
+	}
+	if len(tree.list) == 0 {
+		b.alert(callback, "Категорий пока нет. Создать: /category Уход / Волосы")
+		return nil
+	}
+	keyboard := getCategoryPickKeyboard(tree, 0, "")
+	return b.bot.EditMarkup(callback.Message.Chat.ID, callback.Message.MessageID, keyboard)
+}
+
+// handleCreateCategory создаёт категорию по пути: /category Уход / Волосы
+func (b *Bot) handleCreateCategory(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	path := parseCategoryPath(message.CommandArguments())
+	if len(path) == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Формат: /category Родитель / Категория"))
+		return err
+	}
+
+	if _, err := b.ensureCategory(message.From.UserName, path); err != nil {
+		return err
+	}
+	_, err := b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📂 Категория «%s» создана", strings.Join(path, " / "))))
+	return err
+}
+
+// handleRemoveCategory удаляет категорию с подкатегориями: /del_category Уход / Волосы
+func (b *Bot) handleRemoveCategory(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	path := parseCategoryPath(message.CommandArguments())
+	if len(path) == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Формат: /del_category Родитель / Категория"))
+		return err
+	}
+
+	tree, err := b.categories(message.From.UserName)
+	if err != nil {
+		return err
+	}
+	category := tree.find(path)
+	if category == nil {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Категория не найдена"))
+		return err
+	}
+
+	if err := b.storage.RemoveCategory(context.Background(), category.ID); err != nil {
+		return err
+	}
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"Категория «%s» удалена, её товары остались без категории", tree.paths[category.ID])))
+	return err
+}
+
+// handleCategoryList показывает дерево категорий
+func (b *Bot) handleCategoryList(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	tree, err := b.categories(message.From.UserName)
+	if err != nil {
+		return err
+	}
+	if len(tree.list) == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Категорий нет. Создать: /category Уход / Волосы"))
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("📂 Категории\n")
+	for _, c := range tree.list {
+		fmt.Fprintf(&sb, "%s• %s\n", strings.Repeat("    ", tree.depth[c.ID]), c.Name)
+	}
+	sb.WriteString("\nДобавить: /category Родитель / Категория\nУдалить: /del_category Родитель / Категория")
+
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, sb.String()))
+	return err
+}
+
+// handleSalesReport показывает продажи по категориям: /sales [с ГГГГ-ММ-ДД] [по ГГГГ-ММ-ДД].
+// По умолчанию — с начала текущего месяца. Подкатегории входят в итог родителя
+func (b *Bot) handleSalesReport(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	from, to, err := parsePeriod(strings.Fields(message.CommandArguments()))
+	if err != nil {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Формат: /sales [ГГГГ-ММ-ДД] [ГГГГ-ММ-ДД]"))
+		return err
+	}
+
+	userName := message.From.UserName
+	sales, err := b.storage.GetCategorySales(context.Background(), userName, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	tree, err := b.categories(userName)
+	if err != nil {
+		return err
+	}
+
+	// Продажи подкатегорий прибавляются ко всем предкам
+	totals := make(map[uint]*salesTotal)
+	all := newSalesTotal()
+	for _, s := range sales {
+		all.add(s)
+
+		id := s.CategoryID
+		if _, ok := tree.byID[id]; !ok {
+			id = 0 // категория удалена
+		}
+		for {
+			t, ok := totals[id]
+			if !ok {
+				t = newSalesTotal()
+				totals[id] = t
+			}
+			t.add(s)
+			c, ok := tree.byID[id]
+			if !ok || c.ParentID == 0 {
+				break
+			}
+			id = c.ParentID
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📊 Продажи по категориям, %s — %s\n", from.Format(dateLayout), to.Format(dateLayout))
+	if len(sales) == 0 {
+		sb.WriteString("\nПродаж нет.")
+	}
+	for _, c := range tree.list {
+		if t, ok := totals[c.ID]; ok {
+			fmt.Fprintf(&sb, "\n%s📂 %s — %s", strings.Repeat("    ", tree.depth[c.ID]), c.Name, t)
+		}
+	}
+	if t, ok := totals[0]; ok {
+		fmt.Fprintf(&sb, "\nБез категории — %s", t)
+	}
+	if len(sales) > 0 {
+		fmt.Fprintf(&sb, "\n\nВсего: %s\nСуммы по строкам чеков без скидки на чек.", all)
+	}
+
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, sb.String()))
+	return err
+}
+
+// parsePeriod разбирает период отчёта «[с] [по]» включительно.
+// По умолчанию — с начала текущего месяца по сегодня
+func parsePeriod(args []string) (time.Time, time.Time, error) {
+	now := time.Now()
+	from := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.Local)
+	to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	dates := []*time.Time{&from, &to}
+	if len(args) > len(dates) {
+		return from, to, fmt.Errorf("слишком много дат: %d", len(args))
+	}
+	for i, arg := range args {
+		date, err := time.ParseInLocation(dateLayout, arg, time.Local)
+		if err != nil {
+			return from, to, err
+		}
+		*dates[i] = date
+	}
+	return from, to, nil
+}
+
+// salesTotal продажи группы товаров: количество отдельно по каждой единице измерения
+type salesTotal struct {
+	quantities map[string]decimal.Decimal
+	revenue    decimal.Decimal
+}
+
+func newSalesTotal() *salesTotal {
+	return &salesTotal{quantities: make(map[string]decimal.Decimal), revenue: decimal.Zero}
+}
+
+func (t *salesTotal) add(s *storage.CategorySales) {
+	t.quantities[s.Unit] = t.quantities[s.Unit].Add(s.Quantity)
+	t.revenue = t.revenue.Add(s.Revenue)
+}
+
+// String «3 шт., 12.5 м, 4500.00»: штуки первыми, затем остальные единицы по алфавиту
+func (t *salesTotal) String() string {
+	units := make([]string, 0, len(t.quantities))
+	for unit := range t.quantities {
+		units = append(units, unit)
+	}
+	sort.Slice(units, func(i, j int) bool {
+		if (units[i] == storage.UnitPiece) != (units[j] == storage.UnitPiece) {
+			return units[i] == storage.UnitPiece
+		}
+		return units[i] < units[j]
+	})
+
+	parts := make([]string, 0, len(units)+1)
+	for _, unit := range units {
+		parts = append(parts, formatQuantity(t.quantities[unit], unit))
+	}
+	parts = append(parts, t.revenue.StringFixed(cart.Places))
+	return strings.Join(parts, ", ")
+}
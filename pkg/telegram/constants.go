@@ -15,6 +15,25 @@ const (
 	ListUsersCmd  = "/list_users"
 )
 
+const (
+	PromoCmd  = "/promo"
+	PromosCmd = "/promos"
+)
+
+const (
+	ImportCmd        = "/import"
+	ExportCatalogCmd = "/export_catalog"
+	ExportOrdersCmd  = "/export_orders"
+	ReindexCmd       = "/reindex"
+	DuplicatesCmd    = "/duplicates"
+)
+
+const (
+	CategoryCmd    = "/category"
+	DelCategoryCmd = "/del_category"
+	CategoriesCmd  = "/categories"
+)
+
 const (
 	EditProductNameCmd     = "edit_product_name"
 	EditProductCountCmd    = "edit_product_count"
@@ -32,11 +51,63 @@ const (
 	RemoveItemFromCartCmd  = "remove_item_from_cart"
 	EditCountItemInCartCmd = "edit_count_item_in_cart"
 	DiscountItemInCartCmd  = "discount_item_in_cart"
+	PhotosProductCmd       = "photos_product"
+	AddPhotoCmd            = "add_photo"
+	DelPhotoCmd            = "del_photo"
+	EditCategoryCmd        = "edit_category"
+)
+
+const (
+	VariantsCmd    = "variants"
+	AddVariantCmd  = "add_variant"
+	DelVariantCmd  = "del_variant"
+	PickVariantCmd = "pick_variant"
+)
+
+const (
+	PickCategoryCmd          = "pick_category"
+	PickSuggestedCategoryCmd = "pick_suggested_category"
+)
+
+const (
+	CatalogPageCmd   = "catalog_page"
+	CatalogSortCmd   = "catalog_sort"
+	CatalogFilterCmd = "catalog_filter"
+	CatalogSearchCmd = "catalog_search"
+	CatalogResetCmd  = "catalog_reset"
+	CatalogItemCmd   = "catalog_item"
+
+	CatalogCategoriesCmd = "catalog_categories"
+	CatalogCategoryCmd   = "catalog_category"
+)
+
+const (
+	InlineAddCmd = "inline_add"
+)
+
+const (
+	ImportConfirmCmd = "import_confirm"
+	ImportCancelCmd  = "import_cancel"
+)
+
+const (
+	MergeKeepFirstCmd  = "merge_keep_first"
+	MergeKeepSecondCmd = "merge_keep_second"
+	MergeSkipCmd       = "merge_skip"
+)
+
+const (
+	PaymentCmd       = "payment"
+	CancelCartCmd    = "cancel_cart"
+	OrderDiscountCmd = "order_discount"
+	PromoCodeCmd     = "promo_code"
+	PayTypeCashCmd   = "pay_type_cash"
+	PayTypeKaspiCmd  = "pay_type_kaspi"
 )
 
 const (
-	PayTypeCashCmd  = "pay_type_cash"
-	PayTypeKaspiCmd = "pay_type_kaspi"
+	ApproveDiscountCmd = "approve_discount"
+	RejectDiscountCmd  = "reject_discount"
 )
 
 const (
@@ -59,12 +130,20 @@ const (
 const (
 	stateEditCountItemInCart   = 12
 	stateDiscountProductInCart = 13
+	stateOrderDiscount         = 14
+	statePromoCode             = 15
+	stateWaitingForExtraPhoto  = 16
+	stateCatalogSearch         = 17
+	stateWaitingForCategory    = 18
+	stateWaitingForVariant     = 19
+	stateWaitingForImport      = 20
 )
 
 var addProductStates = map[int]bool{
 	stateWaitingForPhoto:         true,
 	stateWaitingForName:          true,
 	stateWaitingForDescription:   true,
+	stateWaitingForCategory:      true,
 	stateWaitingForCount:         true,
 	stateWaitingForPurchasePrice: true,
 	stateWaitingForSellingPrice:  true,
@@ -0,0 +1,236 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/shopspring/decimal"
+)
+
+// dateLayout формат дат в командах
+const dateLayout = "2006-01-02"
+
+// parseDiscount разбирает скидку: "10%" — проценты, "500" — сумма
+func parseDiscount(input string) (cart.OrderDiscount, error) {
+	input = strings.ReplaceAll(strings.TrimSpace(input), ",", ".")
+	if strings.HasSuffix(input, "%") {
+		percent, err := decimal.NewFromString(strings.TrimSpace(strings.TrimSuffix(input, "%")))
+		if err != nil {
+			return cart.OrderDiscount{}, err
+		}
+		return cart.OrderDiscount{Percent: percent}, nil
+	}
+	amount, err := decimal.NewFromString(input)
+	if err != nil {
+		return cart.OrderDiscount{}, err
+	}
+	return cart.OrderDiscount{Amount: amount}, nil
+}
+
+// describeDiscount возвращает скидку в виде "10%" или "500.00"
+func describeDiscount(percent, amount decimal.Decimal) string {
+	var parts []string
+	if percent.IsPositive() {
+		parts = append(parts, percent.String()+"%")
+	}
+	if amount.IsPositive() {
+		parts = append(parts, amount.StringFixed(cart.Places))
+	}
+	return strings.Join(parts, " + ")
+}
+
+// handleOrderDiscount запрашивает и применяет скидку на весь чек
+func (b *Bot) handleOrderDiscount(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	c := b.cart(chatID)
+
+	if c.IsEmpty() {
+		b.sendCartPrompt(chatID, "Корзина пуста")
+		return nil
+	}
+
+	if b.states[chatID] != stateOrderDiscount {
+		b.states[chatID] = stateOrderDiscount
+		b.sendCartPrompt(chatID, "Введите скидку на чек: 10% или сумму, 0 — убрать скидку:")
+		return nil
+	}
+
+	discount, err := parseDiscount(message.Text)
+	if err == nil {
+		err = c.SetPendingOrderDiscount(discount)
+	}
+	if err != nil {
+		b.sendCartPrompt(chatID, "Введите процент от 0 до 100 со знаком % или положительную сумму:")
+		return nil
+	}
+	delete(b.states, chatID)
+
+	// Скидка на чек, как и на строку, сверх лимита роли ждёт решения администратора
+	limit, shopID, err := b.maxDiscountFor(message.Chat.UserName)
+	if err != nil {
+		return err
+	}
+	if percent := c.OrderDiscountPercent(discount); percent > limit {
+		if err := b.requestOrderDiscountApproval(message, shopID, discount, percent, limit); err != nil {
+			return err
+		}
+		return b.renderCart(chatID)
+	}
+	if err := c.ResolvePendingOrderDiscount(true); err != nil {
+		return err
+	}
+	return b.renderCart(chatID)
+}
+
+// handlePromoCode запрашивает промокод и применяет его скидку к чеку
+func (b *Bot) handlePromoCode(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	c := b.cart(chatID)
+
+	if c.IsEmpty() {
+		b.sendCartPrompt(chatID, "Корзина пуста")
+		return nil
+	}
+
+	if b.states[chatID] != statePromoCode {
+		b.states[chatID] = statePromoCode
+		b.sendCartPrompt(chatID, "Введите промокод:")
+		return nil
+	}
+
+	code := strings.TrimSpace(message.Text)
+	promo, err := b.storage.GetPromoCode(context.Background(), message.Chat.UserName, code)
+	if errors.Is(err, storage.ErrPromoCodeUnavailable) || (err == nil && !promo.Available(time.Now())) {
+		delete(b.states, chatID)
+		b.sendCartPrompt(chatID, "Промокод не найден или больше не действует.")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = c.SetOrderDiscount(cart.OrderDiscount{Percent: promo.Percent, Amount: promo.Amount, PromoCode: promo.Code})
+	if err != nil {
+		return err
+	}
+
+	delete(b.states, chatID)
+	return b.renderCart(chatID)
+}
+
+// handleCreatePromoCode создаёт промокод:
+// /promo КОД 10% [с ГГГГ-ММ-ДД|-] [по ГГГГ-ММ-ДД|-] [лимит]
+func (b *Bot) handleCreatePromoCode(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	usage := "Формат: /promo КОД 10% [дата начала] [дата окончания] [лимит]\n" +
+		"Скидка — процент со знаком % или сумма, даты в формате ГГГГ-ММ-ДД, «-» — без ограничения.\n" +
+		"Например: /promo SUMMER 10% 2026-06-01 2026-08-31 100"
+
+	if ok, err := b.isShopAdmin(context.Background(), message.From.UserName); err != nil || !ok {
+		if err == nil {
+			_, err = b.bot.Send(tgbotapi.NewMessage(chatID, "Создавать промокоды может только администратор магазина."))
+		}
+		return err
+	}
+
+	args := strings.Fields(message.CommandArguments())
+	if len(args) < 2 || len(args) > 5 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return err
+	}
+
+	discount, err := parseDiscount(args[1])
+	if err != nil || discount.IsZero() || discount.Percent.IsNegative() || discount.Amount.IsNegative() ||
+		discount.Percent.GreaterThan(decimal.NewFromInt(100)) {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, usage))
+		return err
+	}
+
+	promo := &storage.PromoCode{
+		UserName: message.From.UserName,
+		Code:     strings.ToUpper(args[0]),
+		Percent:  discount.Percent,
+		Amount:   discount.Amount,
+	}
+
+	dates := []**time.Time{&promo.ValidFrom, &promo.ValidTo}
+	for i, arg := range args[2:] {
+		if i == 2 {
+			limit, err := strconv.ParseUint(arg, 10, 32)
+			if err != nil {
+				_, err := b.bot.Send(tgbotapi.NewMessage(chatID, usage))
+				return err
+			}
+			promo.UsageLimit = uint(limit)
+			continue
+		}
+		if arg == "-" {
+			continue
+		}
+		date, err := time.Parse(dateLayout, arg)
+		if err != nil {
+			_, err := b.bot.Send(tgbotapi.NewMessage(chatID, usage))
+			return err
+		}
+		*dates[i] = &date
+	}
+
+	if _, err := b.storage.CreatePromoCode(context.Background(), promo); err != nil {
+		b.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось создать промокод, возможно, такой код уже есть."))
+		return err
+	}
+
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Промокод %s создан: скидка %s",
+		promo.Code, describeDiscount(promo.Percent, promo.Amount))))
+	return err
+}
+
+// handlePromoCodeList показывает промокоды магазина и сколько раз они использованы
+func (b *Bot) handlePromoCodeList(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	codes, err := b.storage.GetPromoCodes(context.Background(), message.From.UserName)
+	if err != nil {
+		return err
+	}
+	if len(codes) == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Промокодов нет. Создать: /promo КОД 10%"))
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🎟 Промокоды\n")
+	now := time.Now()
+	for _, p := range codes {
+		status := "✅"
+		if !p.Available(now) {
+			status = "⛔️"
+		}
+		fmt.Fprintf(&sb, "%s %s — %s", status, p.Code, describeDiscount(p.Percent, p.Amount))
+		if p.ValidFrom != nil || p.ValidTo != nil {
+			from, to := "…", "…"
+			if p.ValidFrom != nil {
+				from = p.ValidFrom.Format(dateLayout)
+			}
+			if p.ValidTo != nil {
+				to = p.ValidTo.Format(dateLayout)
+			}
+			fmt.Fprintf(&sb, ", %s — %s", from, to)
+		}
+		if p.UsageLimit > 0 {
+			fmt.Fprintf(&sb, ", использован %d из %d", p.UsedCount, p.UsageLimit)
+		} else {
+			fmt.Fprintf(&sb, ", использован %d", p.UsedCount)
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, sb.String()))
+	return err
+}
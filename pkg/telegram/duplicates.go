@@ -0,0 +1,375 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/Bariban/vector-shop-bot/pkg/ann"
+	"github.com/Bariban/vector-shop-bot/pkg/barcode"
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	// duplicateNameSimilarity доля общих слов, с которой названия считаются одинаковыми
+	duplicateNameSimilarity = 0.75
+	// maxDuplicatePairs сколько самых вероятных пар показать за одну проверку
+	maxDuplicatePairs = 50
+)
+
+// duplicatePair два товара, похожие на один и тот же, и чем они похожи
+type duplicatePair struct {
+	first, second uint
+	barcode       string  // общий штрихкод товара или надписи на фото
+	distance      float64 // гибридное расстояние самых похожих фото, -1 — фото не похожи
+	name          float64 // сходство названий, 0 — названия разные
+}
+
+// reasons перечисляет, чем похожи товары пары
+func (p duplicatePair) reasons() string {
+	var reasons []string
+	if p.barcode != "" {
+		reasons = append(reasons, "штрихкод "+p.barcode)
+	}
+	if p.distance >= 0 {
+		reasons = append(reasons, fmt.Sprintf("фото (расстояние %.2f)", p.distance))
+	}
+	if p.name > 0 {
+		reasons = append(reasons, fmt.Sprintf("название (%.0f%% общих слов)", p.name*100))
+	}
+	return strings.Join(reasons, ", ")
+}
+
+// duplicateReview пары возможных дублей, которые администратор разбирает по одной
+type duplicateReview struct {
+	userName string
+	pairs    []duplicatePair
+	pos      int
+	merged   map[uint]uint // удалённый при объединении товар → товар, в который он вошёл
+	skipped  int
+}
+
+// resolve возвращает товар, в который вошёл productID после объединений
+func (r *duplicateReview) resolve(productID uint) uint {
+	for {
+		next, ok := r.merged[productID]
+		if !ok {
+			return productID
+		}
+		productID = next
+	}
+}
+
+// handleDuplicates ищет в каталоге товары, похожие на один и тот же: по фото, названию
+// и штрихкоду, и предлагает администратору объединить их по одной паре
+func (b *Bot) handleDuplicates(message *tgbotapi.Message) error {
+	chatID, userName := message.Chat.ID, message.Chat.UserName
+	ctx := context.Background()
+
+	if ok, err := b.isShopAdmin(ctx, message.From.UserName); err != nil || !ok {
+		if err == nil {
+			_, err = b.bot.Send(tgbotapi.NewMessage(chatID, "Объединять товары может только администратор магазина."))
+		}
+		return err
+	}
+
+	pairs, err := b.findDuplicates(ctx, userName)
+	if err != nil {
+		_, _ = b.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось проверить каталог на дубли."))
+		return err
+	}
+	if len(pairs) == 0 {
+		delete(b.duplicates, chatID)
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Похожих товаров не найдено."))
+		return err
+	}
+
+	b.duplicates[chatID] = &duplicateReview{userName: userName, pairs: pairs, merged: make(map[uint]uint)}
+	return b.showDuplicate(chatID, 0)
+}
+
+// findDuplicates возвращает пары возможных дублей: сначала с общим штрихкодом,
+// затем по близости фото и по сходству названий
+func (b *Bot) findDuplicates(ctx context.Context, userName string) ([]duplicatePair, error) {
+	products, err := b.storage.GetProducts(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+	items, index, err := b.duplicateItems(ctx, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	exists := make(map[uint]bool, len(products))
+	for _, p := range products {
+		exists[p.ProductID] = true
+	}
+	pairs := make(map[[2]uint]*duplicatePair)
+	pair := func(id1, id2 uint) *duplicatePair {
+		if id1 > id2 {
+			id1, id2 = id2, id1
+		}
+		key := [2]uint{id1, id2}
+		p, ok := pairs[key]
+		if !ok {
+			p = &duplicatePair{first: id1, second: id2, distance: -1}
+			pairs[key] = p
+		}
+		return p
+	}
+
+	// Штрихкод товара или напечатанный под штрихкодом на фото другого товара
+	codes := make(map[string]map[uint]bool)
+	addCode := func(code string, productID uint) {
+		if codes[code] == nil {
+			codes[code] = make(map[uint]bool)
+		}
+		codes[code][productID] = true
+	}
+	for _, p := range products {
+		if p.Barcode != "" {
+			addCode(p.Barcode, p.ProductID)
+		}
+	}
+	for _, item := range items {
+		if exists[item.ProductID] {
+			for _, code := range textBarcodes(item.Text) {
+				addCode(code, item.ProductID)
+			}
+		}
+	}
+	for code, ids := range codes {
+		list := make([]uint, 0, len(ids))
+		for id := range ids {
+			list = append(list, id)
+		}
+		for i := range list {
+			for j := i + 1; j < len(list); j++ {
+				pair(list[i], list[j]).barcode = code
+			}
+		}
+	}
+
+	// Фото разных товаров, которые поиск по фото принял бы за один товар
+	for i, item := range items {
+		if !exists[item.ProductID] {
+			continue
+		}
+		var near []ann.Result
+		if index != nil {
+			if near, err = index.Search(item.Vector, annCandidates); err != nil {
+				return nil, err
+			}
+		} else {
+			for _, other := range items[i+1:] {
+				if distance, err := b.metric.Distance(item.Vector, other.Vector); err == nil {
+					near = append(near, ann.Result{Item: other, Distance: distance})
+				}
+			}
+		}
+		for _, r := range near {
+			if r.ProductID == item.ProductID || !exists[r.ProductID] {
+				continue
+			}
+			score := b.metric.HybridDistance(r.Distance, item.Text, r.Text)
+			if score > b.matchThreshold {
+				continue
+			}
+			if p := pair(item.ProductID, r.ProductID); p.distance < 0 || score < p.distance {
+				p.distance = score
+			}
+		}
+	}
+
+	for i, p1 := range products {
+		for _, p2 := range products[i+1:] {
+			if s := recognize.NameSimilarity(p1.Name, p2.Name); s >= duplicateNameSimilarity {
+				pair(p1.ProductID, p2.ProductID).name = s
+			}
+		}
+	}
+
+	result := make([]duplicatePair, 0, len(pairs))
+	for _, p := range pairs {
+		result = append(result, *p)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		x, y := result[i], result[j]
+		if (x.barcode != "") != (y.barcode != "") {
+			return x.barcode != ""
+		}
+		if (x.distance >= 0) != (y.distance >= 0) {
+			return x.distance >= 0
+		}
+		if x.distance != y.distance {
+			return x.distance < y.distance
+		}
+		if x.name != y.name {
+			return x.name > y.name
+		}
+		return x.first < y.first || x.first == y.first && x.second < y.second
+	})
+	if len(result) > maxDuplicatePairs {
+		result = result[:maxDuplicatePairs]
+	}
+	return result, nil
+}
+
+// duplicateItems возвращает фото магазина с векторами текущей модели. С индексом фото
+// берутся из него, и он же ищет соседей, без индекса фото читаются из базы
+func (b *Bot) duplicateItems(ctx context.Context, userName string) ([]ann.Item, *ann.Index, error) {
+	if b.vectors != nil {
+		index := b.vectors.Shop(userName)
+		return index.Items(), index, nil
+	}
+	images, err := b.storage.GetVectorsByUsername(ctx, userName, b.recognizer.ActiveModel(), 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	items := make([]ann.Item, 0, len(images))
+	for _, image := range images {
+		items = append(items, vectorItem(image))
+	}
+	return items, nil, nil
+}
+
+// textBarcodes находит в распознанном тексте фото номера EAN-13
+func textBarcodes(text string) []string {
+	var codes []string
+	for _, w := range strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsDigit(r) }) {
+		if barcode.Valid(w) {
+			codes = append(codes, w)
+		}
+	}
+	return codes
+}
+
+// showDuplicate показывает очередную пару: редактирует сообщение msgID или отправляет новое.
+// Пары, товары которых уже удалены или объединены друг с другом, пропускаются
+func (b *Bot) showDuplicate(chatID int64, msgID int) error {
+	review := b.duplicates[chatID]
+	ctx := context.Background()
+	for ; review.pos < len(review.pairs); review.pos++ {
+		pair := review.pairs[review.pos]
+		firstID, secondID := review.resolve(pair.first), review.resolve(pair.second)
+		if firstID == secondID {
+			continue
+		}
+		first, err := b.storage.GetProductByID(ctx, firstID)
+		if err != nil {
+			return err
+		}
+		second, err := b.storage.GetProductByID(ctx, secondID)
+		if err != nil {
+			return err
+		}
+		if first == nil || second == nil {
+			continue
+		}
+
+		text := fmt.Sprintf("Возможные дубли %d из %d\nПохожи: %s\n\n%s\n%s\n"+
+			"Какой товар оставить? Остаток, фото и прошлые продажи второго перейдут к нему, второй будет удалён.",
+			review.pos+1, len(review.pairs), pair.reasons(), duplicateCard("1️⃣", first), duplicateCard("2️⃣", second))
+		suffix := "_" + strconv.Itoa(review.pos)
+		keyboard := tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Оставить 1️⃣", MergeKeepFirstCmd+suffix),
+				tgbotapi.NewInlineKeyboardButtonData("Оставить 2️⃣", MergeKeepSecondCmd+suffix),
+			),
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData("Не дубли", MergeSkipCmd+suffix),
+			),
+		)
+		return b.sendDuplicateMessage(chatID, msgID, text, &keyboard)
+	}
+
+	delete(b.duplicates, chatID)
+	text := fmt.Sprintf("Проверка дублей завершена: объединено %d, пропущено %d.", len(review.merged), review.skipped)
+	return b.sendDuplicateMessage(chatID, msgID, text, nil)
+}
+
+func (b *Bot) sendDuplicateMessage(chatID int64, msgID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) error {
+	if msgID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+		edit.ReplyMarkup = keyboard
+		_, err := b.bot.Send(edit)
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	_, err := b.bot.Send(msg)
+	return err
+}
+
+// duplicateCard описание товара в паре дублей
+func duplicateCard(mark string, product *storage.Product) string {
+	text := fmt.Sprintf("%s %s (#%d)\n📦 Наличие: %s\n💰 Цена продажи: %s\n", mark, product.Name, product.ProductID,
+		formatQuantity(product.Count, product.Unit), product.SellingPrice.StringFixed(cart.Places))
+	if product.Barcode != "" {
+		text += fmt.Sprintf("🏷 Штрихкод: %s\n", product.Barcode)
+	}
+	return text
+}
+
+// handleDuplicateDecision объединяет товары показанной пары, оставляя первый или второй,
+// или пропускает пару и показывает следующую
+func (b *Bot) handleDuplicateDecision(merge, keepFirst bool) func(*tgbotapi.CallbackQuery) error {
+	return func(callback *tgbotapi.CallbackQuery) error {
+		chatID := callback.Message.Chat.ID
+		data := callback.Data
+		pos, _ := strconv.Atoi(data[strings.LastIndex(data, "_")+1:])
+
+		review, ok := b.duplicates[chatID]
+		if !ok || pos != review.pos {
+			b.alert(callback, "Список дублей устарел, запустите /duplicates заново")
+			return nil
+		}
+		if !merge {
+			review.skipped++
+			review.pos++
+			return b.showDuplicate(chatID, callback.Message.MessageID)
+		}
+
+		ctx := context.Background()
+		if ok, err := b.isShopAdmin(ctx, callback.From.UserName); err != nil || !ok {
+			if err == nil {
+				b.alert(callback, "Объединять товары может только администратор магазина")
+			}
+			return err
+		}
+
+		pair := review.pairs[pos]
+		keepID, dropID := review.resolve(pair.first), review.resolve(pair.second)
+		if !keepFirst {
+			keepID, dropID = dropID, keepID
+		}
+		err := b.storage.MergeProducts(ctx, review.userName, keepID, dropID)
+		switch {
+		case errors.Is(err, storage.ErrMergeUnits):
+			b.alert(callback, "Нельзя объединить: у товаров разные единицы измерения")
+			return nil
+		case errors.Is(err, storage.ErrMergeVariants):
+			b.alert(callback, "Нельзя объединить: варианты есть только у одного из товаров")
+			return nil
+		case errors.Is(err, storage.ErrNoSavedProducts):
+			b.toast(callback, "Товар уже удалён")
+		case err != nil:
+			return err
+		default:
+			review.merged[dropID] = keepID
+			b.moveProductVectors(review.userName, dropID, keepID)
+			b.toast(callback, "Товары объединены")
+		}
+		review.pos++
+		return b.showDuplicate(chatID, callback.Message.MessageID)
+	}
+}
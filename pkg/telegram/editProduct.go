@@ -3,8 +3,8 @@ package telegram
 import (
 	"context"
 	"fmt"
-	"strconv"
 
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/shopspring/decimal"
 )
@@ -19,11 +19,32 @@ func (b *Bot) handleEditProductCmd(callback *tgbotapi.CallbackQuery) error {
 	b.tempMsgID[chatID] = callback.Message.MessageID
 	// Обновляем клавиатуру с галочками
 	editProductKeyboard := b.generateEditProductKeyboard(chatID)
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, callback.Message.MessageID, editProductKeyboard)
-	_, err := b.bot.Send(msg)
+	err := b.bot.EditMarkup(chatID, callback.Message.MessageID, editProductKeyboard)
 	return err
 }
 
+// handleToggleEditParam отмечает параметр товара для редактирования
+func (b *Bot) handleToggleEditParam(param string) CallbackHandler {
+	return func(callback *tgbotapi.CallbackQuery) error {
+		chatID := callback.Message.Chat.ID
+		if param == EditProductCountCmd {
+			variants, err := b.storage.GetVariants(context.Background(), b.tempProduct[chatID].ProductID)
+			if err != nil {
+				return err
+			}
+			if len(variants) > 0 {
+				b.alert(callback, "Остаток ведётся по вариантам, измените его в списке вариантов")
+				return nil
+			}
+		}
+		if b.selectedParams[chatID] == nil {
+			b.selectedParams[chatID] = make(map[string]bool)
+		}
+		b.selectedParams[chatID][param] = true
+		return b.handleEditProductCmd(callback)
+	}
+}
+
 func (b *Bot) handleConfirmEdit(message *tgbotapi.Message) error {
 	chatID := message.Chat.ID
 	selectedParams := b.selectedParams[chatID]
@@ -66,15 +87,28 @@ func (b *Bot) handleConfirmEdit(message *tgbotapi.Message) error {
 		case EditProductCountCmd:
 			if state != stateWaitingForEditCount {
 				b.states[chatID] = stateWaitingForEditCount
-				b.bot.Send(tgbotapi.NewMessage(chatID, "Введите новое количество:"))
+				b.bot.Send(tgbotapi.NewMessage(chatID, "Введите новое количество, при смене единицы — с ней: 2,5 кг"))
 				return nil
 			} else {
-				count, err := strconv.Atoi(text)
+				count, unit, err := parseQuantity(text)
 				if err != nil {
 					return fmt.Errorf("ошибка ввода количества: %w", err)
 				}
-				product.Count = uint(count)
+				saved, err := b.storage.GetProductByID(context.Background(), product.ProductID)
+				if err != nil || saved == nil {
+					return fmt.Errorf("товар %d не найден: %w", product.ProductID, err)
+				}
+				if unit == "" {
+					unit = saved.Unit
+				}
+				if !storage.Fractional(unit) && !count.IsInteger() {
+					b.bot.Send(tgbotapi.NewMessage(chatID, "Для штучного товара количество целое, укажите единицу: 2,5 кг"))
+					return nil
+				}
+				product.Count = count
+				product.Unit = unit
 				b.storage.UpdateProductField(context.Background(), product.ProductID, "count", count)
+				b.storage.UpdateProductField(context.Background(), product.ProductID, "unit", unit)
 				b.bot.Send(tgbotapi.NewMessage(chatID, "Количество успешно обновлено!"))
 				b.selectedParams[chatID][EditProductCountCmd] = false
 			}
@@ -128,8 +162,7 @@ func (b *Bot) handleConfirmEdit(message *tgbotapi.Message) error {
 		),
 	)
 
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, b.tempMsgID[chatID], buttonDone)
-	_, err := b.bot.Send(msg)
+	err := b.bot.EditMarkup(chatID, b.tempMsgID[chatID], buttonDone)
 	delete(b.states, chatID)
 	delete(b.tempProduct, chatID)
 	delete(b.selectedParams, chatID)
@@ -170,6 +203,13 @@ func (b *Bot) getProductActionKeyboard(productID uint) tgbotapi.InlineKeyboardMa
 			tgbotapi.NewInlineKeyboardButtonData("✏️ Изменить ", fmt.Sprintf("%s_%d", EditProductCmd, productID)),
 			tgbotapi.NewInlineKeyboardButtonData("Удалить ❓", fmt.Sprintf("%s_%d", ConfirmDelProductCmd, productID)),
 		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🖼 Фото", fmt.Sprintf("%s_%d", PhotosProductCmd, productID)),
+			tgbotapi.NewInlineKeyboardButtonData("📂 Категория", fmt.Sprintf("%s_%d", EditCategoryCmd, productID)),
+		),
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🎨 Варианты", fmt.Sprintf("%s_%d", VariantsCmd, productID)),
+		),
 	)
 }
 
@@ -184,8 +224,7 @@ func (b *Bot) handleConfirmDeleteProductCmd(callback *tgbotapi.CallbackQuery) er
 		),
 	)
 
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, b.tempMsgID[chatID], buttonDone)
-	_, err := b.bot.Send(msg)
+	err := b.bot.EditMarkup(chatID, b.tempMsgID[chatID], buttonDone)
 	return err
 }
 
@@ -197,11 +236,11 @@ func (b *Bot) handleDeleteProductCmd(callback *tgbotapi.CallbackQuery) error {
 	err := b.storage.Remove(context.Background(), product.ProductID)
 
 	if err != nil {
-		msg := tgbotapi.NewMessage(chatID, "Не удалось удалить товар")
-		if _, err := b.bot.Send(msg); err != nil {
-			return err
-		}
+		b.alert(callback, "Не удалось удалить товар")
+		return err
 	}
+	b.unindexProduct(callback.Message.Chat.UserName, product.ProductID)
+	b.toast(callback, "Удалено")
 
 	buttonDone := tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
@@ -209,8 +248,7 @@ func (b *Bot) handleDeleteProductCmd(callback *tgbotapi.CallbackQuery) error {
 		),
 	)
 
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, b.tempMsgID[chatID], buttonDone)
-	_, err = b.bot.Send(msg)
+	err = b.bot.EditMarkup(chatID, b.tempMsgID[chatID], buttonDone)
 	delete(b.states, chatID)
 	delete(b.tempProduct, chatID)
 	delete(b.selectedParams, chatID)
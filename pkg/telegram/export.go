@@ -0,0 +1,137 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	"github.com/Bariban/vector-shop-bot/pkg/table"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/shopspring/decimal"
+)
+
+// exportFormats форматы выгрузки, по умолчанию отправляются оба
+var exportFormats = []string{"csv", "xlsx"}
+
+// splitExportFormat отделяет формат выгрузки от остальных аргументов команды
+func splitExportFormat(args []string) ([]string, []string) {
+	if n := len(args); n > 0 {
+		for _, f := range exportFormats {
+			if strings.EqualFold(args[n-1], f) {
+				return args[:n-1], []string{f}
+			}
+		}
+	}
+	return args, exportFormats
+}
+
+// sendTable отправляет таблицу документами в выбранных форматах
+func (b *Bot) sendTable(chatID int64, name, caption string, rows [][]interface{}, formats []string) error {
+	for _, format := range formats {
+		fileName := name + "." + format
+		data, err := table.Write(fileName, rows)
+		if err != nil {
+			return err
+		}
+		doc := tgbotapi.NewDocumentUpload(chatID, tgbotapi.FileBytes{Name: fileName, Bytes: data})
+		doc.Caption = caption
+		if _, err := b.bot.Send(doc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// handleExportCatalog выгружает каталог с остатками и их стоимостью: /export_catalog [csv|xlsx].
+// Товары с вариантами выгружаются по вариантам
+func (b *Bot) handleExportCatalog(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	args, formats := splitExportFormat(strings.Fields(message.CommandArguments()))
+	if len(args) > 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Формат: /export_catalog [csv|xlsx]"))
+		return err
+	}
+
+	userName := message.From.UserName
+	products, err := b.storage.GetStockItems(context.Background(), userName)
+	if err != nil {
+		return err
+	}
+	if len(products) == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Каталог пуст"))
+		return err
+	}
+	tree, err := b.categories(userName)
+	if err != nil {
+		return err
+	}
+
+	rows := [][]interface{}{{
+		"ID", "Название", "Артикул", "Штрихкод", "Категория", "Единица", "Остаток",
+		"Цена закупки", "Цена продажи", "Стоимость по закупке", "Стоимость по продаже",
+	}}
+	purchaseTotal, sellingTotal := decimal.Zero, decimal.Zero
+	for _, p := range products {
+		purchase := p.Count.Mul(p.PurchasePrice).Round(cart.Places)
+		selling := p.Count.Mul(p.SellingPrice).Round(cart.Places)
+		purchaseTotal = purchaseTotal.Add(purchase)
+		sellingTotal = sellingTotal.Add(selling)
+		rows = append(rows, []interface{}{
+			p.ProductID, p.Name, p.SKU, p.Barcode, tree.paths[p.CategoryID], storage.UnitLabel(p.Unit), p.Count,
+			p.PurchasePrice, p.SellingPrice, purchase, selling,
+		})
+	}
+	rows = append(rows, []interface{}{nil, "Итого", nil, nil, nil, nil, nil, nil, nil, purchaseTotal, sellingTotal})
+
+	caption := fmt.Sprintf("📦 Каталог: %d позиций, остаток по закупке %s, по продаже %s",
+		len(products), purchaseTotal.StringFixed(cart.Places), sellingTotal.StringFixed(cart.Places))
+	return b.sendTable(chatID, "catalogue_"+time.Now().Format(dateLayout), caption, rows, formats)
+}
+
+// handleExportOrders выгружает заказы со строками за период:
+// /export_orders [ГГГГ-ММ-ДД] [ГГГГ-ММ-ДД] [csv|xlsx]. Одна строка таблицы — одна строка чека
+func (b *Bot) handleExportOrders(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	args, formats := splitExportFormat(strings.Fields(message.CommandArguments()))
+	from, to, err := parsePeriod(args)
+	if err != nil {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Формат: /export_orders [ГГГГ-ММ-ДД] [ГГГГ-ММ-ДД] [csv|xlsx]"))
+		return err
+	}
+
+	orders, err := b.storage.GetOrdersWithDetails(context.Background(), message.From.UserName, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+	period := from.Format(dateLayout) + " — " + to.Format(dateLayout)
+	if len(orders) == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Заказов за "+period+" нет"))
+		return err
+	}
+
+	rows := [][]interface{}{{
+		"Заказ", "Дата", "Оплата", "Телефон", "Товар ID", "Товар", "Единица", "Количество",
+		"Цена", "Скидка по строке", "Сумма строки", "Сумма чека без скидки", "Скидка на чек", "Промокод", "Итого по чеку",
+	}}
+	total := decimal.Zero
+	for _, o := range orders {
+		total = total.Add(o.Amount)
+		for i, d := range o.Details {
+			row := []interface{}{
+				o.ID, *o.Date, o.PayType.Description, o.BuersPhone, d.ProductID, d.Name, storage.UnitLabel(d.Unit), d.Count,
+				d.ListPrice, d.DiscountAmount, d.FactSum,
+			}
+			// Суммы чека — только в его первой строке, чтобы итог по колонке сходился
+			if i == 0 {
+				row = append(row, o.Subtotal, o.DiscountAmount, o.PromoCode, o.Amount)
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	caption := fmt.Sprintf("🧾 Заказы за %s: %d, выручка %s", period, len(orders), total.StringFixed(cart.Places))
+	return b.sendTable(chatID, fmt.Sprintf("orders_%s_%s", from.Format(dateLayout), to.Format(dateLayout)), caption, rows, formats)
+}
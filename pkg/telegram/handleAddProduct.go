@@ -2,21 +2,26 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
-	"strconv"
 
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
 	"github.com/Bariban/vector-shop-bot/pkg/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/shopspring/decimal"
 )
 
+// maxSuggestedName длина названия, предлагаемого по надписи на упаковке
+const maxSuggestedName = 64
+
 func (b *Bot) handleAddProductCmd(message *tgbotapi.Message) error {
 	chatID := message.Chat.ID
 	product := b.tempProduct[chatID]
 	if message.Text == "Добавить товар" {
 		delete(b.states, chatID)
 		delete(b.tempProduct, chatID)
+		delete(b.recognitions, chatID)
 	}
 	if b.states[chatID] != stateWaitingForPhoto {
 		b.states[chatID] = stateWaitingForPhoto
@@ -37,76 +42,49 @@ func (b *Bot) handleAddProductCmd(message *tgbotapi.Message) error {
 		product.Image = append(product.Image, &storage.ImageMeta{})
 	}
 
+	// Дополнительные ракурсы можно прислать на любом шаге мастера, в том числе альбомом
+	if message.Photo != nil && b.states[chatID] != stateWaitingForPhoto {
+		return b.addWizardPhoto(message, product)
+	}
+
 	switch b.states[chatID] {
 	case stateWaitingForPhoto:
-		imageMeta, err := b.getFileMeta((*message.Photo)[len(*message.Photo)-1].FileID)
+		imageMeta, err := b.receivePhoto((*message.Photo)[len(*message.Photo)-1].FileID)
 		if err != nil {
 			msg := tgbotapi.NewMessage(chatID, "Ошибка обработки фото.")
 			_, _ = b.bot.Send(msg)
 			return err
 		}
-		foundProduct, err := b.getProductsByVector(message, imageMeta.Float)
+		// Похожие по вектору товары и подсказки появятся, когда ответит CLIP-сервис,
+		// мастер их не ждёт. Совпадение штрихкода проверяется сразу
+		first := b.startRecognition(imageMeta)
+		b.recognitions[chatID] = []*recognition{first}
+
+		foundProduct, err := b.findProducts(message, imageMeta)
 		if err != nil {
 			msg := tgbotapi.NewMessage(chatID, "Ошибка обработки фото.")
 			_, _ = b.bot.Send(msg)
 			return err
 		}
-		l := len(foundProduct)
-		if l > 0 {
-			var message string
-			if l == 1 {
-				message = "❗️ Найден похожий товар"
-			} else {
-				message = "❗️ Найдены похожие товары"
-			}
-			msg := tgbotapi.NewMessage(chatID, message)
-			_, _ = b.bot.Send(msg)
-			for _, product := range foundProduct {
-
-				// Отправляем изображения (если есть)
-				for _, photo := range product.Image {
-					photoFile := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{
-						Name:  fmt.Sprintf("product_%d.jpg", product.ProductID),
-						Bytes: photo.Byte,
-					})
-					if _, err := b.bot.Send(photoFile); err != nil {
-						log.Printf("не удалось отправить фото: %v", err)
-					}
-				}
-
-				// Формируем текст с информацией о продукте
-				productInfo := fmt.Sprintf(
-					"🛒 *%s*\n📦 Наличие: %d\n💰 Цена продажи: %s\n",
-					product.Name,
-					product.Count,
-					product.SellingPrice.StringFixed(2),
-				)
-
-				actionsProductKeyboard := b.getProductActionKeyboard(product.ProductID)
-
-				msg := tgbotapi.NewMessage(chatID, productInfo)
-				msg.ParseMode = "Markdown"
-				msg.ReplyMarkup = actionsProductKeyboard
-
-				sentMsg, err := b.bot.Send(msg)
-				if err != nil {
-					log.Printf("не удалось отправить информацию о продукте: %v", err)
-					return err
-				}
-
-				b.tempMsgID[chatID] = sentMsg.MessageID
-			}
-			return err
+		if len(foundProduct) > 0 {
+			return b.showFoundProducts(chatID, foundProduct)
 		}
 
-		product.Image[0].Url = imageMeta.Url
-		product.Image[0].Float = imageMeta.Float
+		product.Image[0] = imageMeta
+		product.Barcode = imageMeta.Barcode
 		b.states[chatID] = stateWaitingForName
-		msg := tgbotapi.NewMessage(chatID, "Введите название товара:")
-		_, err = b.bot.Send(msg)
-		return err
+		sent, err := b.bot.Send(tgbotapi.NewMessage(chatID, namePrompt(product.Barcode, "")))
+		if err != nil {
+			return err
+		}
+		first.message, first.promptID = message, sent.MessageID
+		b.notifyRecognized(first)
+		return nil
 	case stateWaitingForName:
 		product.Name = message.Text
+		if name := recognize.SuggestName(product.Image[0].Text, maxSuggestedName); message.Text == "+" && name != "" {
+			product.Name = name
+		}
 		b.states[chatID] = stateWaitingForDescription
 		msg := tgbotapi.NewMessage(chatID, "Введите описание товара:")
 		_, err := b.bot.Send(msg)
@@ -114,19 +92,30 @@ func (b *Bot) handleAddProductCmd(message *tgbotapi.Message) error {
 
 	case stateWaitingForDescription:
 		product.Description = message.Text
-		b.states[chatID] = stateWaitingForCount
-		msg := tgbotapi.NewMessage(chatID, "Введите количество товара:")
-		_, err := b.bot.Send(msg)
-		return err
+		b.states[chatID] = stateWaitingForCategory
+		b.applyRecognitions(chatID)
+		return b.askWizardCategory(chatID, product)
+
+	case stateWaitingForCategory:
+		return b.handleWizardCategoryText(message, product)
 
 	case stateWaitingForCount:
-		count, err := strconv.Atoi(message.Text)
+		count, unit, err := parseQuantity(message.Text)
 		if err != nil {
-			msg := tgbotapi.NewMessage(chatID, "Введите корректное количество.")
+			msg := tgbotapi.NewMessage(chatID, "Введите корректное количество, например 5 или 2,5 кг.")
 			_, _ = b.bot.Send(msg)
 			return err
 		}
-		product.Count = uint(count)
+		if unit == "" {
+			unit = storage.UnitPiece
+		}
+		if !storage.Fractional(unit) && !count.IsInteger() {
+			msg := tgbotapi.NewMessage(chatID, "Для штучного товара количество целое. Для товара на вес или метраж укажите единицу: 2,5 кг, 10 м, 1,5 л.")
+			_, err = b.bot.Send(msg)
+			return err
+		}
+		product.Count = count
+		product.Unit = unit
 		b.states[chatID] = stateWaitingForPurchasePrice
 		msg := tgbotapi.NewMessage(chatID, "Введите цену закупки:")
 		_, err = b.bot.Send(msg)
@@ -156,16 +145,33 @@ func (b *Bot) handleAddProductCmd(message *tgbotapi.Message) error {
 
 		// Сохраняем продукт в БД
 		product.ProductID, err = b.storage.Save(context.Background(), product)
+		if errors.Is(err, storage.ErrBarcodeExists) {
+			delete(b.states, chatID)
+			delete(b.tempProduct, chatID)
+			_, err = b.bot.Send(tgbotapi.NewMessage(chatID, "Товар с таким штрихкодом уже добавлен."))
+			return err
+		}
 		if err != nil {
 			msg := tgbotapi.NewMessage(chatID, "Ошибка сохранения товара.")
 			_, _ = b.bot.Send(msg)
 			return err
 		}
-		// Сохраняем изображение в БД
-
-		product.Image[0].Byte, err = b.getFileContent(product.Image[0].Url)
-		if err != nil {
-			msg := tgbotapi.NewMessage(chatID, "Ошибка обработки содержимого фото.")
+		// Сохраняем изображения в БД, ещё не распознанные фото индексируются в фоне
+		b.applyRecognitions(chatID)
+		delete(b.recognitions, chatID)
+		for _, image := range product.Image {
+			if image.Byte != nil {
+				continue
+			}
+			image.Byte, err = b.getFileContent(image.Url)
+			if err != nil {
+				msg := tgbotapi.NewMessage(chatID, "Ошибка обработки содержимого фото.")
+				_, _ = b.bot.Send(msg)
+				return err
+			}
+		}
+		if err := b.storeImages(product.Image); err != nil {
+			msg := tgbotapi.NewMessage(chatID, "Ошибка сохранения фото.")
 			_, _ = b.bot.Send(msg)
 			return err
 		}
@@ -175,14 +181,114 @@ func (b *Bot) handleAddProductCmd(message *tgbotapi.Message) error {
 			_, _ = b.bot.Send(msg)
 			return err
 		}
+		b.indexVectors(product.UserName, product.Image)
 
 		delete(b.states, chatID)
 		delete(b.tempProduct, chatID)
 
-		msg := tgbotapi.NewMessage(chatID, "Товар успешно добавлен!")
+		text := "Товар успешно добавлен!"
+		if queued := b.indexLater(product.Image); queued > 0 {
+			text += fmt.Sprintf("\n⏳ Фото распознаются в фоне: %d. До этого товар находится по фото только по штрихкоду.", queued)
+		}
+		msg := tgbotapi.NewMessage(chatID, text)
 		_, err = b.bot.Send(msg)
 		return err
 	}
 
 	return nil
 }
+
+// namePrompt вопрос о названии товара с подсказками по штрихкоду и надписи на упаковке
+func namePrompt(barcode, suggested string) string {
+	text := "Введите название товара:"
+	if barcode != "" {
+		text = fmt.Sprintf("🏷 Штрихкод: %s\n%s", barcode, text)
+	}
+	if suggested != "" {
+		text = fmt.Sprintf("На упаковке: «%s»\nВведите название товара или «+», чтобы взять это:", suggested)
+	}
+	return text + "\n📷 Можно отправить ещё фото с других ракурсов."
+}
+
+// showFoundProducts показывает товары, похожие на фото из мастера
+func (b *Bot) showFoundProducts(chatID int64, products []*storage.Product) error {
+	text := "❗️ Найдены похожие товары"
+	if len(products) == 1 {
+		text = "❗️ Найден похожий товар"
+	}
+	_, _ = b.bot.Send(tgbotapi.NewMessage(chatID, text))
+	for _, product := range products {
+		for _, photo := range product.Image {
+			if _, err := b.sendImage(chatID, photo, true, "", nil); err != nil {
+				log.Printf("не удалось отправить фото: %v", err)
+			}
+		}
+
+		msg := tgbotapi.NewMessage(chatID, productCardText(product))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = b.getProductActionKeyboard(product.ProductID)
+		sent, err := b.bot.Send(msg)
+		if err != nil {
+			log.Printf("не удалось отправить информацию о продукте: %v", err)
+			return err
+		}
+		b.tempMsgID[chatID] = sent.MessageID
+	}
+	return nil
+}
+
+// showRecognition дополняет мастер результатом распознавания первого фото: показывает
+// похожие товары и дописывает в вопрос о названии надпись с упаковки. Если мастер
+// уже ушёл дальше вопроса о названии, подсказки не нужны
+func (b *Bot) showRecognition(r *recognition) error {
+	chatID := r.message.Chat.ID
+	product := b.tempProduct[chatID]
+	if b.states[chatID] != stateWaitingForName || product == nil || len(product.Image) == 0 ||
+		product.Image[0] != r.image || !r.apply() {
+		return nil
+	}
+
+	if name := recognize.SuggestName(r.image.Text, maxSuggestedName); name != "" {
+		edit := tgbotapi.NewEditMessageText(chatID, r.promptID, namePrompt(product.Barcode, name))
+		if _, err := b.bot.Send(edit); err != nil {
+			return err
+		}
+	}
+
+	found, err := b.findProducts(r.message, r.image)
+	if err != nil || len(found) == 0 {
+		return err
+	}
+	return b.showFoundProducts(chatID, found)
+}
+
+// addWizardPhoto добавляет ещё один ракурс к товару в мастере добавления
+func (b *Bot) addWizardPhoto(message *tgbotapi.Message, product *storage.Product) error {
+	chatID := message.Chat.ID
+	imageMeta, err := b.receivePhoto((*message.Photo)[len(*message.Photo)-1].FileID)
+	if err != nil {
+		msg := tgbotapi.NewMessage(chatID, "Ошибка обработки фото.")
+		_, _ = b.bot.Send(msg)
+		return err
+	}
+	product.Image = append(product.Image, imageMeta)
+	b.recognitions[chatID] = append(b.recognitions[chatID], b.startRecognition(imageMeta))
+
+	text := fmt.Sprintf("📷 Фото добавлено, всего: %d", len(product.Image))
+	if product.Barcode == "" && imageMeta.Barcode != "" {
+		existing, err := b.storage.GetProductByBarcode(context.Background(), product.UserName, imageMeta.Barcode)
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			text += fmt.Sprintf("\n🏷 Штрихкод %s уже у товара «%s»", imageMeta.Barcode, existing.Name)
+		} else {
+			product.Barcode = imageMeta.Barcode
+			text += "\n🏷 Штрихкод: " + product.Barcode
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	_, err = b.bot.Send(msg)
+	return err
+}
@@ -2,11 +2,13 @@ package telegram
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"strconv"
 	"strings"
 
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
 	"github.com/Bariban/vector-shop-bot/pkg/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
 	"github.com/shopspring/decimal"
@@ -23,12 +25,15 @@ func (b *Bot) getAddItemToCartKeyboard(productID uint) tgbotapi.InlineKeyboardMa
 
 // getProductActionKeyboard возвращает клавиатуру с действиями над товаром
 func (b *Bot) getCountItemInCartKeyboard(chatID int64, productID uint) tgbotapi.InlineKeyboardMarkup {
-	cart := b.cartItems[chatID].CartItems[productID]
-	countItem := int(cart.CountCart)
+	line, _ := b.cart(chatID).Line(productID)
 
 	var discount string
-	if cart.Discount != 0 {
-		discount = "Скидка  -" + strconv.Itoa(int(cart.Discount)) + "%"
+	if line.Pending != nil {
+		discount = "⏳ Скидка на подтверждении"
+	} else if line.PriceOverride != nil {
+		discount = "Цена " + line.PriceOverride.StringFixed(cart.Places)
+	} else if line.Discount != 0 {
+		discount = "Скидка  -" + strconv.Itoa(int(line.Discount)) + "%"
 	} else {
 		discount = "Скидка"
 	}
@@ -36,7 +41,7 @@ func (b *Bot) getCountItemInCartKeyboard(chatID int64, productID uint) tgbotapi.
 	return tgbotapi.NewInlineKeyboardMarkup(
 		tgbotapi.NewInlineKeyboardRow(
 			tgbotapi.NewInlineKeyboardButtonData("  ➖  ", fmt.Sprintf("%s_%d", ReduceItemInCartCmd, productID)),
-			tgbotapi.NewInlineKeyboardButtonData(strconv.Itoa(countItem), fmt.Sprintf("%s_%d", EditCountItemInCartCmd, productID)),
+			tgbotapi.NewInlineKeyboardButtonData(lineQuantity(line), fmt.Sprintf("%s_%d", EditCountItemInCartCmd, productID)),
 			tgbotapi.NewInlineKeyboardButtonData("  ➕  ", fmt.Sprintf("%s_%d", AddItemToCartCmd, productID)),
 		),
 		tgbotapi.NewInlineKeyboardRow(
@@ -46,412 +51,299 @@ func (b *Bot) getCountItemInCartKeyboard(chatID int64, productID uint) tgbotapi.
 	)
 }
 
-func (b *Bot) handleAddItemToCart(callback *tgbotapi.CallbackQuery) error {
-	chatID := callback.Message.Chat.ID
-	messageID := callback.Message.MessageID
-	product := b.tempProduct[chatID]
+// cart возвращает корзину чата, создавая её при необходимости
+func (b *Bot) cart(chatID int64) *cart.Cart {
+	c, ok := b.carts[chatID]
+	if !ok {
+		c = cart.New()
+		b.carts[chatID] = c
+	}
+	return c
+}
 
-	cart, exists := b.cartItems[chatID]
-	if !exists {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Корзина не найдена:"))
-		return nil
+// refreshCartLine перерисовывает сводку корзины и кнопки карточки товара
+func (b *Bot) refreshCartLine(chatID int64, productID uint) error {
+	if err := b.renderCart(chatID); err != nil {
+		return err
 	}
-	cartItem, exists := cart.CartItems[product.ProductID]
 
-	if !exists {
-		b.states[chatID] = stateEditCountItemInCart
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Товар не найден:"))
+	msgID := b.tracker.cardMsgID(chatID, productID)
+	if msgID == 0 {
 		return nil
 	}
 
-	var str string
-	if cartItem.CountCart < cartItem.CountStore {
-		cartItem.CountCart++
-		str = "+" + cartItem.Price.String()
-		cart.Amount = cart.Amount.Add(cartItem.Price)
-		b.cartItems[chatID] = cart
+	keyboard := b.getAddItemToCartKeyboard(productID)
+	if line, _ := b.cart(chatID).Line(productID); line.Quantity.IsPositive() {
+		keyboard = b.getCountItemInCartKeyboard(chatID, productID)
 	}
+	return b.bot.EditMarkup(chatID, msgID, keyboard)
+}
 
-	cartItem.MsgID = messageID
-	b.cartItems[chatID].CartItems[product.ProductID] = cartItem
+func (b *Bot) handleAddItemToCart(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	product := b.tempProduct[chatID]
+	c := b.cart(chatID)
 
-	if cartItem.CountCart == 1 {
-		b.cleanUpMessages(chatID, messageID)
-		b.tempMsgID[chatID] = messageID
+	switch err := c.Add(product.ProductID, one); err {
+	case nil:
+	case cart.ErrOutOfStock:
+		b.toast(callback, "Нет в наличии")
+		return nil
+	default:
+		b.alert(callback, "Товар не найден")
+		return nil
 	}
 
-	b.getSellingKeyboard(chatID, str)
-
-	// Обновляем клавиатуру
-	CountItemInCartKeyboard := b.getCountItemInCartKeyboard(chatID, product.ProductID)
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, cartItem.MsgID, CountItemInCartKeyboard)
-	_, err := b.bot.Send(msg)
+	if line, _ := c.Line(product.ProductID); line.Quantity.Equal(one) {
+		b.tracker.rememberCard(chatID, product.ProductID, callback.Message.MessageID)
+		b.cleanUpMessages(chatID)
+	}
 
-	return err
+	b.toast(callback, fmt.Sprintf("+1 добавлено · 🛍 %s", c.Total().StringFixed(cart.Places)))
+	return b.refreshCartLine(chatID, product.ProductID)
 }
 
 func (b *Bot) handleReduceItemInCart(callback *tgbotapi.CallbackQuery) error {
 	chatID := callback.Message.Chat.ID
 	product := b.tempProduct[chatID]
+	c := b.cart(chatID)
 
-	cart, exists := b.cartItems[chatID]
+	line, exists := c.Line(product.ProductID)
 	if !exists {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Корзина не найдена:"))
+		b.alert(callback, "Товар не найден")
 		return nil
 	}
-	cartItem, exists := cart.CartItems[product.ProductID]
 
-	if !exists {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Товар не найден:"))
+	if line.Quantity.LessThanOrEqual(one) {
+		b.toast(callback, "Минимум 1 "+line.Unit)
 		return nil
 	}
-	var str string
-	if cartItem.CountCart > 1 {
-		cartItem.CountCart--
-		str = "-" + cartItem.Price.String()
-		cart.Amount = cart.Amount.Sub(cartItem.Price)
-		b.cartItems[chatID] = cart
-	}
 
-	if cartItem.MsgID == 0 {
-		cartItem.MsgID = callback.Message.MessageID
+	if err := c.Remove(product.ProductID, one); err != nil {
+		b.alert(callback, err.Error())
+		return nil
 	}
 
-	b.cartItems[chatID].CartItems[product.ProductID] = cartItem
-
-	b.getSellingKeyboard(chatID, str)
-
-	// Обновляем клавиатуру
-	CountItemInCartKeyboard := b.getCountItemInCartKeyboard(chatID, product.ProductID)
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, cartItem.MsgID, CountItemInCartKeyboard)
-	_, err := b.bot.Send(msg)
-
-	return err
+	b.toast(callback, fmt.Sprintf("−1 · 🛍 %s", c.Total().StringFixed(cart.Places)))
+	return b.refreshCartLine(chatID, product.ProductID)
 }
 
 func (b *Bot) handleDiscoutItemInCart(message *tgbotapi.Message) error {
 	chatID := message.Chat.ID
 	product := b.tempProduct[chatID]
 	state := b.states[chatID]
+	c := b.cart(chatID)
 
-	cart, exists := b.cartItems[chatID]
-	if !exists {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Корзина не найдена:"))
-		return nil
-	}
-
-	cartItem, exists := cart.CartItems[product.ProductID]
-	count := cartItem.CountCart
-	if !exists {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Товар не найден:"))
+	if _, exists := c.Line(product.ProductID); !exists {
+		b.sendCartPrompt(chatID, "Товар не найден:")
 		return nil
 	}
 
 	if state != stateDiscountProductInCart {
 		b.states[chatID] = stateDiscountProductInCart
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Введите скидку:"))
+		b.sendCartPrompt(chatID, "Введите скидку в процентах или новую цену через =, например =990:")
 		return nil
 	}
 
 	input := strings.TrimSpace(message.Text)
 	if len(input) == 0 {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Введите корректное значение:"))
+		b.sendCartPrompt(chatID, "Введите корректное значение:")
 		return nil
 	}
 
-	// Преобразуем оставшуюся часть в число
-	discount, err := strconv.Atoi(input)
-	if err != nil || discount < 0 || discount > 100 {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Введите значение скидки от 0 до 100:"))
-		return nil
+	var pending cart.Pending
+	if strings.HasPrefix(input, "=") {
+		// Фиксированная цена вместо скидки
+		price, err := decimal.NewFromString(strings.ReplaceAll(strings.TrimSpace(input[1:]), ",", "."))
+		if err != nil || price.IsNegative() {
+			b.sendCartPrompt(chatID, "Введите цену числом, например =990:")
+			return nil
+		}
+		pending.Price = &price
+	} else {
+		// Преобразуем оставшуюся часть в число
+		discount, err := strconv.Atoi(strings.TrimSuffix(input, "%"))
+		if err != nil || discount < 0 || discount > 100 {
+			b.sendCartPrompt(chatID, "Введите значение скидки от 0 до 100:")
+			return nil
+		}
+		pending.Discount = uint(discount)
 	}
 
-	// Вычисляем новую цену со скидкой
-	discountFactor := decimal.NewFromFloat(1 - float64(discount)/100)
-	newPrice := cartItem.PriceStore.Mul(discountFactor)
-
-	// Вычисляем разницу в сумме
-	var str string
-	if count > 0 {
-		originalTotal := cartItem.Price.Mul(decimal.NewFromInt(int64(count)))
-		discounted := newPrice.Mul(decimal.NewFromInt(int64(count)))
-		discountedTotal := originalTotal.Sub(discounted)
-		str = "-" + discountedTotal.String()
-		cart.Amount = discounted
-		b.cartItems[chatID] = cart
+	if err := c.SetPending(product.ProductID, pending); err != nil {
+		return err
 	}
+	delete(b.states, chatID)
 
-	// Обновляем цену
-	cartItem.Price = newPrice
-	cartItem.Discount = uint(discount)
-	if cartItem.MsgID == 0 {
-		cartItem.MsgID = message.MessageID
+	// Скидка сверх лимита роли ждёт решения администратора
+	line, _ := c.Line(product.ProductID)
+	limit, shopID, err := b.maxDiscountFor(message.Chat.UserName)
+	if err != nil {
+		return err
+	}
+	if discount := pending.EffectiveDiscount(line.UnitPrice); discount > limit {
+		if err := b.requestDiscountApproval(message, shopID, line, discount, limit); err != nil {
+			return err
+		}
+		return b.refreshCartLine(chatID, product.ProductID)
 	}
-	b.cartItems[chatID].CartItems[product.ProductID] = cartItem
-
-	b.getSellingKeyboard(chatID, str)
-
-	CountItemInCartKeyboard := b.getCountItemInCartKeyboard(chatID, product.ProductID)
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, cartItem.MsgID, CountItemInCartKeyboard)
-	_, err = b.bot.Send(msg)
 
-	delete(b.states, chatID)
-	return err
+	if err := c.ResolvePending(product.ProductID, true); err != nil {
+		return err
+	}
+	return b.refreshCartLine(chatID, product.ProductID)
 }
 
 func (b *Bot) handleEditCountItemInCart(message *tgbotapi.Message) error {
 	chatID := message.Chat.ID
 	product := b.tempProduct[chatID]
 	state := b.states[chatID]
+	c := b.cart(chatID)
 
-	cart, exists := b.cartItems[chatID]
-	if !exists {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Корзина не найдена:"))
-		return nil
-	}
-
-	cartItem, exists := cart.CartItems[product.ProductID]
-
+	line, exists := c.Line(product.ProductID)
 	if !exists {
-		b.states[chatID] = stateEditCountItemInCart
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Товар не найден:"))
+		b.sendCartPrompt(chatID, "Товар не найден:")
 		return nil
 	}
 
 	if state != stateEditCountItemInCart {
 		b.states[chatID] = stateEditCountItemInCart
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Введите количество:"))
+		b.sendCartPrompt(chatID, "Введите количество:")
 		return nil
 	}
 
 	input := strings.TrimSpace(message.Text)
 	if len(input) == 0 {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Введите корректное значение:"))
+		b.sendCartPrompt(chatID, "Введите корректное значение:")
 		return nil
 	}
 
 	// Проверяем, есть ли знак перед числом
 	sign := ""
-	if strings.HasPrefix(input, "+") || strings.HasPrefix(input, "-") || strings.HasPrefix(input, "*") || strings.HasPrefix(input, "/") {
+	if strings.HasPrefix(input, "+") || strings.HasPrefix(input, "-") {
 		sign = input[:1]
 		input = input[1:]
 	}
 
-	// Преобразуем оставшуюся часть в число
-	count, err := strconv.Atoi(input)
-	if err != nil || count < 0 {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Введите корректное положительное число:"))
+	// Преобразуем оставшуюся часть в число, единица измерения после числа не обязательна
+	count, _, err := parseQuantity(input)
+	if err != nil {
+		b.sendCartPrompt(chatID, "Введите корректное положительное число:")
 		return nil
 	}
 
-	// Обрабатываем математическую операцию
-	newCount := int(cartItem.CountCart) // Текущее количество товара в корзине
-
-	itemPrice := cartItem.Price // Цена за единицу товара
-
-	var str string
-	var d decimal.Decimal
 	switch sign {
 	case "+":
-		newCount += count
-		d = itemPrice.Mul(decimal.NewFromInt(int64(count)))
-		str = "+" + d.String()
-		cart.Amount = cart.Amount.Add(d)
-		b.cartItems[chatID] = cart
-
+		err = c.Add(product.ProductID, count)
 	case "-":
-		newCount -= count
-		if newCount < 0 {
-			b.bot.Send(tgbotapi.NewMessage(chatID, "Количество не может быть отрицательным."))
-			return nil
-		}
-		d = itemPrice.Mul(decimal.NewFromInt(int64(count)))
-		str = "-" + d.String()
-		b.cartItems[chatID].Amount.Sub(d)
-		cart.Amount = cart.Amount.Sub(cartItem.Price)
-		b.cartItems[chatID] = cart
-
+		err = c.Remove(product.ProductID, count)
 	default:
-		delta := count - int(cartItem.CountCart)
-
-		itemPriceChange := cartItem.Price.Mul(decimal.NewFromInt(int64(abs(delta))))
-
-		if delta > 0 {
-			str = "+" + itemPriceChange.String()
-			cart.Amount = cart.Amount.Add(itemPriceChange)
-		} else if delta < 0 {
-			str = "-" + itemPriceChange.String()
-			cart.Amount = cart.Amount.Sub(itemPriceChange)
-		}
-
-		cartItem.CountCart = uint(count)
-		b.cartItems[chatID] = cart
-		newCount = count
+		err = c.SetQuantity(product.ProductID, count)
 	}
 
-	if newCount < 0 {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Результат не может быть отрицательным."))
+	switch err {
+	case nil:
+	case cart.ErrOutOfStock:
+		b.sendCartPrompt(chatID, fmt.Sprintf("Превышен остаток: %s %s", line.Stock, line.Unit))
 		return nil
-	}
-	if uint(newCount) > cartItem.CountStore {
-		b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Превышен остаток: %d", cartItem.CountStore)))
+	case cart.ErrInvalidQuantity:
+		b.sendCartPrompt(chatID, "Количество не может быть отрицательным.")
 		return nil
+	case cart.ErrFractionalQuantity:
+		b.sendCartPrompt(chatID, "Этот товар продаётся только целыми штуками.")
+		return nil
+	default:
+		return err
 	}
 
-	// Обновляем количество
-	cartItem.CountCart = uint(newCount)
-	if cartItem.MsgID == 0 {
-		cartItem.MsgID = message.MessageID
-	}
-	b.cartItems[chatID].CartItems[product.ProductID] = cartItem
-
-	b.getSellingKeyboard(chatID, str)
-
-	CountItemInCartKeyboard := b.getCountItemInCartKeyboard(chatID, product.ProductID)
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, cartItem.MsgID, CountItemInCartKeyboard)
-	_, err = b.bot.Send(msg)
-
 	delete(b.states, chatID)
-	return err
+	return b.refreshCartLine(chatID, product.ProductID)
 }
 
-func (b *Bot) handleRemoveItemFromCart(message *tgbotapi.Message) error {
-	chatID := message.Chat.ID
+func (b *Bot) handleRemoveItemFromCart(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
 	product := b.tempProduct[chatID]
+	c := b.cart(chatID)
 
-	cart, exists := b.cartItems[chatID]
-	if !exists {
-		b.bot.Send(tgbotapi.NewMessage(chatID, "Корзина не найдена:"))
+	if err := c.SetQuantity(product.ProductID, decimal.Zero); err != nil {
+		b.alert(callback, "Товар не найден")
 		return nil
 	}
 
-	var str string
-	cartItem, exists := cart.CartItems[product.ProductID]
-	if exists {
-		d := decimal.NewFromInt(int64(cartItem.CountCart)).Mul(cartItem.Price)
-		cartItem.CountCart = 0
-		str = "-" + d.String()
-		cart.Amount = cart.Amount.Sub(d)
-		b.cartItems[chatID] = cart
-	}
-
-	b.cartItems[chatID].CartItems[product.ProductID] = cartItem
-
-	b.getSellingKeyboard(chatID, str)
-
-	// Обновляем клавиатуру
-
-	CountItemInCartKeyboard := b.getAddItemToCartKeyboard(product.ProductID)
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, cartItem.MsgID, CountItemInCartKeyboard)
-	_, err := b.bot.Send(msg)
-
-	return err
-}
-
-func (b *Bot) cleanUpMessages(chatID int64, lastMsgID int) {
-	exceptMsgIDs := make(map[int]bool)
-	tmpMsg := b.tempMsgID[chatID]
-	if tmpMsg == 0 {
-		return
-	}
-
-	for _, cartItem := range b.cartItems[chatID].CartItems {
-		if cartItem.MsgID != 0 {
-			exceptMsgIDs[cartItem.MsgID] = true
-			exceptMsgIDs[cartItem.MsgID-1] = true
-		}
-	}
-
-	// Получаем диапазон сообщений
-	for i := lastMsgID; i > tmpMsg; i-- {
-		// Пропускаем сообщения, которые нужно оставить
-		if exceptMsgIDs[i] {
-			continue
-		}
-
-		// Удаляем текстовое сообщение
-		_, err := b.bot.DeleteMessage(tgbotapi.DeleteMessageConfig{
-			ChatID:    chatID,
-			MessageID: i,
-		})
-		if err != nil {
-			log.Printf("Не удалось удалить сообщение %d: %v", i, err)
-			continue
-		}
-	}
-
-}
-
-func (b *Bot) getSellingKeyboard(chatID int64, str string) (int, error) {
-	// Получаем текущую сумму корзины
-	amount := b.cartItems[chatID].Amount
-
-	// Создаём клавиатуру
-	buttons := tgbotapi.NewReplyKeyboard(
-		tgbotapi.NewKeyboardButtonRow(
-			tgbotapi.NewKeyboardButton(fmt.Sprintf("🛍 %s", amount.StringFixed(2))), // Форматируем сумму
-			tgbotapi.NewKeyboardButton("Отмена"),
-			tgbotapi.NewKeyboardButton("Оплата"),
-		),
-	)
-
-	// Настройки клавиатуры
-	buttons.OneTimeKeyboard = false
-	buttons.ResizeKeyboard = true
-
-	// Отправляем обновлённую клавиатуру
-	msg := tgbotapi.NewMessage(chatID, str) // Отправляем пустую строку вместо нового текста
-	msg.ReplyMarkup = buttons
-
-	messege, err := b.bot.Send(msg)
-	return messege.MessageID, err
-}
-
-func abs(x int) int {
-	if x < 0 {
-		return -x
-	}
-	return x
+	b.toast(callback, fmt.Sprintf("Удалено · 🛍 %s", c.Total().StringFixed(cart.Places)))
+	return b.refreshCartLine(chatID, product.ProductID)
 }
 
 // handleAddOrder сохраняем заказ
 func (b *Bot) handleAddOrder(callback *tgbotapi.CallbackQuery, payType string) error {
 
 	chatID := callback.Message.Chat.ID
-	cart := b.cartItems[chatID]
-	b.tempMsgID[chatID] = 0
+	c := b.cart(chatID)
+	if c.IsEmpty() {
+		b.alert(callback, "Корзина пуста")
+		return nil
+	}
+	if c.HasPending() {
+		b.alert(callback, "Есть скидки, ожидающие подтверждения администратора")
+		return nil
+	}
 
 	// Формируем список деталей заказа
-	details := make([]*storage.OrderDetail, 0, len(cart.CartItems))
-	for productID, item := range cart.CartItems {
-		factSum := item.Price.Mul(decimal.NewFromInt(int64(item.CountCart)))
+	lines := c.Lines()
+	details := make([]*storage.OrderDetail, 0, len(lines))
+	for _, line := range lines {
 		details = append(details, &storage.OrderDetail{
-			ProductID: productID,
-			Amount:    item.Price,
-			Count:     item.CountCart,
-			FactSum:   factSum,
+			ProductID: line.ProductID,
+			Amount:    line.Price(),
+			Count:     line.Quantity,
+			Discount:  line.Discount,
+			FactSum:   line.Total(),
+
+			ListPrice:      line.UnitPrice,
+			DiscountAmount: line.DiscountSum(),
 		})
 	}
 
 	// Создаём объект заказа
+	discount := c.OrderDiscount()
 	order := &storage.Order{
 		UserName: callback.From.UserName,
-		Amount:   cart.Amount,
+		Amount:   c.Total(),
 		Details:  details,
 		PayType:  &storage.PayType{Description: payType}, // Пример преобразования типа оплаты
+
+		Subtotal:        c.Subtotal(),
+		DiscountPercent: discount.Percent,
+		DiscountAmount:  c.OrderDiscountSum(),
+		PromoCode:       discount.PromoCode,
 	}
 
 	// Сохраняем заказ и детали через транзакцию
 	ctx := context.Background()
 	orderID, err := b.storage.AddOrderWithDetails(ctx, order)
+	if errors.Is(err, storage.ErrPromoCodeUnavailable) {
+		// Промокод истёк или исчерпан, пока собирали корзину
+		c.SetOrderDiscount(cart.OrderDiscount{})
+		b.alert(callback, "Промокод больше не действует, скидка снята")
+		return b.renderCart(chatID)
+	}
 	if err != nil {
-		b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка сохранения заказа: %v", err)))
+		b.alert(callback, fmt.Sprintf("Ошибка сохранения заказа: %v", err))
 		return err
 	}
-	// Очистка корзины
-	delete(b.cartItems, chatID)
+	// Фиксируем сводку корзины как чек и очищаем корзину
+	b.cleanUpMessages(chatID)
+	if id := b.tracker.summaryID(chatID); id != 0 {
+		text := fmt.Sprintf("✅ Заказ #%d\n\n%s", orderID, b.cartSummaryText(chatID))
+		if _, err := b.bot.Send(tgbotapi.NewEditMessageText(chatID, id, text)); err != nil {
+			log.Printf("не удалось обновить сводку корзины: %v", err)
+		}
+	}
+	b.tracker.forget(chatID)
+	delete(b.carts, chatID)
 
 	// Уведомление об успешном сохранении
+	b.toast(callback, "Оплачено")
 	b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Заказ #%d успешно сохранён!", orderID)))
 	return b.handleStartTxt(callback.Message)
 }
@@ -459,26 +351,29 @@ func (b *Bot) handleAddOrder(callback *tgbotapi.CallbackQuery, payType string) e
 // handleSelectPayType запрашиваем тип платежа
 func (b *Bot) handleSelectPayType(message *tgbotapi.Message) error {
 	chatID := message.Chat.ID
-	cart := b.cartItems[chatID]
-
-	if cart.Amount.IsZero() {
-		for _, cartItem := range cart.CartItems {
-			if cartItem.CountCart > 0 {
-				break
-			}
-			msg := tgbotapi.NewMessage(chatID, "Корзина пуста")
-			_, err := b.bot.Send(msg)
-			return err
-		}
+
+	if b.cart(chatID).IsEmpty() {
+		msg := tgbotapi.NewMessage(chatID, "Корзина пуста")
+		_, err := b.bot.Send(msg)
+		return err
+	}
+	if b.cart(chatID).HasPending() {
+		msg := tgbotapi.NewMessage(chatID, "Есть скидки, ожидающие подтверждения администратора")
+		_, err := b.bot.Send(msg)
+		return err
 	}
 
-	b.cleanUpMessages(chatID, message.MessageID)
+	b.cleanUpMessages(chatID)
 	msg := tgbotapi.NewMessage(chatID, "Способ оплаты:")
 	msg.ParseMode = "Markdown"
 	msg.ReplyMarkup = b.getPayTypesKeyboard()
 
-	_, err := b.bot.Send(msg)
-	return err
+	sent, err := b.bot.Send(msg)
+	if err != nil {
+		return err
+	}
+	b.tracker.track(chatID, sent.MessageID)
+	return nil
 }
 
 func (b *Bot) getPayTypesKeyboard() tgbotapi.InlineKeyboardMarkup {
@@ -2,71 +2,188 @@ package telegram
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"regexp"
+	"sort"
 	"strconv"
 
-	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+	"github.com/Bariban/vector-shop-bot/pkg/barcode"
 	"github.com/Bariban/vector-shop-bot/pkg/storage"
 	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
-	"github.com/shopspring/decimal"
 )
 
-type File struct {
-	FilePath string `json:"file_path"`
+// registerHandlers связывает команды и inline-кнопки с обработчиками
+func (b *Bot) registerHandlers() {
+	b.messageHandlers = map[string]Handler{
+		StartCmd:             onMessage(b.handleStart),
+		AddProductText:       onMessage(b.handleAddProductCmd),
+		PaymentText:          onMessage(b.handleSelectPayType),
+		CancelOperationsText: onMessage(b.handleCancelOperations),
+		MenuText:             onMessage(b.handleMenu),
+	}
+
+	// Команды с аргументами
+	b.commandHandlers = map[string]Handler{
+		PromoCmd:  onMessage(b.handleCreatePromoCode),
+		PromosCmd: onMessage(b.handlePromoCodeList),
+
+		CreateShopCmd: onMessage(b.handleCreateShop),
+		InviteUserCmd: onMessage(b.handleInviteUser),
+		ListUsersCmd:  onMessage(b.handleListUsers),
+
+		CategoryCmd:    onMessage(b.handleCreateCategory),
+		DelCategoryCmd: onMessage(b.handleRemoveCategory),
+		CategoriesCmd:  onMessage(b.handleCategoryList),
+		SalesCmd:       onMessage(b.handleSalesReport),
+
+		ImportCmd:        onMessage(b.handleImportCmd),
+		ExportCatalogCmd: onMessage(b.handleExportCatalog),
+		ExportOrdersCmd:  onMessage(b.handleExportOrders),
+		ReindexCmd:       onMessage(b.handleReindex),
+		DuplicatesCmd:    onMessage(b.handleDuplicates),
+	}
+
+	b.callbackHandlers = map[string]Handler{
+		AddProductCmd:            onCallback(b.handleAddProductCallback),
+		ListCmd:                  onCallback(b.handleProductList),
+		EditProductCmd:           onCallback(b.handleEditProductCmd),
+		ConfirmDelProductCmd:     onCallback(b.handleConfirmDeleteProductCmd),
+		DelProductCmd:            onCallback(b.handleDeleteProductCmd),
+		ActionsProductCmd:        onCallback(b.handleActionsProductmd),
+		EditProductNameCmd:       onCallback(b.handleToggleEditParam(EditProductNameCmd)),
+		EditProductCountCmd:      onCallback(b.handleToggleEditParam(EditProductCountCmd)),
+		EditProductPurchaseCmd:   onCallback(b.handleToggleEditParam(EditProductPurchaseCmd)),
+		EditProductSellingCmd:    onCallback(b.handleToggleEditParam(EditProductSellingCmd)),
+		ConfirmEditProductCmd:    onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handleConfirmEdit(callback.Message) }),
+		AddItemToCartCmd:         onCallback(b.handleAddItemToCart),
+		ReduceItemInCartCmd:      onCallback(b.handleReduceItemInCart),
+		EditCountItemInCartCmd:   onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handleEditCountItemInCart(callback.Message) }),
+		DiscountItemInCartCmd:    onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handleDiscoutItemInCart(callback.Message) }),
+		RemoveItemFromCartCmd:    onCallback(b.handleRemoveItemFromCart),
+		PaymentCmd:               onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handleSelectPayType(callback.Message) }),
+		CancelCartCmd:            onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handleCancelOperations(callback.Message) }),
+		OrderDiscountCmd:         onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handleOrderDiscount(callback.Message) }),
+		PromoCodeCmd:             onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handlePromoCode(callback.Message) }),
+		PayTypeCashCmd:           onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handleAddOrder(callback, PayTypeCashCmd) }),
+		ApproveDiscountCmd:       onCallback(b.handleDiscountDecision(true)),
+		RejectDiscountCmd:        onCallback(b.handleDiscountDecision(false)),
+		PhotosProductCmd:         onCallback(b.handleProductPhotos),
+		AddPhotoCmd:              onCallback(b.handleAddPhotoCallback),
+		DelPhotoCmd:              onCallback(b.handleDeletePhoto),
+		CatalogPageCmd:           onCallback(b.handleCatalogPage),
+		CatalogSearchCmd:         onCallback(func(callback *tgbotapi.CallbackQuery) error { return b.handleCatalogSearch(callback.Message) }),
+		CatalogResetCmd:          onCallback(b.handleCatalogReset),
+		CatalogItemCmd:           onCallback(b.handleCatalogItem),
+		CatalogCategoriesCmd:     onCallback(b.handleCatalogCategories),
+		CatalogCategoryCmd:       onCallback(b.handleCatalogCategory),
+		EditCategoryCmd:          onCallback(b.handleEditCategory),
+		PickCategoryCmd:          onCallback(b.handlePickCategory),
+		PickSuggestedCategoryCmd: onCallback(b.handlePickSuggestedCategory),
+		VariantsCmd:              onCallback(b.handleVariants),
+		AddVariantCmd:            onCallback(b.handleAddVariant),
+		DelVariantCmd:            onCallback(b.handleDelVariant),
+		PickVariantCmd:           onCallback(b.handlePickVariant),
+		ImportConfirmCmd:         onCallback(b.handleImportConfirm),
+		ImportCancelCmd:          onCallback(b.handleImportCancel),
+		MergeKeepFirstCmd:        onCallback(b.handleDuplicateDecision(true, true)),
+		MergeKeepSecondCmd:       onCallback(b.handleDuplicateDecision(true, false)),
+		MergeSkipCmd:             onCallback(b.handleDuplicateDecision(false, false)),
+	}
+	b.registerCatalogHandlers()
 }
 
-type getFileResponse struct {
-	Ok     bool  `json:"ok"`
-	Result *File `json:"result"`
+// route выбирает обработчик для обновления
+func (b *Bot) route(update tgbotapi.Update) error {
+	switch {
+	case update.Message != nil:
+		return b.routeMessage(update)
+	case update.CallbackQuery != nil && update.CallbackQuery.Message == nil:
+		return b.routeInlineCallback(update)
+	case update.CallbackQuery != nil:
+		return b.routeCallback(update)
+	case update.InlineQuery != nil:
+		return b.handleInlineQuery(update.InlineQuery)
+	default:
+		return nil
+	}
 }
 
-func (b *Bot) handleMessageCommand(message *tgbotapi.Message) error {
+func (b *Bot) routeMessage(update tgbotapi.Update) error {
+	message := update.Message
+	if h, ok := b.messageHandlers[message.Text]; ok {
+		return h(update)
+	}
 
-	chatID := message.Chat.ID
-	state := b.states[chatID]
-	switch message.Text {
-	case StartCmd:
-		return b.handleStartTxt(message)
-	case AddProductText:
-		return b.handleAddProductCmd(message)
-	case PaymentText:
-		return b.handleSelectPayType(message)
-	case CancelOperationsText:
-		return b.handleCancelOperations(message)
-	// case MenuText:
-	// 	return b.handleMenu(message)
-	default:
-		if addProductStates[state] {
-			return b.handleAddProductCmd(message)
+	if message.IsCommand() {
+		if h, ok := b.commandHandlers["/"+message.Command()]; ok {
+			return h(update)
 		}
+	}
 
-		if editProductStates[state] {
-			return b.handleConfirmEdit(message)
-		}
+	state := b.states[message.Chat.ID]
+	if addProductStates[state] {
+		return b.handleAddProductCmd(message)
+	}
 
-		if message.Photo != nil {
-			return b.handleSampleImage(message)
-		}
+	if editProductStates[state] {
+		return b.handleConfirmEdit(message)
+	}
 
-		if state == stateEditCountItemInCart {
-			return b.handleEditCountItemInCart(message)
-		}
+	if state == stateCatalogSearch {
+		return b.handleCatalogSearch(message)
+	}
 
-		if state == stateDiscountProductInCart {
-			return b.handleDiscoutItemInCart(message)
-		}
+	if state == stateWaitingForImport {
+		return b.handleImportFile(message)
+	}
+
+	if state == stateWaitingForVariant {
+		return b.handleVariantText(message)
+	}
 
-		return b.handleUnknownCmd(message)
+	if state == stateWaitingForExtraPhoto {
+		return b.handleExtraPhoto(message)
 	}
 
+	if message.Photo != nil {
+		return b.handleSampleImage(message)
+	}
+
+	if state == stateEditCountItemInCart {
+		return b.handleEditCountItemInCart(message)
+	}
+
+	if state == stateDiscountProductInCart {
+		return b.handleDiscoutItemInCart(message)
+	}
+
+	if state == stateOrderDiscount {
+		return b.handleOrderDiscount(message)
+	}
+
+	if state == statePromoCode {
+		return b.handlePromoCode(message)
+	}
+
+	return b.handleUnknownCmd(message)
+}
+
+// notProductCallbacks кнопки, у которых число в конце — не ID товара
+var notProductCallbacks = map[string]bool{
+	ApproveDiscountCmd: true,
+	RejectDiscountCmd:  true,
+	DelPhotoCmd:        true,
+	CatalogPageCmd:     true,
+	CatalogCategoryCmd: true,
+	PickCategoryCmd:    true,
+	MergeKeepFirstCmd:  true,
+	MergeKeepSecondCmd: true,
+	MergeSkipCmd:       true,
 }
 
-func (b *Bot) handleCallbackCommand(callback *tgbotapi.CallbackQuery) error {
+func (b *Bot) routeCallback(update tgbotapi.Update) error {
+	callback := update.CallbackQuery
 	chatID := callback.Message.Chat.ID
 	action := callback.Data
 	var productID int
@@ -76,69 +193,36 @@ func (b *Bot) handleCallbackCommand(callback *tgbotapi.CallbackQuery) error {
 
 	// Преобразуем найденное число в uint
 	if match != "" {
-		productID, _ = strconv.Atoi(match)
 		// Определяем действие (до числа)
-		product := &storage.Product{
-			ProductID: uint(productID),
-			UserName:  callback.From.UserName,
-			Image:     []*storage.ImageMeta{{}},
-		}
-		b.tempProduct[chatID] = product
 		action = action[:len(action)-len(match)-1]
+		if !notProductCallbacks[action] {
+			productID, _ = strconv.Atoi(match)
+			product := &storage.Product{
+				ProductID: uint(productID),
+				UserName:  callback.From.UserName,
+				Image:     []*storage.ImageMeta{{}},
+			}
+			b.tempProduct[chatID] = product
+		}
 	}
 
-	switch action {
-	case AddProductCmd:
-		chatID := callback.Message.Chat.ID
-		b.states[chatID] = stateWaitingForPhoto
-		product := &storage.Product{
-			UserName: callback.From.UserName,
-			Image:    []*storage.ImageMeta{{}},
-		}
-		b.tempProduct[chatID] = product
-		msg := tgbotapi.NewMessage(chatID, b.messages.Responses.SendPhoto)
-		_, err := b.bot.Send(msg)
-		return err
-	case ListCmd:
-		return b.handleProductList(callback)
-	case EditProductCmd:
-		return b.handleEditProductCmd(callback)
-	case ConfirmDelProductCmd:
-		return b.handleConfirmDeleteProductCmd(callback)
-	case DelProductCmd:
-		return b.handleDeleteProductCmd(callback)
-	case ActionsProductCmd:
-		return b.handleActionsProductmd(callback)
-	case EditProductNameCmd:
-		b.selectedParams[callback.Message.Chat.ID][action] = true
-		return b.handleEditProductCmd(callback)
-	case EditProductCountCmd:
-		b.selectedParams[callback.Message.Chat.ID][action] = true
-		return b.handleEditProductCmd(callback)
-	case EditProductPurchaseCmd:
-		b.selectedParams[callback.Message.Chat.ID][action] = true
-		return b.handleEditProductCmd(callback)
-	case EditProductSellingCmd:
-		b.selectedParams[callback.Message.Chat.ID][action] = true
-		return b.handleEditProductCmd(callback)
-	case ConfirmEditProductCmd:
-		return b.handleConfirmEdit(callback.Message)
-	case AddItemToCartCmd:
-		return b.handleAddItemToCart(callback)
-	case ReduceItemInCartCmd:
-		return b.handleReduceItemInCart(callback)
-	case EditCountItemInCartCmd:
-		return b.handleEditCountItemInCart(callback.Message)
-	case DiscountItemInCartCmd:
-		return b.handleDiscoutItemInCart(callback.Message)
-	case RemoveItemFromCartCmd:
-		return b.handleRemoveItemFromCart(callback.Message)
-	case PayTypeCashCmd:
-		return b.handleAddOrder(callback, action)
+	if h, ok := b.callbackHandlers[action]; ok {
+		return h(update)
+	}
+	return nil
+}
 
-	default:
-		return nil
+func (b *Bot) handleAddProductCallback(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	b.states[chatID] = stateWaitingForPhoto
+	product := &storage.Product{
+		UserName: callback.From.UserName,
+		Image:    []*storage.ImageMeta{{}},
 	}
+	b.tempProduct[chatID] = product
+	msg := tgbotapi.NewMessage(chatID, b.messages.Responses.SendPhoto)
+	_, err := b.bot.Send(msg)
+	return err
 }
 
 func (b *Bot) handleStartTxt(message *tgbotapi.Message) error {
@@ -160,6 +244,19 @@ func (b *Bot) handleStartTxt(message *tgbotapi.Message) error {
 	return err
 }
 
+// handleMenu показывает основные действия с каталогом
+func (b *Bot) handleMenu(message *tgbotapi.Message) error {
+	msg := tgbotapi.NewMessage(message.Chat.ID, "Меню")
+	msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("📋 Каталог", ListCmd),
+			tgbotapi.NewInlineKeyboardButtonData("➕ Добавить товар", AddProductCmd),
+		),
+	)
+	_, err := b.bot.Send(msg)
+	return err
+}
+
 func (b *Bot) handleUnknownCmd(message *tgbotapi.Message) error {
 	msg := tgbotapi.NewMessage(message.Chat.ID, b.messages.Responses.UnknownCommand)
 	_, err := b.bot.Send(msg)
@@ -172,155 +269,155 @@ func (b *Bot) handleActionsProductmd(callback *tgbotapi.CallbackQuery) error {
 
 	buttonDone := b.getProductActionKeyboard(product.ProductID)
 
-	msg := tgbotapi.NewEditMessageReplyMarkup(chatID, b.tempMsgID[chatID], buttonDone)
-	_, err := b.bot.Send(msg)
+	err := b.bot.EditMarkup(chatID, b.tempMsgID[chatID], buttonDone)
 	return err
 }
 
 // getFileMeta получает URL и вектор из fileID
 func (b *Bot) getFileMeta(fileID string) (*storage.ImageMeta, error) {
-	// URL для получения информации о файле
-	apiURL := fmt.Sprintf("https://api.telegram.org/bot%s/getFile?file_id=%s", "8015128447:AAHNjRFRjWP1LQ4nqePLtjJhaoiBo6BFIKA", fileID) //TODO cfg.TelegramToken
-
-	// Делаем запрос к Telegram API
-	resp, err := http.Get(apiURL)
+	url, err := b.bot.FileURL(fileID)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при запросе getFile: %w", err)
-	}
-	defer resp.Body.Close()
-
-	// Декодируем ответ
-	var result getFileResponse
-	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
-		return nil, fmt.Errorf("ошибка декодирования ответа getFile: %w", err)
-	}
-
-	// Проверяем успешность запроса
-	if !result.Ok || result.Result == nil {
-		return nil, fmt.Errorf("не удалось получить информацию о файле")
+		return nil, err
 	}
-
-	imageMeta := &storage.ImageMeta{}
-	// Генерируем URL для скачивания файла
-	imageMeta.Url = fmt.Sprintf("https://api.telegram.org/file/bot%s/%s", "8015128447:AAHNjRFRjWP1LQ4nqePLtjJhaoiBo6BFIKA", result.Result.FilePath) //TODO cfg.TelegramToken
-
-	imageMeta.Float, err = recognize.ExtractFromModel(imageMeta.Url)
+	image, err := b.recognizeImage(url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при получении вектора файла: %w", err)
+		return nil, err
 	}
-	return imageMeta, nil
+	image.SourceFileID = fileID
+	return image, nil
 }
 
-// getFileMeta получает контент из URL
-func (b *Bot) getFileContent(url string) ([]byte, error) {
-
-	// Скачиваем файл
-	fileResp, err := http.Get(url)
+// recognizeImage получает вектор, текст и штрихкод фото по URL. Байты фото скачиваются, если data пусто
+func (b *Bot) recognizeImage(url string, data []byte) (*storage.ImageMeta, error) {
+	response, err := b.recognizer.Recognize(url)
 	if err != nil {
-		return nil, fmt.Errorf("ошибка при загрузке файла: %w", err)
+		return nil, fmt.Errorf("ошибка при получении вектора файла: %w", err)
 	}
-	defer fileResp.Body.Close()
-
-	// Читаем содержимое файла в память
-	byte, err := io.ReadAll(fileResp.Body)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения содержимого файла: %w", err)
+	imageMeta := &storage.ImageMeta{
+		Url:          url,
+		Float:        response.Features,
+		Text:         response.ExtractedText,
+		Category:     response.BestCategory,
+		Similarities: response.Similarities,
+		Model:        b.recognizer.ActiveModel(),
 	}
 
-	return byte, nil
-}
-
-func (b *Bot) getProductsByVector(message *tgbotapi.Message, vector []float64) ([]*storage.Product, error) {
-	// Получение всех векторов изображений по имени пользователя
-	images, err := b.storage.GetVectorsByUsername(context.Background(), message.Chat.UserName)
-	if err != nil {
-		return nil, fmt.Errorf("ошибка чтения содержимого файла: %w", err)
+	// Штрихкод ищем по самому фото, байты пригодятся и при сохранении
+	imageMeta.Byte = data
+	if imageMeta.Byte == nil {
+		imageMeta.Byte, err = b.getFileContent(url)
+		if err != nil {
+			return nil, err
+		}
 	}
+	if code, err := barcode.Decode(imageMeta.Byte); err == nil {
+		imageMeta.Barcode = code
+	}
+	return imageMeta, nil
+}
 
-	var matchedProducts []*storage.Product
+// getFileContent получает контент из URL
+func (b *Bot) getFileContent(url string) ([]byte, error) {
+	return b.bot.DownloadFile(url)
+}
 
-	// Сравнение векторов
-	for _, image := range images {
-		ok, err := recognize.CompareFeatureVectors(vector, image.Float, 0.5)
+// findProducts ищет товары по фото: штрихкод совпадает точно и важнее похожести.
+// Похожие товары с другим штрихкодом заведомо другие и отбрасываются
+func (b *Bot) findProducts(message *tgbotapi.Message, sample *storage.ImageMeta) ([]*storage.Product, error) {
+	if sample.Barcode != "" {
+		product, err := b.storage.GetProductByBarcode(context.Background(), message.Chat.UserName, sample.Barcode)
 		if err != nil {
-			return nil, fmt.Errorf("ошибка при сравнении файлов: %w", err)
+			return nil, err
 		}
-		if ok {
-			// Получение товара по ID изображения
-			product, err := b.storage.GetProductByID(context.Background(), image.ProductID)
-			if err != nil || product == nil {
-				return nil, fmt.Errorf("ошибка получения товара по ID: %w", err)
+		if product != nil {
+			// У варианта нет своих фото, показываем фото основного товара
+			imagesOf := product.ProductID
+			if product.ParentID != 0 {
+				imagesOf = product.ParentID
 			}
-
-			if product.Image == nil {
-				product.Image = make([]*storage.ImageMeta, 0)
+			images, err := b.storage.GetImages(context.Background(), imagesOf)
+			if err != nil {
+				return nil, err
 			}
-			product.Image = append(product.Image, image)
-
-			// Добавляем продукт в итоговый список
-			matchedProducts = append(matchedProducts, product)
+			if len(images) > 0 {
+				product.Image = images[:1]
+			}
+			return []*storage.Product{product}, nil
 		}
 	}
 
-	return matchedProducts, nil
+	// Фото ещё не распознано: похожие по вектору искать не по чему
+	if sample.Float == nil {
+		return nil, nil
+	}
+	products, err := b.getProductsByVector(message, sample)
+	if err != nil || sample.Barcode == "" {
+		return products, err
+	}
+	filtered := products[:0]
+	for _, p := range products {
+		if p.Barcode == "" {
+			filtered = append(filtered, p)
+		}
+	}
+	return filtered, nil
 }
 
-func (b *Bot) handleProductList(callback *tgbotapi.CallbackQuery) error {
-	chatID := callback.Message.Chat.ID
-	userName := callback.From.UserName
-
-	// Получаем список продуктов для пользователя
-	products, err := b.storage.GetProducts(context.Background(), userName)
+// getProductsByVector ищет товары, похожие на фото: по вектору и по надписям на упаковке.
+// Самые похожие идут первыми
+func (b *Bot) getProductsByVector(message *tgbotapi.Message, sample *storage.ImageMeta) ([]*storage.Product, error) {
+	matches, complete, err := b.nearestImages(message.Chat.UserName, sample)
 	if err != nil {
-		msg := tgbotapi.NewMessage(chatID, "Не удалось получить список продуктов. Попробуйте позже.")
-		_, _ = b.bot.Send(msg)
-		return fmt.Errorf("ошибка получения продуктов: %w", err)
+		return nil, fmt.Errorf("ошибка чтения содержимого файла: %w", err)
 	}
 
-	if len(products) == 0 {
-		msg := tgbotapi.NewMessage(chatID, "У вас пока нет добавленных товаров.")
-		_, _ = b.bot.Send(msg)
-		return nil
+	// У товара может быть несколько фото, товар оценивается по самому похожему ракурсу
+	best := make(map[uint]*storage.ImageMeta)
+	scores := make(map[uint]float64)
+	for _, m := range matches {
+		image := m.image
+		score := b.metric.HybridDistance(m.distance, sample.Text, image.Text)
+		if score > b.matchThreshold {
+			continue
+		}
+		if prev, ok := scores[image.ProductID]; !ok || score < prev {
+			scores[image.ProductID] = score
+			best[image.ProductID] = image
+		}
 	}
 
-	// Формируем сообщение со списком товаров
-	for _, product := range products {
-		// Получаем изображения для каждого товара
-		photos, err := b.storage.GetPhotosByProductID(context.Background(), product.ProductID)
-		if err != nil {
-			log.Printf("не удалось получить фото для продукта %d: %v", product.ProductID, err)
-			continue // Продолжаем обрабатывать остальные товары
-		}
+	ids := make([]uint, 0, len(best))
+	for id := range best {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return scores[ids[i]] < scores[ids[j]] })
 
-		// Отправляем изображения (если есть)
-		for _, photo := range photos {
-			photoFile := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{
-				Name:  fmt.Sprintf("product_%d.jpg", product.ProductID),
-				Bytes: photo,
-			})
-			if _, err := b.bot.Send(photoFile); err != nil {
-				log.Printf("не удалось отправить фото: %v", err)
-			}
+	var matchedProducts []*storage.Product
+	for _, id := range ids {
+		// Получение товара по ID изображения
+		product, err := b.storage.GetProductByID(context.Background(), id)
+		if err != nil || product == nil {
+			return nil, fmt.Errorf("ошибка получения товара по ID: %w", err)
 		}
-
-		// Формируем текст с информацией о продукте
-		productInfo := fmt.Sprintf(
-			"🛒 *%s*\n📦 Наличие: %d\n💰 Цена продажи: %s\n",
-			product.Name,
-			product.Count,
-			product.SellingPrice.StringFixed(2),
-		)
-
-		// Отправляем информацию о продукте
-		msg := tgbotapi.NewMessage(chatID, productInfo)
-		msg.ParseMode = "Markdown"
-		if _, err := b.bot.Send(msg); err != nil {
-			log.Printf("не удалось отправить информацию о продукте: %v", err)
+		image := best[id]
+		if !complete {
+			// Индекс хранит только векторы, ключи фото для отправки читаются из базы
+			full, err := b.storage.GetImage(context.Background(), image.ImageID)
+			if err != nil {
+				return nil, fmt.Errorf("ошибка получения фото товара: %w", err)
+			}
+			if full == nil {
+				continue // фото удалили после поиска
+			}
+			image = full
 		}
+		product.Image = []*storage.ImageMeta{image}
+		matchedProducts = append(matchedProducts, product)
 	}
 
-	return nil
+	return matchedProducts, nil
 }
+
 func (b *Bot) handleSampleImage(message *tgbotapi.Message) error {
 	chatID := message.Chat.ID
 	if b.states[chatID] != stateWaitingForPhoto {
@@ -330,7 +427,7 @@ func (b *Bot) handleSampleImage(message *tgbotapi.Message) error {
 			_, _ = b.bot.Send(msg)
 			return err
 		}
-		foundProduct, err := b.getProductsByVector(message, imageMeta.Float)
+		foundProduct, err := b.findProducts(message, imageMeta)
 		if err != nil {
 			msg := tgbotapi.NewMessage(chatID, "Ошибка обработки фото.")
 			_, _ = b.bot.Send(msg)
@@ -341,58 +438,53 @@ func (b *Bot) handleSampleImage(message *tgbotapi.Message) error {
 			for _, product := range foundProduct {
 
 				// Отправляем изображения (если есть)
+				var cardMsgIDs []int
 				for _, photo := range product.Image {
-					photoFile := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{
-						Name:  fmt.Sprintf("product_%d.jpg", product.ProductID),
-						Bytes: photo.Byte,
-					})
-					if _, err := b.bot.Send(photoFile); err != nil {
+					sent, err := b.sendImage(chatID, photo, true, "", nil)
+					if err != nil {
 						log.Printf("не удалось отправить фото: %v", err)
+						continue
 					}
+					cardMsgIDs = append(cardMsgIDs, sent.MessageID)
 				}
 
 				// Формируем текст с информацией о продукте
-				productInfo := fmt.Sprintf(
-					"🛒 *%s*\n📦 Наличие: %d\n💰 Цена продажи: %s\n",
-					product.Name,
-					product.Count,
-					product.SellingPrice.StringFixed(2),
-				)
+				productInfo := productCardText(product)
 
-				cart, exists := b.cartItems[chatID]
-				if !exists {
-					cart = Cart{
-						Amount:    decimal.NewFromInt(0),
-						CartItems: make(map[uint]CartItem),
-					}
+				variants, err := b.storage.GetVariants(context.Background(), product.ProductID)
+				if err != nil {
+					return err
 				}
 
-				b.cartItems[chatID] = cart
+				// Товар с вариантами попадает в корзину только после выбора варианта
+				c := b.cart(chatID)
+				line, exists := c.Line(product.ProductID)
+				if len(variants) == 0 {
+					c.Put(productLine(product))
+				}
 
-				cartItem, exists := b.cartItems[chatID].CartItems[product.ProductID]
-				if exists {
-					if cartItem.CountCart == product.Count {
+				// Повторное фото товара из корзины добавляет ещё одну единицу
+				if exists && line.Quantity.IsPositive() {
+					if err := c.Add(product.ProductID, one); err != nil {
 						msg := tgbotapi.NewMessage(chatID, "Товар закончился")
 						_, err = b.bot.Send(msg)
 						return err
-					} else {
-						cartItem.CountStore = product.Count
-						cartItem.CountCart++
-					}
-				} else {
-					cartItem = CartItem{
-						MsgID:      0,
-						CountStore: product.Count,
-						CountCart:  0,
-						Price:      product.SellingPrice,
-						PriceStore: product.SellingPrice,
 					}
 				}
 
-				b.cartItems[chatID].CartItems[product.ProductID] = cartItem
-
 				actionsProductKeyboard := b.getProductActionKeyboard(product.ProductID)
+				if product.ParentID != 0 {
+					// Вариант, найденный по штрихкоду, редактируется из списка вариантов товара
+					actionsProductKeyboard = tgbotapi.NewInlineKeyboardMarkup()
+				}
 				addProductToCartKeyboard := b.getAddItemToCartKeyboard(product.ProductID)
+				if exists && line.Quantity.IsPositive() {
+					addProductToCartKeyboard = b.getCountItemInCartKeyboard(chatID, product.ProductID)
+				}
+				if len(variants) > 0 {
+					productInfo += "\nВыберите вариант:\n" + variantLines(variants)
+					addProductToCartKeyboard = getVariantPickKeyboard(variants)
+				}
 
 				mergedKeyboard := tgbotapi.NewInlineKeyboardMarkup(
 					append(actionsProductKeyboard.InlineKeyboard,
@@ -404,12 +496,19 @@ func (b *Bot) handleSampleImage(message *tgbotapi.Message) error {
 				msg.ParseMode = "Markdown"
 				msg.ReplyMarkup = mergedKeyboard
 
-				_, err := b.bot.Send(msg)
+				sent, err := b.bot.Send(msg)
 				if err != nil {
 					log.Printf("не удалось отправить информацию о продукте: %v", err)
 					return err
 				}
 
+				// Запоминаем карточку, чтобы она пережила очистку чата
+				b.tracker.rememberCard(chatID, product.ProductID, append(cardMsgIDs, sent.MessageID)...)
+				if exists && line.Quantity.IsPositive() {
+					if err := b.renderCart(chatID); err != nil {
+						return err
+					}
+				}
 			}
 			return err
 		}
@@ -425,10 +524,16 @@ func (b *Bot) handleSampleImage(message *tgbotapi.Message) error {
 func (b *Bot) handleCancelOperations(message *tgbotapi.Message) error {
 	chatID := message.Chat.ID
 
+	if _, ok := b.carts[chatID]; ok {
+		b.clearCartView(chatID)
+	}
 	delete(b.states, chatID)
 	delete(b.tempProduct, chatID)
-	delete(b.cartItems, chatID)
+	delete(b.carts, chatID)
 	delete(b.selectedParams, chatID)
 	delete(b.tempMsgID, chatID)
+	delete(b.imports, chatID)
+	delete(b.recognitions, chatID)
+	delete(b.duplicates, chatID)
 	return nil
 }
@@ -0,0 +1,527 @@
+package telegram
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/barcode"
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	"github.com/Bariban/vector-shop-bot/pkg/table"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// maxImportRows товаров в одном файле импорта
+	maxImportRows = 2000
+	// maxImportPhotoSize наибольший размер одного фото из ZIP
+	maxImportPhotoSize = 10 << 20
+	// importPreviewRows и importReportErrors сколько товаров и ошибок показать в предпросмотре
+	importPreviewRows  = 10
+	importReportErrors = 20
+)
+
+// Колонки таблицы импорта
+const (
+	importName = iota
+	importDescription
+	importCount
+	importUnit
+	importPurchase
+	importSelling
+	importBarcode
+	importCategory
+	importImage
+)
+
+// importHeaders названия колонок в первой строке таблицы, регистр не важен
+var importHeaders = map[string]int{
+	"name": importName, "название": importName, "наименование": importName, "товар": importName,
+	"description": importDescription, "описание": importDescription,
+	"count": importCount, "quantity": importCount, "количество": importCount, "остаток": importCount,
+	"unit": importUnit, "единица": importUnit, "ед. изм.": importUnit, "ед.изм.": importUnit,
+	"purchase_price": importPurchase, "purchase price": importPurchase, "цена закупки": importPurchase, "цена закупа": importPurchase, "закуп": importPurchase,
+	"selling_price": importSelling, "selling price": importSelling, "price": importSelling, "цена продажи": importSelling, "цена": importSelling,
+	"barcode": importBarcode, "ean": importBarcode, "штрихкод": importBarcode,
+	"category": importCategory, "категория": importCategory,
+	"image": importImage, "photo": importImage, "фото": importImage, "изображение": importImage,
+}
+
+// importRow проверенная строка таблицы
+type importRow struct {
+	line     int
+	product  *storage.Product
+	category []string
+	image    string // URL фото или имя файла в ZIP
+}
+
+// importBatch разобранный файл импорта, ждущий подтверждения
+type importBatch struct {
+	fileName string
+	total    int
+	rows     []*importRow
+	errors   []string
+	photos   map[string][]byte // фото из ZIP по имени файла в нижнем регистре
+}
+
+// handleImportCmd объясняет формат файла и ждёт его
+func (b *Bot) handleImportCmd(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	b.states[chatID] = stateWaitingForImport
+	msg := tgbotapi.NewMessage(chatID, "📥 Отправьте файл CSV или XLSX с товарами.\n\n"+
+		"Первая строка — заголовки: название, описание, количество, единица, цена закупки, цена продажи, штрихкод, категория, фото.\n"+
+		"Обязательны название и цена продажи. Категория пишется через «/»: Косметика/Волосы.\n"+
+		"В колонке фото — ссылка на изображение или имя файла: тогда пришлите ZIP с таблицей и фото.")
+	_, err := b.bot.Send(msg)
+	return err
+}
+
+// handleImportFile разбирает и проверяет присланный файл и показывает предпросмотр импорта
+func (b *Bot) handleImportFile(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	if message.Document == nil {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Отправьте файл CSV, XLSX или ZIP. Чтобы выйти из импорта, нажмите «Отмена»."))
+		return err
+	}
+
+	url, err := b.bot.FileURL(message.Document.FileID)
+	if err != nil {
+		return err
+	}
+	data, err := b.getFileContent(url)
+	if err != nil {
+		return err
+	}
+
+	rows, photos, err := readImportFile(message.Document.FileName, data)
+	if err != nil {
+		_, _ = b.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось прочитать файл: "+err.Error()))
+		return err
+	}
+	batch, err := b.parseImport(message.Chat.UserName, rows, photos)
+	if err != nil {
+		_, err = b.bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return err
+	}
+	batch.fileName = message.Document.FileName
+
+	delete(b.states, chatID)
+	b.imports[chatID] = batch
+
+	msg := tgbotapi.NewMessage(chatID, importPreviewText(batch))
+	if len(batch.rows) > 0 {
+		msg.ReplyMarkup = tgbotapi.NewInlineKeyboardMarkup(
+			tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Загрузить %d", len(batch.rows)), ImportConfirmCmd),
+				tgbotapi.NewInlineKeyboardButtonData("❌ Отмена", ImportCancelCmd),
+			),
+		)
+	}
+	_, err = b.bot.Send(msg)
+	return err
+}
+
+// readImportFile читает таблицу из CSV, XLSX или ZIP, в котором лежат таблица и фото
+func readImportFile(name string, data []byte) ([][]string, map[string][]byte, error) {
+	if strings.ToLower(path.Ext(name)) != ".zip" {
+		rows, err := table.Read(name, data)
+		if errors.Is(err, table.ErrUnsupported) {
+			return nil, nil, fmt.Errorf("нужен файл CSV, XLSX или ZIP")
+		}
+		return rows, nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("повреждённый ZIP: %w", err)
+	}
+	var rows [][]string
+	photos := make(map[string][]byte)
+	for _, f := range zr.File {
+		base := path.Base(f.Name)
+		if f.FileInfo().IsDir() || strings.HasPrefix(base, ".") || strings.HasPrefix(f.Name, "__MACOSX/") {
+			continue
+		}
+		isTable := table.IsTable(base)
+		switch strings.ToLower(path.Ext(base)) {
+		case ".jpg", ".jpeg", ".png":
+		default:
+			if !isTable {
+				continue
+			}
+		}
+
+		content, err := readZipFile(f)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !isTable {
+			photos[strings.ToLower(base)] = content
+			continue
+		}
+		if rows != nil {
+			return nil, nil, fmt.Errorf("в ZIP должна быть одна таблица")
+		}
+		if rows, err = table.Read(base, content); err != nil {
+			return nil, nil, err
+		}
+	}
+	if rows == nil {
+		return nil, nil, fmt.Errorf("в ZIP нет таблицы CSV или XLSX")
+	}
+	return rows, photos, nil
+}
+
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, fmt.Errorf("не удалось распаковать %s: %w", f.Name, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxImportPhotoSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("не удалось распаковать %s: %w", f.Name, err)
+	}
+	if len(data) > maxImportPhotoSize {
+		return nil, fmt.Errorf("файл %s больше %d МБ", f.Name, maxImportPhotoSize>>20)
+	}
+	return data, nil
+}
+
+// parseImport проверяет строки таблицы: ошибки собираются по каждой строке,
+// а в batch.rows попадают только строки без ошибок
+func (b *Bot) parseImport(userName string, rows [][]string, photos map[string][]byte) (*importBatch, error) {
+	header := -1
+	for i, row := range rows {
+		if !isEmptyRow(row) {
+			header = i
+			break
+		}
+	}
+	if header < 0 {
+		return nil, fmt.Errorf("Файл пустой")
+	}
+
+	columns := make(map[int]int)
+	for i, title := range rows[header] {
+		if field, ok := importHeaders[strings.ToLower(strings.TrimSpace(title))]; ok {
+			if _, dup := columns[field]; !dup {
+				columns[field] = i
+			}
+		}
+	}
+	for field, title := range map[int]string{importName: "название", importSelling: "цена продажи"} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("В первой строке нет колонки «%s»", title)
+		}
+	}
+
+	batch := &importBatch{photos: photos}
+	barcodes := make(map[string]int)
+	for i := header + 1; i < len(rows); i++ {
+		if isEmptyRow(rows[i]) {
+			continue
+		}
+		batch.total++
+		if batch.total > maxImportRows {
+			return nil, fmt.Errorf("В файле больше %d товаров, разделите его на части", maxImportRows)
+		}
+
+		cell := func(field int) string {
+			col, ok := columns[field]
+			if !ok || col >= len(rows[i]) {
+				return ""
+			}
+			return strings.TrimSpace(rows[i][col])
+		}
+		row, problems := parseImportRow(userName, cell, photos)
+		row.line = i + 1
+
+		if code := row.product.Barcode; code != "" {
+			if line, ok := barcodes[code]; ok {
+				problems = append(problems, fmt.Sprintf("штрихкод %s уже в строке %d", code, line))
+			} else {
+				barcodes[code] = row.line
+				existing, err := b.storage.GetProductByBarcode(context.Background(), userName, code)
+				if err != nil {
+					return nil, err
+				}
+				if existing != nil {
+					problems = append(problems, fmt.Sprintf("штрихкод %s уже у товара «%s»", code, existing.Name))
+				}
+			}
+		}
+
+		if len(problems) > 0 {
+			batch.errors = append(batch.errors, fmt.Sprintf("Строка %d: %s", row.line, strings.Join(problems, "; ")))
+			continue
+		}
+		batch.rows = append(batch.rows, row)
+	}
+	return batch, nil
+}
+
+// parseImportRow разбирает одну строку таблицы и возвращает найденные в ней ошибки
+func parseImportRow(userName string, cell func(field int) string, photos map[string][]byte) (*importRow, []string) {
+	var problems []string
+	product := &storage.Product{
+		UserName:    userName,
+		Name:        cell(importName),
+		Description: cell(importDescription),
+		Unit:        storage.UnitPiece,
+	}
+	row := &importRow{product: product, category: parseCategoryPath(cell(importCategory))}
+
+	if product.Name == "" {
+		problems = append(problems, "не указано название")
+	}
+
+	if text := cell(importCount); text != "" {
+		count, unit, err := parseQuantity(text)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("количество «%s» — не число", text))
+		}
+		product.Count = count
+		if unit != "" {
+			product.Unit = unit
+		}
+	}
+	if text := cell(importUnit); text != "" {
+		unit, ok := storage.ParseUnit(text)
+		if !ok {
+			problems = append(problems, fmt.Sprintf("неизвестная единица «%s»", text))
+		}
+		product.Unit = unit
+	}
+	if !storage.Fractional(product.Unit) && !product.Count.IsInteger() {
+		problems = append(problems, "дробное количество у штучного товара")
+	}
+
+	var err error
+	if product.PurchasePrice, err = parseImportPrice(cell(importPurchase), false); err != nil {
+		problems = append(problems, "цена закупки "+err.Error())
+	}
+	if product.SellingPrice, err = parseImportPrice(cell(importSelling), true); err != nil {
+		problems = append(problems, "цена продажи "+err.Error())
+	}
+
+	if code := cell(importBarcode); code != "" {
+		if !barcode.Valid(code) {
+			problems = append(problems, fmt.Sprintf("штрихкод «%s» не EAN-13", code))
+		}
+		product.Barcode = code
+	}
+
+	if image := cell(importImage); image != "" {
+		row.image = image
+		if !isImageURL(image) {
+			if photos == nil {
+				problems = append(problems, fmt.Sprintf("фото «%s»: пришлите ZIP с таблицей и фото", image))
+			} else if _, ok := photos[strings.ToLower(path.Base(image))]; !ok {
+				problems = append(problems, fmt.Sprintf("фото «%s» нет в ZIP", image))
+			}
+		}
+	}
+	return row, problems
+}
+
+// parseImportPrice разбирает цену: пробелы между разрядами и запятая допустимы
+func parseImportPrice(text string, required bool) (decimal.Decimal, error) {
+	if text == "" {
+		if required {
+			return decimal.Zero, fmt.Errorf("не указана")
+		}
+		return decimal.Zero, nil
+	}
+	clean := strings.NewReplacer(" ", "", " ", "", ",", ".").Replace(text)
+	price, err := decimal.NewFromString(clean)
+	if err != nil {
+		return decimal.Zero, fmt.Errorf("«%s» — не число", text)
+	}
+	if price.IsNegative() || (required && price.IsZero()) {
+		return decimal.Zero, fmt.Errorf("«%s» должна быть больше нуля", text)
+	}
+	return price, nil
+}
+
+func isImageURL(s string) bool {
+	return strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://")
+}
+
+func isEmptyRow(row []string) bool {
+	for _, c := range row {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// importPreviewText отчёт о проверке файла: ошибки по строкам и первые товары
+func importPreviewText(batch *importBatch) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "📥 Импорт из «%s»\nТоваров в файле: %d, готово к загрузке: %d, с ошибками: %d\n",
+		batch.fileName, batch.total, len(batch.rows), len(batch.errors))
+
+	if len(batch.errors) > 0 {
+		sb.WriteString("\n⚠️ Строки с ошибками не будут загружены:\n")
+		for i, e := range batch.errors {
+			if i == importReportErrors {
+				fmt.Fprintf(&sb, "… и ещё %d\n", len(batch.errors)-i)
+				break
+			}
+			sb.WriteString(e + "\n")
+		}
+	}
+
+	photos := 0
+	for i, row := range batch.rows {
+		if row.image != "" {
+			photos++
+		}
+		if i == 0 {
+			sb.WriteString("\nТовары:\n")
+		}
+		if i < importPreviewRows {
+			p := row.product
+			fmt.Fprintf(&sb, "%d. %s — %s, %s", i+1, p.Name, formatQuantity(p.Count, p.Unit), p.SellingPrice.StringFixed(cart.Places))
+			if len(row.category) > 0 {
+				sb.WriteString(" · " + strings.Join(row.category, "/"))
+			}
+			if row.image != "" {
+				sb.WriteString(" · 📷")
+			}
+			sb.WriteString("\n")
+		}
+	}
+	if len(batch.rows) > importPreviewRows {
+		fmt.Fprintf(&sb, "… и ещё %d\n", len(batch.rows)-importPreviewRows)
+	}
+	if photos > 0 {
+		fmt.Fprintf(&sb, "\n📷 Фото: %d, они обработаются в фоне после загрузки.", photos)
+	}
+	return sb.String()
+}
+
+// handleImportConfirm сохраняет проверенные товары одной транзакцией и запускает обработку фото
+func (b *Bot) handleImportConfirm(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	batch, ok := b.imports[chatID]
+	if !ok {
+		b.alert(callback, "Импорт не найден, отправьте файл заново")
+		return nil
+	}
+	delete(b.imports, chatID)
+	userName := callback.From.UserName
+
+	// Категории создаются в той же транзакции, что и товары
+	products := make([]*storage.Product, len(batch.rows))
+	categories := make([][]string, len(batch.rows))
+	for i, row := range batch.rows {
+		row.product.UserName = userName
+		products[i] = row.product
+		categories[i] = row.category
+	}
+
+	if err := b.storage.ImportProducts(context.Background(), products, categories); err != nil {
+		b.alert(callback, "Импорт не выполнен")
+		text := "Импорт не выполнен, ни один товар не сохранён."
+		if errors.Is(err, storage.ErrBarcodeExists) {
+			text += " Штрихкод из файла уже занят, проверьте файл и отправьте его заново: /import"
+		}
+		_, _ = b.bot.Send(tgbotapi.NewMessage(chatID, text))
+		return err
+	}
+
+	b.toast(callback, "Загружено")
+	done := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData(fmt.Sprintf("✅ Загружено %d", len(products)), "done"),
+	))
+	if err := b.bot.EditMarkup(chatID, callback.Message.MessageID, done); err != nil {
+		log.Printf("не удалось обновить предпросмотр импорта: %v", err)
+	}
+
+	var withPhotos []*importRow
+	for _, row := range batch.rows {
+		if row.image != "" {
+			withPhotos = append(withPhotos, row)
+		}
+	}
+	text := fmt.Sprintf("✅ Загружено товаров: %d", len(products))
+	if len(withPhotos) > 0 {
+		text += fmt.Sprintf("\n📷 Сохраняем фото: %d. Пока фото не распознаны, эти товары не находятся по фото.", len(withPhotos))
+		go b.importPhotos(chatID, withPhotos, batch.photos)
+	}
+	_, err := b.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// handleImportCancel отменяет импорт после предпросмотра
+func (b *Bot) handleImportCancel(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	delete(b.imports, chatID)
+	b.toast(callback, "Импорт отменён")
+	return b.bot.EditMarkup(chatID, callback.Message.MessageID, tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("Отменено", "done")),
+	))
+}
+
+// importPhotos в фоне сохраняет фото загруженных товаров и ставит их в очередь индексации.
+// Использует только потокобезопасные части бота: мессенджер, хранилища и очередь
+func (b *Bot) importPhotos(chatID int64, rows []*importRow, photos map[string][]byte) {
+	var failed []string
+	for _, row := range rows {
+		if err := b.importPhoto(chatID, row, photos); err != nil {
+			log.Printf("импорт фото строки %d: %v", row.line, err)
+			failed = append(failed, fmt.Sprintf("Строка %d (%s): %v", row.line, row.product.Name, err))
+		}
+	}
+
+	text := fmt.Sprintf("📷 Фото сохранены: %d из %d, товары начнут находиться по ним после распознавания в фоне", len(rows)-len(failed), len(rows))
+	if len(failed) > 0 {
+		text += "\n\nНе удалось сохранить:\n" + strings.Join(failed, "\n")
+	}
+	if _, err := b.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("не удалось отправить итог импорта фото: %v", err)
+	}
+}
+
+// importPhoto сохраняет фото товара и ставит его в очередь индексации. Фото по ссылке
+// скачает воркер. CLIP-сервис принимает только ссылки, поэтому фото из ZIP временно
+// отправляется в чат: по его file_id воркер получит ссылку Telegram
+func (b *Bot) importPhoto(chatID int64, row *importRow, photos map[string][]byte) error {
+	image := &storage.ImageMeta{Url: row.image}
+	if !isImageURL(row.image) {
+		name := path.Base(row.image)
+		image.Url, image.Byte = "", photos[strings.ToLower(name)]
+		fileID, err := b.uploadTempPhoto(chatID, name, image.Byte)
+		if err != nil {
+			return err
+		}
+		image.SourceFileID = fileID
+	}
+
+	product := &storage.Product{
+		ProductID: row.product.ProductID,
+		UserName:  row.product.UserName,
+		Image:     []*storage.ImageMeta{image},
+	}
+	if err := b.storeImages(product.Image); err != nil {
+		return err
+	}
+	if err := b.storage.SaveImage(context.Background(), product); err != nil {
+		return err
+	}
+	if b.indexLater(product.Image) == 0 {
+		return fmt.Errorf("не удалось поставить фото в очередь")
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package telegram
+
+import (
+	"reflect"
+	"testing"
+)
+
+// Строки без штрихкодов не обращаются к базе, поэтому бот собирается без хранилища
+func TestParseImportHeaders(t *testing.T) {
+	tests := []struct {
+		name    string
+		rows    [][]string
+		names   []string
+		prices  []string
+		lines   []int
+		errors  int
+		wantErr bool
+	}{
+		{
+			name:   "russian headers",
+			rows:   [][]string{{"Название", "Цена продажи"}, {"Мыло", "100"}},
+			names:  []string{"Мыло"},
+			prices: []string{"100"},
+			lines:  []int{2},
+		},
+		{
+			name:   "english headers with spaces and case",
+			rows:   [][]string{{" NAME ", "Selling_Price", "unknown"}, {"Soap", "99.5", "x"}},
+			names:  []string{"Soap"},
+			prices: []string{"99.5"},
+			lines:  []int{2},
+		},
+		{
+			name:   "columns in any order",
+			rows:   [][]string{{"штрихкод", "цена", "описание", "товар"}, {"", "1 200,50", "для рук", "Крем"}},
+			names:  []string{"Крем"},
+			prices: []string{"1200.5"},
+			lines:  []int{2},
+		},
+		{
+			name:   "first of duplicate columns wins",
+			rows:   [][]string{{"название", "цена", "наименование"}, {"Мыло", "10", "Шампунь"}},
+			names:  []string{"Мыло"},
+			prices: []string{"10"},
+			lines:  []int{2},
+		},
+		{
+			name:   "empty rows before header and between products",
+			rows:   [][]string{nil, {"", " "}, {"название", "цена"}, {"Мыло", "10"}, {}, {"", ""}, {"Крем", "20"}},
+			names:  []string{"Мыло", "Крем"},
+			prices: []string{"10", "20"},
+			lines:  []int{4, 7},
+		},
+		{
+			name:   "short rows",
+			rows:   [][]string{{"название", "описание", "цена"}, {"Мыло"}, {"Крем", "", "20"}},
+			names:  []string{"Крем"},
+			prices: []string{"20"},
+			lines:  []int{3},
+			errors: 1,
+		},
+		{
+			name:    "no selling price column",
+			rows:    [][]string{{"название", "цена закупки"}, {"Мыло", "10"}},
+			wantErr: true,
+		},
+		{
+			name:    "no name column",
+			rows:    [][]string{{"описание", "цена"}, {"Мыло", "10"}},
+			wantErr: true,
+		},
+		{
+			name:    "empty file",
+			rows:    [][]string{nil, {""}},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &Bot{}
+			batch, err := b.parseImport("seller", tt.rows, nil)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("parseImport() = %+v, want error", batch)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var names, prices []string
+			var lines []int
+			for _, row := range batch.rows {
+				names = append(names, row.product.Name)
+				prices = append(prices, row.product.SellingPrice.String())
+				lines = append(lines, row.line)
+			}
+			if !reflect.DeepEqual(names, tt.names) || !reflect.DeepEqual(prices, tt.prices) || !reflect.DeepEqual(lines, tt.lines) {
+				t.Errorf("parseImport() rows = %q %q %v, want %q %q %v", names, prices, lines, tt.names, tt.prices, tt.lines)
+			}
+			if len(batch.errors) != tt.errors {
+				t.Errorf("parseImport() errors = %q, want %d", batch.errors, tt.errors)
+			}
+			if batch.total != len(tt.names)+tt.errors {
+				t.Errorf("parseImport() total = %d, want %d", batch.total, len(tt.names)+tt.errors)
+			}
+		})
+	}
+}
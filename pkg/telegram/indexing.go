@@ -0,0 +1,238 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/Bariban/vector-shop-bot/pkg/barcode"
+	"github.com/Bariban/vector-shop-bot/pkg/blob"
+	"github.com/Bariban/vector-shop-bot/pkg/jobs"
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// recognition распознавание фото, запущенное в фоне. Результат переносится в фото
+// только из горутины обработки обновлений, после завершения распознавания
+type recognition struct {
+	image    *storage.ImageMeta
+	model    string // модель, которой помечается вектор
+	done     chan struct{}
+	response *recognize.Response
+	err      error
+
+	message  *tgbotapi.Message // сообщение с первым фото мастера
+	promptID int               // вопрос о названии, в который дописываются подсказки
+}
+
+// startRecognition отправляет фото в CLIP-сервис, не дожидаясь ответа
+func (b *Bot) startRecognition(image *storage.ImageMeta) *recognition {
+	r := &recognition{image: image, model: b.recognizer.ActiveModel(), done: make(chan struct{})}
+	go func() {
+		defer close(r.done)
+		r.response, r.err = b.recognizer.Recognize(image.Url)
+		if r.err == nil && len(r.response.Features) == 0 {
+			r.err = fmt.Errorf("CLIP-сервис вернул пустой вектор")
+		}
+		if r.err != nil {
+			log.Printf("фото не распознано сразу, проиндексируется после сохранения: %v", r.err)
+		}
+	}()
+	return r
+}
+
+// notifyRecognized передаёт распознавание в цикл обработки обновлений, когда оно завершится
+func (b *Bot) notifyRecognized(r *recognition) {
+	go func() {
+		<-r.done
+		b.recognized <- r
+	}()
+}
+
+// apply переносит результат в фото, если распознавание уже завершилось успешно
+func (r *recognition) apply() bool {
+	select {
+	case <-r.done:
+	default:
+		return false
+	}
+	if r.err != nil {
+		return false
+	}
+	r.image.Float = r.response.Features
+	r.image.Text = r.response.ExtractedText
+	r.image.Category = r.response.BestCategory
+	r.image.Similarities = r.response.Similarities
+	r.image.Model = r.model
+	return true
+}
+
+// applyRecognitions переносит готовые результаты распознавания в фото мастера
+func (b *Bot) applyRecognitions(chatID int64) {
+	for _, r := range b.recognitions[chatID] {
+		r.apply()
+	}
+}
+
+// receivePhoto получает присланное фото без распознавания: ссылку, байты и штрихкод.
+// Штрихкод декодируется на месте, CLIP-сервис для этого не нужен
+func (b *Bot) receivePhoto(fileID string) (*storage.ImageMeta, error) {
+	url, err := b.bot.FileURL(fileID)
+	if err != nil {
+		return nil, err
+	}
+	data, err := b.getFileContent(url)
+	if err != nil {
+		return nil, err
+	}
+	image := &storage.ImageMeta{Url: url, SourceFileID: fileID, Byte: data}
+	if code, err := barcode.Decode(data); err == nil {
+		image.Barcode = code
+	}
+	return image, nil
+}
+
+// indexLater ставит в очередь сохранённые фото без вектора и возвращает их число
+func (b *Bot) indexLater(images []*storage.ImageMeta) int {
+	queued := 0
+	for _, image := range images {
+		if image.Float != nil || image.ImageID == 0 {
+			continue
+		}
+		if err := b.indexer.Enqueue(context.Background(), image.ImageID); err != nil {
+			log.Printf("не удалось поставить фото %d в очередь индексации: %v", image.ImageID, err)
+			continue
+		}
+		queued++
+	}
+	return queued
+}
+
+// SetJobQueue задаёт очередь индексации фото, по умолчанию она хранится в памяти
+func (b *Bot) SetJobQueue(queue jobs.Queue) {
+	b.indexer.Queue = queue
+}
+
+// RunIndexer индексирует фото из очереди до отмены ctx
+func (b *Bot) RunIndexer(ctx context.Context) {
+	b.indexer.Run(ctx)
+}
+
+// PendingIndexing число фото, которые ждут индексации
+func (b *Bot) PendingIndexing(ctx context.Context) (int, error) {
+	return b.indexer.Queue.Pending(ctx)
+}
+
+// indexImage считает вектор и текст сохранённого фото, если их ещё нет или их посчитала другая модель
+func (b *Bot) indexImage(ctx context.Context, job *jobs.Job) error {
+	image, err := b.storage.GetImage(ctx, job.ImageID)
+	if err != nil {
+		return err
+	}
+	if image == nil || image.Float != nil && image.Model == b.recognizer.ActiveModel() {
+		return nil // фото удалено или уже проиндексировано текущей моделью
+	}
+	return b.reindexImage(ctx, image, 0)
+}
+
+// reindexImage пересчитывает вектор и текст фото текущей моделью. Байты фото, добавленного
+// по ссылке, скачиваются и переносятся в blob-хранилище. Фото, у которого остались только
+// байты, загружается в чат chatID, если он задан, чтобы получить ссылку Telegram
+func (b *Bot) reindexImage(ctx context.Context, image *storage.ImageMeta, chatID int64) error {
+	var err error
+	if image.SourceFileID == "" && image.Url == "" {
+		image.SourceFileID = image.FileID
+	}
+	if image.SourceFileID == "" && image.Url == "" && chatID != 0 {
+		if err := b.uploadSource(ctx, chatID, image); err != nil {
+			return err
+		}
+	}
+
+	url := image.Url
+	if image.SourceFileID != "" {
+		if url, err = b.bot.FileURL(image.SourceFileID); err != nil {
+			return err
+		}
+	}
+	if url == "" {
+		log.Printf("фото %d не проиндексировать: нет ни file_id, ни ссылки", image.ImageID)
+		return nil
+	}
+
+	if image.BlobKey == "" && image.Byte == nil {
+		data, err := b.getFileContent(url)
+		if err != nil {
+			return err
+		}
+		key, thumbKey, err := blob.PutImage(ctx, b.blobs, data)
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить фото: %w", err)
+		}
+		if err := b.storage.SetImageBlob(ctx, image.ImageID, key, thumbKey); err != nil {
+			return err
+		}
+	}
+
+	response, err := b.recognizer.Recognize(url)
+	if err != nil {
+		return fmt.Errorf("ошибка при получении вектора файла: %w", err)
+	}
+	if len(response.Features) == 0 {
+		return fmt.Errorf("CLIP-сервис вернул пустой вектор")
+	}
+	model := b.recognizer.ActiveModel()
+	err = b.storage.SetImageRecognition(ctx, image.ImageID, response.Features, response.ExtractedText, model)
+	if errors.Is(err, storage.ErrImageNotFound) {
+		// Фото удалили, пока оно распознавалось: в индекс его возвращать нельзя
+		log.Printf("фото %d удалено во время индексации", image.ImageID)
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	image.Float, image.Text, image.Model = response.Features, response.ExtractedText, model
+	b.indexVectors(image.UserName, []*storage.ImageMeta{image})
+	return nil
+}
+
+// uploadSource загружает байты фото во временное сообщение и запоминает его file_id как источник фото
+func (b *Bot) uploadSource(ctx context.Context, chatID int64, image *storage.ImageMeta) error {
+	data := image.Byte
+	if image.BlobKey != "" {
+		var err error
+		if data, err = b.blobs.Get(ctx, image.BlobKey); err != nil {
+			return fmt.Errorf("не удалось прочитать фото %d: %w", image.ImageID, err)
+		}
+	}
+	if len(data) == 0 {
+		return fmt.Errorf("у фото %d нет ни байтов, ни ссылки", image.ImageID)
+	}
+
+	name := fmt.Sprintf("product_%d_%d.jpg", image.ProductID, image.ImageID)
+	fileID, err := b.uploadTempPhoto(chatID, name, data)
+	if err != nil {
+		return err
+	}
+	image.SourceFileID = fileID
+	return b.storage.SetImageSource(ctx, image.ImageID, image.SourceFileID)
+}
+
+// uploadTempPhoto отправляет фото в чат, сразу удаляет сообщение и возвращает file_id
+// самого крупного размера: по нему Telegram отдаёт ссылку на файл
+func (b *Bot) uploadTempPhoto(chatID int64, name string, data []byte) (string, error) {
+	sent, err := b.bot.Send(tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{Name: name, Bytes: data}))
+	if err != nil {
+		return "", fmt.Errorf("не удалось загрузить фото: %w", err)
+	}
+	if err := b.bot.Delete(chatID, sent.MessageID); err != nil {
+		log.Printf("не удалось удалить временное фото %d: %v", sent.MessageID, err)
+	}
+	if sent.Photo == nil || len(*sent.Photo) == 0 {
+		return "", fmt.Errorf("Telegram не вернул фото")
+	}
+	sizes := *sent.Photo
+	return sizes[len(sizes)-1].FileID, nil
+}
@@ -0,0 +1,161 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+const (
+	// inlinePageSize результатов в одном ответе на inline-запрос
+	inlinePageSize = 20
+	// inlineCacheTime сколько секунд Telegram может кэшировать ответ, остатки меняются часто
+	inlineCacheTime = 5
+)
+
+// inlineCachedPhoto результат inline-запроса с фото, уже загруженным в Telegram.
+// В tgbotapi v4 нет типа InlineQueryResultCachedPhoto
+type inlineCachedPhoto struct {
+	Type        string                         `json:"type"`
+	ID          string                         `json:"id"`
+	PhotoFileID string                         `json:"photo_file_id"`
+	Title       string                         `json:"title,omitempty"`
+	Description string                         `json:"description,omitempty"`
+	Caption     string                         `json:"caption,omitempty"`
+	ParseMode   string                         `json:"parse_mode,omitempty"`
+	ReplyMarkup *tgbotapi.InlineKeyboardMarkup `json:"reply_markup,omitempty"`
+}
+
+// handleInlineQuery ищет товары продавца по тексту «@bot запрос» и возвращает их карточки
+func (b *Bot) handleInlineQuery(query *tgbotapi.InlineQuery) error {
+	offset, _ := strconv.Atoi(query.Offset)
+	ctx := context.Background()
+
+	products, total, err := b.storage.SearchProducts(ctx, storage.ProductQuery{
+		UserName: query.From.UserName,
+		Text:     query.Query,
+		Sort:     storage.SortByName,
+		Limit:    inlinePageSize,
+		Offset:   offset,
+	})
+	if err != nil {
+		return err
+	}
+
+	ids := make([]uint, len(products))
+	for i, p := range products {
+		ids[i] = p.ProductID
+	}
+	covers, err := b.storage.GetCoverImages(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	results := make([]interface{}, 0, len(products))
+	for _, p := range products {
+		results = append(results, inlineResult(p, covers[p.ProductID]))
+	}
+
+	config := tgbotapi.InlineConfig{
+		InlineQueryID: query.ID,
+		Results:       results,
+		CacheTime:     inlineCacheTime,
+		IsPersonal:    true,
+	}
+	if next := offset + len(products); next < total {
+		config.NextOffset = strconv.Itoa(next)
+	}
+	return b.bot.AnswerInline(config)
+}
+
+// inlineResult карточка товара для inline-режима: фото, если оно уже есть в Telegram, иначе текст
+func inlineResult(product *storage.Product, cover *storage.ImageMeta) interface{} {
+	id := strconv.FormatUint(uint64(product.ProductID), 10)
+	text := productCardText(product)
+	description := fmt.Sprintf("💰 %s · 📦 %s", product.SellingPrice.StringFixed(cart.Places), formatQuantity(product.Count, product.Unit))
+	keyboard := getInlineCardKeyboard(product.ProductID)
+
+	if cover != nil {
+		fileID := cover.FileID
+		if fileID == "" {
+			fileID = cover.ThumbFileID
+		}
+		if fileID != "" {
+			return inlineCachedPhoto{
+				Type:        "photo",
+				ID:          id,
+				PhotoFileID: fileID,
+				Title:       product.Name,
+				Description: description,
+				Caption:     text,
+				ParseMode:   "Markdown",
+				ReplyMarkup: &keyboard,
+			}
+		}
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticleMarkdown(id, product.Name, text)
+	article.Description = description
+	article.ReplyMarkup = &keyboard
+	return article
+}
+
+// getInlineCardKeyboard кнопка под карточкой, отправленной через inline-режим
+func getInlineCardKeyboard(productID uint) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("🛒 В корзину", fmt.Sprintf("%s_%d", InlineAddCmd, productID)),
+		),
+	)
+}
+
+// routeInlineCallback обрабатывает кнопки под сообщениями, отправленными через inline-режим.
+// У таких нажатий нет сообщения и чата, поэтому корзина берётся из личного чата с ботом
+func (b *Bot) routeInlineCallback(update tgbotapi.Update) error {
+	callback := update.CallbackQuery
+	if strings.HasPrefix(callback.Data, InlineAddCmd+"_") {
+		return b.handleInlineAdd(callback)
+	}
+	return nil
+}
+
+// handleInlineAdd добавляет товар из inline-карточки в корзину продавца
+func (b *Bot) handleInlineAdd(callback *tgbotapi.CallbackQuery) error {
+	// Идентификатор личного чата совпадает с идентификатором пользователя
+	chatID := int64(callback.From.ID)
+	data := callback.Data
+	productID, _ := strconv.Atoi(data[strings.LastIndex(data, "_")+1:])
+
+	product, err := b.storage.GetProductByID(context.Background(), uint(productID))
+	if err != nil || product == nil || product.UserName != callback.From.UserName {
+		b.alert(callback, "Товар не найден")
+		return nil
+	}
+	variants, err := b.storage.GetVariants(context.Background(), product.ProductID)
+	if err != nil {
+		return err
+	}
+	if len(variants) > 0 {
+		b.alert(callback, "У товара есть варианты: отправьте боту его фото и выберите вариант")
+		return nil
+	}
+
+	c := b.cart(chatID)
+	c.Put(productLine(product))
+	if err := c.Add(product.ProductID, one); err != nil {
+		b.toast(callback, "Нет в наличии")
+		return nil
+	}
+
+	if err := b.renderCart(chatID); err != nil {
+		b.alert(callback, "Напишите боту /start, чтобы вести корзину")
+		return nil
+	}
+	b.toast(callback, fmt.Sprintf("+1 добавлено · 🛍 %s", c.Total().StringFixed(cart.Places)))
+	return nil
+}
@@ -0,0 +1,91 @@
+package telegram
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Messenger описывает вызовы Telegram API, которые использует бот
+type Messenger interface {
+	Send(c tgbotapi.Chattable) (tgbotapi.Message, error)
+	EditMarkup(chatID int64, messageID int, markup tgbotapi.InlineKeyboardMarkup) error
+	Delete(chatID int64, messageID int) error
+	AnswerCallback(callbackID, text string, alert bool) error
+	AnswerInline(config tgbotapi.InlineConfig) error
+	FileURL(fileID string) (string, error)
+	DownloadFile(url string) ([]byte, error)
+	GetUpdatesChan(config tgbotapi.UpdateConfig) (tgbotapi.UpdatesChannel, error)
+}
+
+// TelegramMessenger реализует Messenger поверх tgbotapi.BotAPI
+type TelegramMessenger struct {
+	api *tgbotapi.BotAPI
+}
+
+func NewTelegramMessenger(api *tgbotapi.BotAPI) *TelegramMessenger {
+	return &TelegramMessenger{api: api}
+}
+
+func (m *TelegramMessenger) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	return m.api.Send(c)
+}
+
+func (m *TelegramMessenger) EditMarkup(chatID int64, messageID int, markup tgbotapi.InlineKeyboardMarkup) error {
+	_, err := m.api.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, messageID, markup))
+	return err
+}
+
+func (m *TelegramMessenger) Delete(chatID int64, messageID int) error {
+	_, err := m.api.DeleteMessage(tgbotapi.DeleteMessageConfig{ChatID: chatID, MessageID: messageID})
+	return err
+}
+
+func (m *TelegramMessenger) AnswerCallback(callbackID, text string, alert bool) error {
+	_, err := m.api.AnswerCallbackQuery(tgbotapi.CallbackConfig{
+		CallbackQueryID: callbackID,
+		Text:            text,
+		ShowAlert:       alert,
+	})
+	return err
+}
+
+// AnswerInline отправляет результаты inline-запроса
+func (m *TelegramMessenger) AnswerInline(config tgbotapi.InlineConfig) error {
+	_, err := m.api.AnswerInlineQuery(config)
+	return err
+}
+
+// FileURL возвращает ссылку для скачивания файла по fileID
+func (m *TelegramMessenger) FileURL(fileID string) (string, error) {
+	url, err := m.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return "", fmt.Errorf("ошибка при запросе getFile: %w", err)
+	}
+	return url, nil
+}
+
+// DownloadFile скачивает файл тем же HTTP-клиентом, что и запросы к API
+func (m *TelegramMessenger) DownloadFile(url string) ([]byte, error) {
+	resp, err := m.api.Client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при загрузке файла: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("ошибка при загрузке файла: %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка чтения содержимого файла: %w", err)
+	}
+	return data, nil
+}
+
+func (m *TelegramMessenger) GetUpdatesChan(config tgbotapi.UpdateConfig) (tgbotapi.UpdatesChannel, error) {
+	return m.api.GetUpdatesChan(config)
+}
@@ -0,0 +1,105 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"runtime/debug"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Handler обрабатывает одно обновление от Telegram
+type Handler func(update tgbotapi.Update) error
+
+// Middleware оборачивает обработчик сквозной логикой
+type Middleware func(next Handler) Handler
+
+// MessageHandler обрабатывает команду из сообщения
+type MessageHandler func(message *tgbotapi.Message) error
+
+// CallbackHandler обрабатывает нажатие inline-кнопки
+type CallbackHandler func(callback *tgbotapi.CallbackQuery) error
+
+// chain собирает обработчик, middlewares применяются в порядке перечисления
+func chain(h Handler, middlewares ...Middleware) Handler {
+	for i := len(middlewares) - 1; i >= 0; i-- {
+		h = middlewares[i](h)
+	}
+	return h
+}
+
+// onMessage адаптирует обработчик сообщения к Handler
+func onMessage(h MessageHandler) Handler {
+	return func(update tgbotapi.Update) error {
+		if update.Message == nil {
+			return nil
+		}
+		return h(update.Message)
+	}
+}
+
+// onCallback адаптирует обработчик inline-кнопки к Handler
+func onCallback(h CallbackHandler) Handler {
+	return func(update tgbotapi.Update) error {
+		if update.CallbackQuery == nil {
+			return nil
+		}
+		return h(update.CallbackQuery)
+	}
+}
+
+// Recover не даёт панике в обработчике остановить бота
+func Recover() Middleware {
+	return func(next Handler) Handler {
+		return func(update tgbotapi.Update) (err error) {
+			defer func() {
+				if r := recover(); r != nil {
+					log.Printf("panic on update %d: %v\n%s", update.UpdateID, r, debug.Stack())
+					err = fmt.Errorf("panic: %v", r)
+				}
+			}()
+			return next(update)
+		}
+	}
+}
+
+// Logging пишет в лог тип обновления, отправителя и время обработки
+func Logging() Middleware {
+	return func(next Handler) Handler {
+		return func(update tgbotapi.Update) error {
+			start := time.Now()
+			err := next(update)
+			kind, from := describeUpdate(update)
+			if err != nil {
+				log.Printf("%s from %s failed in %s: %v", kind, from, time.Since(start), err)
+			} else {
+				log.Printf("%s from %s handled in %s", kind, from, time.Since(start))
+			}
+			return err
+		}
+	}
+}
+
+func describeUpdate(update tgbotapi.Update) (kind, from string) {
+	switch {
+	case update.Message != nil:
+		kind = "message"
+		if update.Message.From != nil {
+			from = update.Message.From.UserName
+		}
+	case update.CallbackQuery != nil:
+		kind = "callback " + update.CallbackQuery.Data
+		if update.CallbackQuery.From != nil {
+			from = update.CallbackQuery.From.UserName
+		}
+	case update.InlineQuery != nil:
+		kind = "inline query " + update.InlineQuery.Query
+		if update.InlineQuery.From != nil {
+			from = update.InlineQuery.From.UserName
+		}
+	default:
+		kind = "update"
+	}
+	return kind, from
+}
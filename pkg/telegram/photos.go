@@ -0,0 +1,223 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/blob"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// sendImage отправляет фото товара: по сохранённому file_id без загрузки байтов,
+// иначе загружает фото (или превью) из blob-хранилища и запоминает полученный file_id
+func (b *Bot) sendImage(chatID int64, image *storage.ImageMeta, thumb bool, caption string, markup interface{}) (tgbotapi.Message, error) {
+	fileID, key := image.FileID, image.BlobKey
+	if thumb && image.ThumbKey != "" {
+		fileID, key = image.ThumbFileID, image.ThumbKey
+	}
+
+	if fileID != "" {
+		photo := tgbotapi.NewPhotoShare(chatID, fileID)
+		photo.Caption = caption
+		photo.ReplyMarkup = markup
+		sent, err := b.bot.Send(photo)
+		if err == nil {
+			return sent, nil
+		}
+		log.Printf("не удалось отправить фото %d по file_id, загружаем заново: %v", image.ImageID, err)
+	}
+
+	data := image.Byte
+	if key != "" {
+		var err error
+		data, err = b.blobs.Get(context.Background(), key)
+		if err != nil {
+			return tgbotapi.Message{}, fmt.Errorf("не удалось прочитать фото %d: %w", image.ImageID, err)
+		}
+	}
+
+	photo := tgbotapi.NewPhotoUpload(chatID, tgbotapi.FileBytes{
+		Name:  fmt.Sprintf("product_%d_%d.jpg", image.ProductID, image.ImageID),
+		Bytes: data,
+	})
+	photo.Caption = caption
+	photo.ReplyMarkup = markup
+	sent, err := b.bot.Send(photo)
+	if err != nil {
+		return sent, err
+	}
+
+	// Запоминаем file_id самого большого размера, чтобы не загружать фото повторно
+	if sent.Photo != nil && len(*sent.Photo) > 0 && image.ImageID != 0 && key != "" {
+		sizes := *sent.Photo
+		fileID := sizes[len(sizes)-1].FileID
+		if err := b.storage.SetImageFileID(context.Background(), image.ImageID, fileID, thumb && image.ThumbKey != ""); err != nil {
+			log.Printf("не удалось сохранить file_id фото %d: %v", image.ImageID, err)
+		} else if thumb && image.ThumbKey != "" {
+			image.ThumbFileID = fileID
+		} else {
+			image.FileID = fileID
+		}
+	}
+	return sent, nil
+}
+
+// storeImages переносит байты новых фото в blob-хранилище вместе с превью
+func (b *Bot) storeImages(images []*storage.ImageMeta) error {
+	for _, image := range images {
+		if image.BlobKey != "" || image.Byte == nil {
+			continue
+		}
+		key, thumbKey, err := blob.PutImage(context.Background(), b.blobs, image.Byte)
+		if err != nil {
+			return fmt.Errorf("не удалось сохранить фото: %w", err)
+		}
+		image.BlobKey, image.ThumbKey = key, thumbKey
+		image.Byte = nil
+	}
+	return nil
+}
+
+// getPhotosKeyboard возвращает кнопки под списком фото товара
+func (b *Bot) getPhotosKeyboard(productID uint) tgbotapi.InlineKeyboardMarkup {
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData("➕ Добавить фото", fmt.Sprintf("%s_%d", AddPhotoCmd, productID)),
+		),
+	)
+}
+
+// handleProductPhotos показывает все фото товара с кнопками удаления
+func (b *Bot) handleProductPhotos(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	product := b.tempProduct[chatID]
+
+	images, err := b.storage.GetImages(context.Background(), product.ProductID)
+	if err != nil {
+		return err
+	}
+
+	for i, image := range images {
+		var markup interface{}
+		if len(images) > 1 {
+			markup = tgbotapi.NewInlineKeyboardMarkup(
+				tgbotapi.NewInlineKeyboardRow(
+					tgbotapi.NewInlineKeyboardButtonData("🗑 Удалить", fmt.Sprintf("%s_%d", DelPhotoCmd, image.ImageID)),
+				),
+			)
+		}
+		caption := fmt.Sprintf("Фото %d из %d", i+1, len(images))
+		if _, err := b.sendImage(chatID, image, false, caption, markup); err != nil {
+			return err
+		}
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("Фото товара: %d. По каждому из них товар находится при поиске.", len(images)))
+	msg.ReplyMarkup = b.getPhotosKeyboard(product.ProductID)
+	_, err = b.bot.Send(msg)
+	return err
+}
+
+// handleAddPhotoCallback ждёт новые фото для товара
+func (b *Bot) handleAddPhotoCallback(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	b.states[chatID] = stateWaitingForExtraPhoto
+	msg := tgbotapi.NewMessage(chatID, "Отправьте одно или несколько фото товара. Чтобы закончить, отправьте любой текст.")
+	_, err := b.bot.Send(msg)
+	return err
+}
+
+// handleExtraPhoto сохраняет фото к существующему товару и ставит его в очередь индексации
+func (b *Bot) handleExtraPhoto(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	product := b.tempProduct[chatID]
+
+	if message.Photo == nil {
+		delete(b.states, chatID)
+		msg := tgbotapi.NewMessage(chatID, "Готово.")
+		_, err := b.bot.Send(msg)
+		return err
+	}
+
+	imageMeta, err := b.receivePhoto((*message.Photo)[len(*message.Photo)-1].FileID)
+	if err != nil {
+		b.bot.Send(tgbotapi.NewMessage(chatID, "Ошибка обработки фото."))
+		return err
+	}
+	if err := b.storeImages([]*storage.ImageMeta{imageMeta}); err != nil {
+		b.bot.Send(tgbotapi.NewMessage(chatID, "Ошибка сохранения фото."))
+		return err
+	}
+
+	err = b.storage.UpdPhoto(context.Background(), &storage.Product{
+		ProductID: product.ProductID,
+		UserName:  message.Chat.UserName,
+		Image:     []*storage.ImageMeta{imageMeta},
+	})
+	if err != nil {
+		b.bot.Send(tgbotapi.NewMessage(chatID, "Ошибка сохранения фото."))
+		return err
+	}
+	b.indexVectors(message.Chat.UserName, []*storage.ImageMeta{imageMeta})
+
+	text := "📷 Фото добавлено"
+	if b.indexLater([]*storage.ImageMeta{imageMeta}) > 0 {
+		text += ", по нему товар начнёт находиться после распознавания в фоне"
+	}
+	if imageMeta.Barcode != "" {
+		text += "\n" + b.attachBarcode(product.ProductID, imageMeta.Barcode)
+	}
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, text))
+	return err
+}
+
+// attachBarcode сохраняет штрихкод, найденный на новом фото, если у товара его ещё нет
+func (b *Bot) attachBarcode(productID uint, code string) string {
+	ctx := context.Background()
+	product, err := b.storage.GetProductByID(ctx, productID)
+	if err != nil || product == nil {
+		log.Printf("не удалось получить товар %d: %v", productID, err)
+		return ""
+	}
+	if product.Barcode != "" {
+		if product.Barcode == code {
+			return ""
+		}
+		return fmt.Sprintf("🏷 На фото другой штрихкод (%s), у товара остался %s", code, product.Barcode)
+	}
+
+	err = b.storage.SetProductBarcode(ctx, productID, code)
+	if errors.Is(err, storage.ErrBarcodeExists) {
+		return fmt.Sprintf("🏷 Штрихкод %s уже у другого товара", code)
+	}
+	if err != nil {
+		log.Printf("не удалось сохранить штрихкод товара %d: %v", productID, err)
+		return ""
+	}
+	return fmt.Sprintf("🏷 Штрихкод %s сохранён", code)
+}
+
+// handleDeletePhoto удаляет фото товара, последнее фото удалить нельзя
+func (b *Bot) handleDeletePhoto(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	data := callback.Data
+	imageID, _ := strconv.Atoi(data[strings.LastIndex(data, "_")+1:])
+
+	_, err := b.storage.RemoveImage(context.Background(), uint(imageID))
+	if errors.Is(err, storage.ErrLastImage) {
+		b.alert(callback, "Нельзя удалить единственное фото товара")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	b.unindexImage(callback.Message.Chat.UserName, uint(imageID))
+
+	b.toast(callback, "Фото удалено")
+	return b.bot.Delete(chatID, callback.Message.MessageID)
+}
@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	"github.com/shopspring/decimal"
+)
+
+// quantityPlaces знаков после запятой в количестве: граммы, миллиметры, миллилитры
+const quantityPlaces = 3
+
+var one = decimal.NewFromInt(1)
+
+// parseQuantity разбирает количество с необязательной единицей измерения: «5», «2,5 кг», «10м».
+// Пустая единица означает, что продавец её не указал
+func parseQuantity(text string) (decimal.Decimal, string, error) {
+	text = strings.TrimSpace(strings.Replace(text, ",", ".", 1))
+	end := strings.IndexFunc(text, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	number, unitText := text, ""
+	if end >= 0 {
+		number, unitText = text[:end], text[end:]
+	}
+
+	quantity, err := decimal.NewFromString(number)
+	if err != nil || quantity.IsNegative() {
+		return decimal.Zero, "", fmt.Errorf("некорректное количество %q", text)
+	}
+	if quantity.Exponent() < -quantityPlaces {
+		return decimal.Zero, "", fmt.Errorf("слишком точное количество %q", text)
+	}
+
+	var unit string
+	if strings.TrimSpace(unitText) != "" {
+		var ok bool
+		if unit, ok = storage.ParseUnit(unitText); !ok {
+			return decimal.Zero, "", fmt.Errorf("неизвестная единица измерения %q", unitText)
+		}
+	}
+	return quantity, unit, nil
+}
+
+// formatQuantity количество с подписью единицы измерения: «5 шт.», «2.5 кг»
+func formatQuantity(quantity decimal.Decimal, unit string) string {
+	return quantity.String() + " " + storage.UnitLabel(unit)
+}
+
+// productLine строка корзины для товара
+func productLine(product *storage.Product) cart.Line {
+	return cart.Line{
+		ProductID:  product.ProductID,
+		Name:       product.Name,
+		Stock:      product.Count,
+		Fractional: storage.Fractional(product.Unit),
+		Unit:       storage.UnitLabel(product.Unit),
+		UnitPrice:  product.SellingPrice,
+	}
+}
+
+// lineQuantity количество в строке корзины, для штучного товара без подписи
+func lineQuantity(line cart.Line) string {
+	if !line.Fractional {
+		return line.Quantity.String()
+	}
+	return line.Quantity.String() + " " + line.Unit
+}
@@ -0,0 +1,92 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// reindexBatch сколько фото переиндексируется между обновлениями сообщения о ходе работы
+const reindexBatch = 20
+
+// handleReindex пересчитывает текущей моделью CLIP векторы всех фото магазина, посчитанные
+// другой моделью или ещё не посчитанные. Работа идёт в фоне, ход виден в одном сообщении
+func (b *Bot) handleReindex(message *tgbotapi.Message) error {
+	chatID, userName := message.Chat.ID, message.Chat.UserName
+	model := b.recognizer.ActiveModel()
+
+	ids, err := b.storage.GetImagesToReindex(context.Background(), userName, model)
+	if err != nil {
+		_, _ = b.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось получить список фото."))
+		return err
+	}
+	if len(ids) == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Все фото уже проиндексированы моделью %s.", model)))
+		return err
+	}
+	if _, running := b.reindexing.LoadOrStore(userName, true); running {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Переиндексация уже идёт, дождитесь её завершения."))
+		return err
+	}
+
+	progress, err := b.bot.Send(tgbotapi.NewMessage(chatID, reindexProgress(model, 0, len(ids), 0)))
+	if err != nil {
+		b.reindexing.Delete(userName)
+		return err
+	}
+	go func() {
+		defer b.reindexing.Delete(userName)
+		b.reindex(chatID, progress.MessageID, model, ids)
+	}()
+	return nil
+}
+
+// reindex переиндексирует фото пачками и после каждой пачки обновляет сообщение о ходе работы.
+// Фото с ошибкой уходят в очередь фоновой индексации. Использует только потокобезопасные
+// части бота: мессенджер, хранилища и очередь
+func (b *Bot) reindex(chatID int64, progressID int, model string, ids []uint) {
+	ctx := context.Background()
+	failed := 0
+	for start := 0; start < len(ids); start += reindexBatch {
+		end := start + reindexBatch
+		if end > len(ids) {
+			end = len(ids)
+		}
+		for _, id := range ids[start:end] {
+			if err := b.reindexByID(ctx, chatID, id); err != nil {
+				log.Printf("переиндексация фото %d: %v", id, err)
+				failed++
+				if err := b.indexer.Enqueue(ctx, id); err != nil {
+					log.Printf("не удалось поставить фото %d в очередь индексации: %v", id, err)
+				}
+			}
+		}
+		edit := tgbotapi.NewEditMessageText(chatID, progressID, reindexProgress(model, end, len(ids), failed))
+		if _, err := b.bot.Send(edit); err != nil {
+			log.Printf("не удалось обновить ход переиндексации: %v", err)
+		}
+	}
+
+	text := fmt.Sprintf("✅ Переиндексация моделью %s завершена: %d из %d фото.", model, len(ids)-failed, len(ids))
+	if failed > 0 {
+		text += fmt.Sprintf("\nНе удалось сразу: %d, они проиндексируются в фоне.", failed)
+	}
+	if _, err := b.bot.Send(tgbotapi.NewMessage(chatID, text)); err != nil {
+		log.Printf("не удалось отправить итог переиндексации: %v", err)
+	}
+}
+
+// reindexByID переиндексирует одно фото, удалённые за время работы фото пропускаются
+func (b *Bot) reindexByID(ctx context.Context, chatID int64, imageID uint) error {
+	image, err := b.storage.GetImage(ctx, imageID)
+	if err != nil || image == nil {
+		return err
+	}
+	return b.reindexImage(ctx, image, chatID)
+}
+
+func reindexProgress(model string, done, total, failed int) string {
+	return fmt.Sprintf("🔄 Переиндексация моделью %s: %d из %d, ошибок %d", model, done, total, failed)
+}
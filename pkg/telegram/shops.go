@@ -0,0 +1,123 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// handleStart запоминает чат пользователя, чтобы слать ему запросы магазина, и показывает меню
+func (b *Bot) handleStart(message *tgbotapi.Message) error {
+	if message.From != nil && message.From.UserName != "" {
+		if err := b.storage.SetShopUserChatID(context.Background(), message.From.UserName, message.Chat.ID); err != nil {
+			log.Printf("не удалось сохранить чат @%s: %v", message.From.UserName, err)
+		}
+	}
+	return b.handleStartTxt(message)
+}
+
+// handleCreateShop создаёт магазин, автор становится его администратором
+func (b *Bot) handleCreateShop(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	name := strings.TrimSpace(message.CommandArguments())
+	if name == "" {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Формат: /create_shop Название"))
+		return err
+	}
+
+	ctx := context.Background()
+	username := message.From.UserName
+	shopID, err := b.storage.CreateShop(ctx, name, username)
+	if err != nil {
+		return err
+	}
+	if err := b.storage.AddShopUser(ctx, shopID, username, storage.RoleAdmin); err != nil {
+		return err
+	}
+	if err := b.storage.SetShopUserChatID(ctx, username, chatID); err != nil {
+		return err
+	}
+
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"Магазин «%s» создан. Пригласите сотрудников: /invite_user @username seller", name)))
+	return err
+}
+
+// handleInviteUser добавляет пользователя в магазин администратора
+func (b *Bot) handleInviteUser(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	args := strings.Fields(message.CommandArguments())
+	if len(args) == 0 || len(args) > 2 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Формат: /invite_user @username [admin|seller|viewer]"))
+		return err
+	}
+
+	role := storage.RoleSeller
+	if len(args) == 2 {
+		role = strings.ToLower(args[1])
+	}
+	if role != storage.RoleAdmin && role != storage.RoleSeller && role != storage.RoleViewer {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Роль должна быть admin, seller или viewer"))
+		return err
+	}
+
+	ctx := context.Background()
+	shopID, myRole, err := b.storage.GetUserShop(ctx, message.From.UserName)
+	if err != nil {
+		return err
+	}
+	if shopID == 0 || myRole != storage.RoleAdmin {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Приглашать может только администратор магазина. Создать магазин: /create_shop Название"))
+		return err
+	}
+
+	username := strings.TrimPrefix(args[0], "@")
+	if err := b.storage.AddShopUser(ctx, shopID, username, role); err != nil {
+		return err
+	}
+
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf(
+		"@%s добавлен с ролью %s. Пусть напишет боту /start, чтобы получать уведомления.", username, role)))
+	return err
+}
+
+// handleListUsers показывает участников магазина
+func (b *Bot) handleListUsers(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	ctx := context.Background()
+
+	shopID, _, err := b.storage.GetUserShop(ctx, message.From.UserName)
+	if err != nil {
+		return err
+	}
+	if shopID == 0 {
+		_, err := b.bot.Send(tgbotapi.NewMessage(chatID, "Вы не состоите в магазине. Создать: /create_shop Название"))
+		return err
+	}
+
+	users, err := b.storage.GetShopUsers(ctx, shopID)
+	if err != nil {
+		return err
+	}
+
+	var sb strings.Builder
+	sb.WriteString("👥 Сотрудники\n")
+	for _, u := range users {
+		limit, ok := b.maxDiscount[u.Role]
+		if !ok {
+			limit = 100
+		}
+		fmt.Fprintf(&sb, "@%s — %s, скидка до %d%%", u.UserName, u.Role, limit)
+		if u.ChatID == 0 {
+			sb.WriteString(" (не писал боту)")
+		}
+		sb.WriteString("\n")
+	}
+
+	_, err = b.bot.Send(tgbotapi.NewMessage(chatID, sb.String()))
+	return err
+}
@@ -0,0 +1,15 @@
+package telegramtest
+
+import (
+	"bytes"
+	"image/png"
+
+	"github.com/Bariban/vector-shop-bot/pkg/barcode/barcodetest"
+)
+
+// barcodePhoto рисует PNG с штрихкодом EAN-13, чтобы бот распознал его на фото товара
+func barcodePhoto(code string) []byte {
+	var buf bytes.Buffer
+	_ = png.Encode(&buf, barcodetest.Image(code, 3))
+	return buf.Bytes()
+}
@@ -0,0 +1,329 @@
+package telegramtest
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/Bariban/vector-shop-bot/pkg/ann"
+	"github.com/Bariban/vector-shop-bot/pkg/blob"
+	"github.com/Bariban/vector-shop-bot/pkg/blob/blobtest"
+	"github.com/Bariban/vector-shop-bot/pkg/config"
+	"github.com/Bariban/vector-shop-bot/pkg/recognize"
+	"github.com/Bariban/vector-shop-bot/pkg/storage/postgres"
+	"github.com/Bariban/vector-shop-bot/pkg/telegram"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// T подмножество testing.TB, которое нужно сценариям
+type T interface {
+	Helper()
+	Logf(format string, args ...interface{})
+	Fatalf(format string, args ...interface{})
+}
+
+// WaitTimeout сколько ждать ответа бота на шаг сценария
+var WaitTimeout = 5 * time.Second
+
+var nextChatID int64 = 100000
+
+// Harness запускает настоящий Bot против поддельных Telegram и CLIP
+type Harness struct {
+	Server     *Server
+	S3         *blobtest.S3Server
+	Bot        *telegram.Bot
+	API        *tgbotapi.BotAPI
+	Recognizer *recognize.Client
+	ChatID     int64
+	UserName   string
+
+	cursor      int
+	stopIndexer context.CancelFunc
+	indexDir    string
+}
+
+// NewHarness поднимает поддельный сервер и запускает бота, каждый Harness получает свой чат и пользователя
+func NewHarness(t T, storage *postgres.Storage, messages config.Messages) *Harness {
+	t.Helper()
+
+	server := NewServer()
+	api, err := tgbotapi.NewBotAPIWithClient(Token, server.Client())
+	if err != nil {
+		server.Close()
+		t.Fatalf("can't create bot api: %v", err)
+	}
+
+	// Фото хранятся в поддельном S3, чтобы сценарии проходили через blob.S3
+	s3 := blobtest.NewS3Server("photos", "test-access-key")
+	blobs := blob.NewS3(s3.URL, s3.Bucket, "", s3.AccessKey, "test-secret-key")
+
+	recognizer := recognize.NewClient(server.ClipURL())
+	bot := telegram.NewBot(telegram.NewTelegramMessenger(api), storage, recognizer, blobs, messages)
+
+	// Поиск по фото идёт через индекс в памяти, как в боевой конфигурации
+	indexDir, err := os.MkdirTemp("", "vector-index-")
+	if err != nil {
+		server.Close()
+		s3.Close()
+		t.Fatalf("can't create vector index dir: %v", err)
+	}
+	registry, err := ann.NewRegistry(indexDir, recognizer.ActiveModel(), recognize.DefaultMetric)
+	if err != nil {
+		t.Fatalf("can't create vector index: %v", err)
+	}
+	bot.SetVectorIndex(registry)
+	if err := bot.LoadVectorIndex(context.Background()); err != nil {
+		t.Fatalf("can't load vector index: %v", err)
+	}
+
+	go func() { _ = bot.Start() }()
+	ctx, stopIndexer := context.WithCancel(context.Background())
+	go bot.RunIndexer(ctx)
+
+	chatID := atomic.AddInt64(&nextChatID, 1)
+	return &Harness{
+		Server:     server,
+		S3:         s3,
+		Bot:        bot,
+		API:        api,
+		Recognizer: recognizer,
+		ChatID:     chatID,
+		UserName:   fmt.Sprintf("e2e_%d_%d", time.Now().Unix(), chatID),
+
+		stopIndexer: stopIndexer,
+		indexDir:    indexDir,
+	}
+}
+
+// Close останавливает получение обновлений и поддельный сервер
+func (h *Harness) Close() {
+	h.API.StopReceivingUpdates()
+	h.stopIndexer()
+	h.Server.Close()
+	h.S3.Close()
+	_ = os.RemoveAll(h.indexDir)
+}
+
+func (h *Harness) user() *tgbotapi.User {
+	return &tgbotapi.User{ID: int(h.ChatID), UserName: h.UserName}
+}
+
+func (h *Harness) chat() *tgbotapi.Chat {
+	return &tgbotapi.Chat{ID: h.ChatID, Type: "private", UserName: h.UserName}
+}
+
+// SendText отправляет боту текстовое сообщение от имени пользователя.
+// Текст с «/» в начале Telegram размечает как команду, так же делает и подделка
+func (h *Harness) SendText(text string) {
+	message := &tgbotapi.Message{
+		MessageID: h.Server.reserveMessageID(),
+		From:      h.user(),
+		Chat:      h.chat(),
+		Date:      int(time.Now().Unix()),
+		Text:      text,
+	}
+	if strings.HasPrefix(text, "/") {
+		command := strings.Fields(text)[0]
+		message.Entities = &[]tgbotapi.MessageEntity{{Type: "bot_command", Offset: 0, Length: len(command)}}
+	}
+	h.Server.Push(tgbotapi.Update{Message: message})
+}
+
+// SendPhoto отправляет боту фото, зарегистрированное через Server.AddPhoto
+func (h *Harness) SendPhoto(fileID string) {
+	h.Server.Push(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: h.Server.reserveMessageID(),
+		From:      h.user(),
+		Chat:      h.chat(),
+		Date:      int(time.Now().Unix()),
+		Photo:     &[]tgbotapi.PhotoSize{{FileID: fileID, Width: 640, Height: 480}},
+	}})
+}
+
+// SendDocument отправляет боту файл, зарегистрированный через Server.AddFile
+func (h *Harness) SendDocument(fileID, fileName string) {
+	h.Server.Push(tgbotapi.Update{Message: &tgbotapi.Message{
+		MessageID: h.Server.reserveMessageID(),
+		From:      h.user(),
+		Chat:      h.chat(),
+		Date:      int(time.Now().Unix()),
+		Document:  &tgbotapi.Document{FileID: fileID, FileName: fileName},
+	}})
+}
+
+// WaitIndexed ждёт, пока бот проиндексирует фото из очереди
+func (h *Harness) WaitIndexed(t T) {
+	t.Helper()
+
+	deadline := time.Now().Add(WaitTimeout)
+	for {
+		n, err := h.Bot.PendingIndexing(context.Background())
+		if err != nil {
+			t.Fatalf("can't count pending photos: %v", err)
+		}
+		if n == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("%d photos are still waiting for indexing", n)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+// Press нажимает последнюю inline-кнопку, callback_data которой начинается с prefix
+func (h *Harness) Press(t T, prefix string) {
+	t.Helper()
+
+	msg, data, ok := h.findButton(func(btn tgbotapi.InlineKeyboardButton) bool {
+		return strings.HasPrefix(*btn.CallbackData, prefix)
+	})
+	if !ok {
+		t.Fatalf("button %q not found in bot messages", prefix)
+	}
+	h.press(msg, data)
+}
+
+// PressLabel нажимает последнюю inline-кнопку, в тексте которой есть label
+func (h *Harness) PressLabel(t T, label string) {
+	t.Helper()
+
+	msg, data, ok := h.findButton(func(btn tgbotapi.InlineKeyboardButton) bool {
+		return strings.Contains(btn.Text, label)
+	})
+	if !ok {
+		t.Fatalf("button with label %q not found in bot messages", label)
+	}
+	h.press(msg, data)
+}
+
+func (h *Harness) press(msg SentMessage, data string) {
+	h.Server.Push(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:   fmt.Sprintf("cb%d", time.Now().UnixNano()),
+		From: h.user(),
+		Message: &tgbotapi.Message{
+			MessageID: msg.MessageID,
+			Chat:      h.chat(),
+			Text:      msg.Text,
+		},
+		Data: data,
+	}})
+}
+
+// Inline отправляет боту inline-запрос «@bot query»
+func (h *Harness) Inline(query string) {
+	h.Server.Push(tgbotapi.Update{InlineQuery: &tgbotapi.InlineQuery{
+		ID:    fmt.Sprintf("iq%d", time.Now().UnixNano()),
+		From:  h.user(),
+		Query: query,
+	}})
+}
+
+// PressInline нажимает кнопку с callback_data data под сообщением, отправленным через inline-режим
+func (h *Harness) PressInline(data string) {
+	h.Server.Push(tgbotapi.Update{CallbackQuery: &tgbotapi.CallbackQuery{
+		ID:              fmt.Sprintf("cb%d", time.Now().UnixNano()),
+		From:            h.user(),
+		InlineMessageID: "inline_" + data,
+		Data:            data,
+	}})
+}
+
+// ExpectInline ждёт ответ на inline-запрос, в результатах которого есть подстрока text
+func (h *Harness) ExpectInline(t T, text string) string {
+	t.Helper()
+
+	deadline := time.Now().Add(WaitTimeout)
+	for time.Now().Before(deadline) {
+		for _, results := range h.Server.InlineResults() {
+			if strings.Contains(results, text) {
+				return results
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("bot didn't answer inline query with %q, got: %v", text, h.Server.InlineResults())
+	return ""
+}
+
+// findButton ищет кнопку с ожиданием: клавиатура могла ещё не обновиться после предыдущего шага
+func (h *Harness) findButton(match func(btn tgbotapi.InlineKeyboardButton) bool) (SentMessage, string, bool) {
+	deadline := time.Now().Add(WaitTimeout)
+	for {
+		msg, data, ok := h.lastButton(match)
+		if ok || time.Now().After(deadline) {
+			return msg, data, ok
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
+
+func (h *Harness) lastButton(match func(btn tgbotapi.InlineKeyboardButton) bool) (SentMessage, string, bool) {
+	messages := h.Server.Messages()
+	for i := len(messages) - 1; i >= 0; i-- {
+		m := messages[i]
+		if m.ChatID != h.ChatID || m.Deleted || m.Keyboard == nil {
+			continue
+		}
+		for _, row := range m.Keyboard.InlineKeyboard {
+			for _, btn := range row {
+				if btn.CallbackData != nil && match(btn) {
+					return m, *btn.CallbackData, true
+				}
+			}
+		}
+	}
+	return SentMessage{}, "", false
+}
+
+// Expect ждёт сообщение бота с подстрокой text среди сообщений после предыдущего Expect
+func (h *Harness) Expect(t T, text string) SentMessage {
+	t.Helper()
+
+	deadline := time.Now().Add(WaitTimeout)
+	for time.Now().Before(deadline) {
+		messages := h.Server.Messages()
+		for i := h.cursor; i < len(messages); i++ {
+			if messages[i].ChatID == h.ChatID && strings.Contains(messages[i].Text, text) {
+				h.cursor = i + 1
+				return messages[i]
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	var got []string
+	for _, m := range h.Server.Messages()[h.cursor:] {
+		got = append(got, fmt.Sprintf("%s %q", m.Method, m.Text))
+	}
+	t.Fatalf("bot didn't send %q, got after cursor: %v", text, got)
+	return SentMessage{}
+}
+
+// ExpectAnswer ждёт ответ на нажатие inline-кнопки с подстрокой text
+func (h *Harness) ExpectAnswer(t T, text string) {
+	t.Helper()
+
+	deadline := time.Now().Add(WaitTimeout)
+	for time.Now().Before(deadline) {
+		for _, a := range h.Server.Answers() {
+			if strings.Contains(a, text) {
+				return
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatalf("bot didn't answer callback with %q, got: %v", text, h.Server.Answers())
+}
+
+// reserveMessageID выдаёт идентификатор для входящего сообщения пользователя
+func (s *Server) reserveMessageID() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextMsg
+	s.nextMsg++
+	return id
+}
@@ -0,0 +1,172 @@
+// Package telegramtest содержит подделки Telegram для тестов бота.
+package telegramtest
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/Bariban/vector-shop-bot/pkg/telegram"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Call — один записанный вызов Messenger
+type Call struct {
+	Method    string
+	ChatID    int64
+	MessageID int
+	Text      string
+	Alert     bool
+	Markup    interface{}
+	Chattable tgbotapi.Chattable
+	Results   []interface{}
+}
+
+// RecordingMessenger реализует telegram.Messenger в памяти и запоминает все вызовы
+type RecordingMessenger struct {
+	mu      sync.Mutex
+	calls   []Call
+	files   map[string][]byte
+	nextID  int
+	updates chan tgbotapi.Update
+}
+
+var _ telegram.Messenger = (*RecordingMessenger)(nil)
+
+func NewRecordingMessenger() *RecordingMessenger {
+	return &RecordingMessenger{
+		files:   make(map[string][]byte),
+		nextID:  1000,
+		updates: make(chan tgbotapi.Update, 100),
+	}
+}
+
+// AddFile регистрирует файл, доступный через FileURL и DownloadFile
+func (m *RecordingMessenger) AddFile(fileID string, data []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.files[fileID] = data
+}
+
+// Push кладёт обновление в канал, который возвращает GetUpdatesChan
+func (m *RecordingMessenger) Push(update tgbotapi.Update) {
+	m.updates <- update
+}
+
+// Close закрывает канал обновлений, после чего Bot.Start завершается
+func (m *RecordingMessenger) Close() {
+	close(m.updates)
+}
+
+// Calls возвращает копию всех записанных вызовов
+func (m *RecordingMessenger) Calls() []Call {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Call(nil), m.calls...)
+}
+
+// Texts возвращает тексты всех отправленных сообщений по порядку
+func (m *RecordingMessenger) Texts() []string {
+	var texts []string
+	for _, c := range m.Calls() {
+		if c.Method == "sendMessage" || c.Method == "editMessageText" {
+			texts = append(texts, c.Text)
+		}
+	}
+	return texts
+}
+
+// Reset очищает записанные вызовы
+func (m *RecordingMessenger) Reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = nil
+}
+
+func (m *RecordingMessenger) record(c Call) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.calls = append(m.calls, c)
+}
+
+func (m *RecordingMessenger) Send(c tgbotapi.Chattable) (tgbotapi.Message, error) {
+	call := Call{Chattable: c}
+	switch cfg := c.(type) {
+	case tgbotapi.MessageConfig:
+		call.Method, call.ChatID, call.Text, call.Markup = "sendMessage", cfg.ChatID, cfg.Text, cfg.ReplyMarkup
+	case tgbotapi.PhotoConfig:
+		call.Method, call.ChatID, call.Text, call.Markup = "sendPhoto", cfg.ChatID, cfg.Caption, cfg.ReplyMarkup
+	case tgbotapi.DocumentConfig:
+		call.Method, call.ChatID, call.Text, call.Markup = "sendDocument", cfg.ChatID, cfg.Caption, cfg.ReplyMarkup
+	case tgbotapi.EditMessageTextConfig:
+		call.Method, call.ChatID, call.MessageID, call.Text = "editMessageText", cfg.ChatID, cfg.MessageID, cfg.Text
+		if cfg.ReplyMarkup != nil {
+			call.Markup = *cfg.ReplyMarkup
+		}
+	case tgbotapi.EditMessageReplyMarkupConfig:
+		call.Method, call.ChatID, call.MessageID = "editMessageReplyMarkup", cfg.ChatID, cfg.MessageID
+		if cfg.ReplyMarkup != nil {
+			call.Markup = *cfg.ReplyMarkup
+		}
+	default:
+		call.Method = fmt.Sprintf("%T", c)
+	}
+
+	m.mu.Lock()
+	m.nextID++
+	id := m.nextID
+	m.mu.Unlock()
+
+	if call.MessageID == 0 {
+		call.MessageID = id
+	}
+	m.record(call)
+	return tgbotapi.Message{
+		MessageID: call.MessageID,
+		Chat:      &tgbotapi.Chat{ID: call.ChatID},
+		Text:      call.Text,
+	}, nil
+}
+
+func (m *RecordingMessenger) EditMarkup(chatID int64, messageID int, markup tgbotapi.InlineKeyboardMarkup) error {
+	m.record(Call{Method: "editMessageReplyMarkup", ChatID: chatID, MessageID: messageID, Markup: markup})
+	return nil
+}
+
+func (m *RecordingMessenger) Delete(chatID int64, messageID int) error {
+	m.record(Call{Method: "deleteMessage", ChatID: chatID, MessageID: messageID})
+	return nil
+}
+
+func (m *RecordingMessenger) AnswerCallback(callbackID, text string, alert bool) error {
+	m.record(Call{Method: "answerCallbackQuery", Text: text, Alert: alert})
+	return nil
+}
+
+func (m *RecordingMessenger) AnswerInline(config tgbotapi.InlineConfig) error {
+	m.record(Call{Method: "answerInlineQuery", Text: config.NextOffset, Results: config.Results})
+	return nil
+}
+
+func (m *RecordingMessenger) FileURL(fileID string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.files[fileID]; !ok {
+		return "", fmt.Errorf("file %s not found", fileID)
+	}
+	return "file://" + fileID, nil
+}
+
+func (m *RecordingMessenger) DownloadFile(url string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := m.files[strings.TrimPrefix(url, "file://")]
+	if !ok {
+		return nil, fmt.Errorf("file %s not found", url)
+	}
+	return data, nil
+}
+
+func (m *RecordingMessenger) GetUpdatesChan(config tgbotapi.UpdateConfig) (tgbotapi.UpdatesChannel, error) {
+	return m.updates, nil
+}
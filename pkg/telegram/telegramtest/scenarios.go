@@ -0,0 +1,552 @@
+package telegramtest
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/table"
+	"github.com/Bariban/vector-shop-bot/pkg/telegram"
+)
+
+// Scenario — сценарий диалога продавца с ботом
+type Scenario struct {
+	Name string
+	Run  func(t T, h *Harness)
+}
+
+// Тестовые фото и их векторы для поддельного CLIP
+var (
+	shampooPhoto    = []byte("\xff\xd8\xff\xe0shampoo")
+	shampooVector   = []float64{0.1, 0.2, 0.3, 0.4}
+	shampooPhotoID  = "shampoo_front"
+	shampooSampleID = "shampoo_sample"
+
+	shampooBackPhoto  = []byte("\xff\xd8\xff\xe0shampoo back")
+	shampooBackVector = []float64{0.9, 0.8, 0.1, 0.0}
+	shampooBackID     = "shampoo_back"
+	shampooBackSample = "shampoo_back_sample"
+
+	// Снимок той же упаковки издалека: по вектору дальше порога, но надпись та же
+	labelFarVector = []float64{0.1, 0.2, 0.3, 1.0}
+	labelFarID     = "label_far"
+	labelText      = "Head & Shoulders\nClassic Clean 400 ml"
+
+	// Штрихкод на упаковке: фото сзади далеко по вектору, но код тот же
+	shampooBarcode  = "4006381333931"
+	barcodeFrontID  = "barcode_front"
+	barcodeSampleID = "barcode_sample"
+)
+
+// Scenarios возвращает сценарии, которые проходит бот: добавление товара,
+// поиск по фото, корзина, скидка и оплата
+func Scenarios() []Scenario {
+	return []Scenario{
+		{Name: "add product", Run: scenarioAddProduct},
+		{Name: "sell product", Run: scenarioSellProduct},
+		{Name: "order discount and promo code", Run: scenarioOrderDiscount},
+		{Name: "several photos per product", Run: scenarioSeveralPhotos},
+		{Name: "catalogue", Run: scenarioCatalogue},
+		{Name: "inline search", Run: scenarioInlineSearch},
+		{Name: "categories", Run: scenarioCategories},
+		{Name: "ocr text", Run: scenarioOCRText},
+		{Name: "barcode", Run: scenarioBarcode},
+		{Name: "variants", Run: scenarioVariants},
+		{Name: "fractional quantities", Run: scenarioFractionalQuantities},
+		{Name: "catalogue import", Run: scenarioImport},
+		{Name: "export", Run: scenarioExport},
+		{Name: "background indexing", Run: scenarioBackgroundIndexing},
+		{Name: "reindex", Run: scenarioReindex},
+		{Name: "duplicates", Run: scenarioDuplicates},
+	}
+}
+
+// addProduct проводит мастер добавления товара
+func addProduct(t T, h *Harness, name, count, purchase, selling string) {
+	t.Helper()
+	addProductWithPhoto(t, h, shampooPhotoID, name, count, purchase, selling)
+}
+
+// addProductWithPhoto проводит мастер добавления товара с фото photoID
+func addProductWithPhoto(t T, h *Harness, photoID, name, count, purchase, selling string) {
+	t.Helper()
+
+	h.SendText("Добавить товар")
+	h.Expect(t, "Отправьте фото товара")
+	h.SendPhoto(photoID)
+	h.Expect(t, "Введите название товара")
+	h.SendText(name)
+	h.Expect(t, "Введите описание товара")
+	h.SendText("Тестовый товар")
+	h.Expect(t, "Выберите категорию")
+	h.SendText("-")
+	h.Expect(t, "Введите количество товара")
+	h.SendText(count)
+	h.Expect(t, "Введите цену закупки")
+	h.SendText(purchase)
+	h.Expect(t, "Введите цену продажи")
+	h.SendText(selling)
+	h.Expect(t, "Товар успешно добавлен")
+}
+
+func scenarioAddProduct(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Шампунь", "5", "100", "150")
+}
+
+func scenarioSellProduct(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooSampleID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Шампунь", "5", "100", "150")
+
+	// Поиск по фото
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "Шампунь")
+
+	// В корзину
+	h.Press(t, "add_item_to_cart_")
+	h.ExpectAnswer(t, "+1 добавлено · 🛍 150.00")
+	h.Expect(t, "Итого: 150.00")
+	h.Press(t, "add_item_to_cart_")
+	h.ExpectAnswer(t, "+1 добавлено · 🛍 300.00")
+
+	// Скидка 10% на строку
+	h.Press(t, "discount_item_in_cart_")
+	h.Expect(t, "Введите скидку")
+	h.SendText("10")
+	h.Expect(t, "Шампунь — 2 × 135.00 (−10%) = 270.00")
+
+	// Оплата из сводки корзины
+	h.Press(t, telegram.PaymentCmd)
+	h.Expect(t, "Способ оплаты")
+	h.Press(t, "pay_type_cash")
+	h.Expect(t, "✅ Заказ #")
+	h.Expect(t, "успешно сохранён")
+}
+
+func scenarioOrderDiscount(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooSampleID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Шампунь", "5", "100", "150")
+
+	h.SendText("/promo sale 10% - - 1")
+	h.Expect(t, "Промокод SALE создан")
+
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "Шампунь")
+	h.Press(t, "add_item_to_cart_")
+	h.Expect(t, "Итого: 150.00")
+
+	// Фиксированная цена строки
+	h.Press(t, "discount_item_in_cart_")
+	h.Expect(t, "Введите скидку")
+	h.SendText("=120")
+	h.Expect(t, "Итого: 120.00")
+
+	// Скидка на чек суммой
+	h.Press(t, telegram.OrderDiscountCmd)
+	h.Expect(t, "Введите скидку на чек")
+	h.SendText("20")
+	h.Expect(t, "Итого: 100.00")
+
+	// Промокод заменяет ручную скидку на чек
+	h.Press(t, telegram.PromoCodeCmd)
+	h.Expect(t, "Введите промокод")
+	h.SendText("sale")
+	h.Expect(t, "Промокод SALE (10%): −12.00")
+
+	h.Press(t, telegram.PaymentCmd)
+	h.Press(t, "pay_type_cash")
+	h.Expect(t, "Итого: 108.00")
+
+	h.SendText("/promos")
+	h.Expect(t, "использован 1 из 1")
+}
+
+func scenarioSeveralPhotos(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooBackID, shampooBackPhoto, shampooBackVector)
+	h.Server.AddPhoto(shampooBackSample, shampooBackPhoto, shampooBackVector)
+
+	h.SendText("Добавить товар")
+	h.Expect(t, "Отправьте фото товара")
+	h.SendPhoto(shampooPhotoID)
+	h.Expect(t, "Введите название товара")
+	h.SendPhoto(shampooBackID)
+	h.Expect(t, "Фото добавлено, всего: 2")
+	h.SendText("Шампунь")
+	h.Expect(t, "Введите описание товара")
+	h.SendText("Тестовый товар")
+	h.Expect(t, "Введите количество товара")
+	h.SendText("5")
+	h.Expect(t, "Введите цену закупки")
+	h.SendText("100")
+	h.Expect(t, "Введите цену продажи")
+	h.SendText("150")
+	h.Expect(t, "Товар успешно добавлен")
+
+	// Товар находится по второму ракурсу
+	h.SendPhoto(shampooBackSample)
+	h.Expect(t, "Шампунь")
+
+	h.Press(t, "photos_product_")
+	h.Expect(t, "Фото товара: 2")
+
+	// Фото лежат в S3, повторный показ идёт по file_id без загрузки байтов
+	if n := h.S3.Objects(); n != 2 {
+		t.Fatalf("expected 2 photos in blob store, got %d", n)
+	}
+	h.Press(t, "photos_product_")
+	h.Expect(t, "Фото товара: 2")
+	uploads := 0
+	for _, m := range h.Server.Messages() {
+		if m.ChatID == h.ChatID && m.Method == "sendPhoto" && m.Upload {
+			uploads++
+		}
+	}
+	// Превью найденного товара и два фото при первом показе
+	if uploads != 3 {
+		t.Fatalf("expected 3 photo uploads, got %d", uploads)
+	}
+}
+
+func scenarioCatalogue(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Шампунь", "2", "100", "150")
+
+	h.SendText("Меню")
+	h.Press(t, telegram.ListCmd)
+	h.Expect(t, "1. Шампунь — 150.00 · 2 шт.")
+
+	h.Press(t, telegram.CatalogSearchCmd)
+	h.Expect(t, "Введите название")
+	h.SendText("шамп")
+	h.Expect(t, "🔎 «шамп»")
+
+	h.Press(t, telegram.CatalogFilterCmd+"_"+"out")
+	h.Expect(t, "Ничего не найдено")
+	h.Press(t, telegram.CatalogFilterCmd+"_"+"low")
+	h.Expect(t, "Шампунь")
+
+	// Фото только по запросу
+	h.Press(t, telegram.CatalogItemCmd)
+	h.Expect(t, "Наличие: 2")
+}
+
+func scenarioInlineSearch(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Шампунь", "2", "100", "150")
+
+	// «@bot шамп» в любом чате
+	h.Inline("шамп")
+	results := h.ExpectInline(t, "Шампунь")
+
+	// Кнопка под отправленной карточкой кладёт товар в корзину личного чата
+	data := regexp.MustCompile(telegram.InlineAddCmd + `_\d+`).FindString(results)
+	if data == "" {
+		t.Fatalf("inline result has no cart button: %s", results)
+	}
+	h.PressInline(data)
+	h.ExpectAnswer(t, "+1 добавлено · 🛍 150.00")
+	h.Expect(t, "Итого: 150.00")
+}
+
+func scenarioCategories(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.SetCategory(shampooPhotoID, "Шампуни")
+	h.Server.AddPhoto(shampooSampleID, shampooPhoto, shampooVector)
+
+	h.SendText("/category Уход / Волосы")
+	h.Expect(t, "Категория «Уход / Волосы» создана")
+
+	// Категорию, предложенную распознаванием, можно создать одной кнопкой
+	h.SendText("Добавить товар")
+	h.Expect(t, "Отправьте фото товара")
+	h.SendPhoto(shampooPhotoID)
+	h.Expect(t, "Введите название товара")
+	h.SendText("Шампунь")
+	h.Expect(t, "Введите описание товара")
+	h.SendText("Тестовый товар")
+	h.Expect(t, "Похоже на «Шампуни»")
+	h.Press(t, telegram.PickSuggestedCategoryCmd)
+	h.Expect(t, "Введите количество товара")
+	h.SendText("3")
+	h.Expect(t, "Введите цену закупки")
+	h.SendText("100")
+	h.Expect(t, "Введите цену продажи")
+	h.SendText("150")
+	h.Expect(t, "Товар успешно добавлен")
+
+	h.SendText("/categories")
+	h.Expect(t, "• Шампуни")
+
+	// Фильтр каталога по категории
+	h.SendText("Меню")
+	h.Press(t, telegram.ListCmd)
+	h.Expect(t, "Шампунь")
+	h.Press(t, telegram.CatalogCategoriesCmd)
+	h.PressLabel(t, "Шампуни")
+	h.Expect(t, "📋 Каталог · 📂 Шампуни")
+
+	// Продажа попадает в отчёт своей категории
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "Шампунь")
+	h.Press(t, "add_item_to_cart_")
+	h.Expect(t, "Итого: 150.00")
+	h.Press(t, telegram.PaymentCmd)
+	h.Press(t, "pay_type_cash")
+	h.Expect(t, "успешно сохранён")
+
+	h.SendText("/sales")
+	h.Expect(t, "📂 Шампуни — 1 шт., 150.00")
+}
+
+func scenarioOCRText(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.SetText(shampooPhotoID, labelText)
+	h.Server.AddPhoto(labelFarID, shampooPhoto, labelFarVector)
+
+	// Без надписи дальний снимок не находит товар
+	h.SendPhoto(labelFarID)
+	h.Expect(t, "Введите название товара")
+
+	// Название предлагается по надписи на упаковке
+	h.SendText("Добавить товар")
+	h.Expect(t, "Отправьте фото товара")
+	h.SendPhoto(shampooPhotoID)
+	h.Expect(t, "На упаковке: «Head & Shoulders Classic Clean 400 ml»")
+	h.SendText("+")
+	h.Expect(t, "Введите описание товара")
+	h.SendText("Тестовый товар")
+	h.Expect(t, "Выберите категорию")
+	h.SendText("-")
+	h.Expect(t, "Введите количество товара")
+	h.SendText("3")
+	h.Expect(t, "Введите цену закупки")
+	h.SendText("100")
+	h.Expect(t, "Введите цену продажи")
+	h.SendText("150")
+	h.Expect(t, "Товар успешно добавлен")
+
+	// С той же надписью дальний снимок находит товар
+	h.Server.SetText(labelFarID, "HEAD & SHOULDERS classic clean")
+	h.SendPhoto(labelFarID)
+	h.Expect(t, "Head & Shoulders Classic Clean 400 ml")
+}
+
+func scenarioBarcode(t T, h *Harness) {
+	h.Server.AddPhoto(barcodeFrontID, barcodePhoto(shampooBarcode), shampooVector)
+	h.Server.AddPhoto(barcodeSampleID, barcodePhoto(shampooBarcode), shampooBackVector)
+
+	h.SendText("Добавить товар")
+	h.Expect(t, "Отправьте фото товара")
+	h.SendPhoto(barcodeFrontID)
+	h.Expect(t, "🏷 Штрихкод: "+shampooBarcode)
+	h.SendText("Шампунь")
+	h.Expect(t, "Введите описание товара")
+	h.SendText("Тестовый товар")
+	h.Expect(t, "Выберите категорию")
+	h.SendText("-")
+	h.Expect(t, "Введите количество товара")
+	h.SendText("3")
+	h.Expect(t, "Введите цену закупки")
+	h.SendText("100")
+	h.Expect(t, "Введите цену продажи")
+	h.SendText("150")
+	h.Expect(t, "Товар успешно добавлен")
+
+	// Совпавший штрихкод находит товар, хотя по вектору фото не похожи
+	h.SendPhoto(barcodeSampleID)
+	h.Expect(t, "🏷 Штрихкод: "+shampooBarcode)
+
+	// Повторное добавление с тем же штрихкодом показывает существующий товар
+	h.SendText("Добавить товар")
+	h.Expect(t, "Отправьте фото товара")
+	h.SendPhoto(barcodeSampleID)
+	h.Expect(t, "Найден похожий товар")
+}
+
+func scenarioVariants(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooSampleID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Кроссовки", "0", "100", "150")
+
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "Кроссовки")
+	h.Press(t, "variants_")
+	h.Expect(t, "Вариантов пока нет")
+
+	h.Press(t, "add_variant_")
+	h.Expect(t, "Введите вариант")
+	h.SendText("42; 2")
+	h.Expect(t, "• 42 — 2 шт., 150.00")
+	h.Press(t, "add_variant_")
+	h.SendText("43; 1; 170; NK-43")
+	h.Expect(t, "• 43 — 1 шт., 170.00, арт. NK-43")
+
+	// После совпадения по фото бот спрашивает вариант
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "Выберите вариант")
+	h.PressLabel(t, "42 · 2 шт.")
+	h.Expect(t, "Итого: 150.00")
+	h.PressLabel(t, "43 · 1 шт.")
+	h.Expect(t, "Итого: 320.00")
+
+	h.Press(t, telegram.PaymentCmd)
+	h.Press(t, "pay_type_cash")
+	h.Expect(t, "успешно сохранён")
+
+	// Остатки списаны по вариантам
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "• 42 — 1 шт., 150.00")
+	h.Expect(t, "• 43 — 0 шт., 170.00, арт. NK-43")
+}
+
+func scenarioFractionalQuantities(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooSampleID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Ткань", "2,5 м", "600", "1000")
+
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "📦 Наличие: 2.5 м")
+	h.Press(t, "add_item_to_cart_")
+	h.Expect(t, "Итого: 1000.00")
+
+	h.Press(t, "edit_count_item_in_cart_")
+	h.Expect(t, "Введите количество")
+	h.SendText("1,25")
+	h.Expect(t, "Ткань — 1.25 м × 1000.00 = 1250.00")
+
+	// Больше остатка продать нельзя
+	h.Press(t, "edit_count_item_in_cart_")
+	h.SendText("3")
+	h.Expect(t, "Превышен остаток: 2.5 м")
+
+	h.Press(t, telegram.PaymentCmd)
+	h.Press(t, "pay_type_cash")
+	h.Expect(t, "успешно сохранён")
+
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "📦 Наличие: 1.25 м")
+}
+
+func scenarioImport(t T, h *Harness) {
+	h.Server.AddFile("import_csv", []byte("Название;Количество;Цена закупки;Цена продажи;Штрихкод;Категория\n"+
+		"Шампунь;5;100;150,50;"+shampooBarcode+";Косметика/Волосы\n"+
+		"Ткань;2,5 м;600;1000;;\n"+
+		";3;10;20;;\n"+
+		"Мыло;1,5;10;;;\n"))
+
+	h.SendText(telegram.ImportCmd)
+	h.Expect(t, "Отправьте файл CSV или XLSX")
+	h.SendDocument("import_csv", "catalogue.csv")
+	h.Expect(t, "Товаров в файле: 4, готово к загрузке: 2, с ошибками: 2")
+	h.Expect(t, "Строка 4: не указано название")
+	h.Expect(t, "Строка 5: дробное количество у штучного товара; цена продажи не указана")
+	h.Expect(t, "1. Шампунь — 5 шт., 150.50 · Косметика/Волосы")
+
+	h.Press(t, telegram.ImportConfirmCmd)
+	h.Expect(t, "Загружено товаров: 2")
+
+	h.SendText("Меню")
+	h.Press(t, telegram.ListCmd)
+	h.Expect(t, "Ткань — 1000.00 · 2.5 м")
+}
+
+func scenarioExport(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooSampleID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Шампунь", "5", "100", "150")
+
+	h.SendText(telegram.ExportCatalogCmd + " csv")
+	doc := h.Expect(t, "📦 Каталог: 1 позиций, остаток по закупке 500.00, по продаже 750.00")
+	rows, err := table.ReadCSV(doc.Document)
+	if err != nil || len(rows) != 3 || rows[1][1] != "Шампунь" || rows[1][6] != "5" || rows[2][9] != "500" {
+		t.Fatalf("unexpected catalogue export: %q, %v", rows, err)
+	}
+
+	h.SendPhoto(shampooSampleID)
+	h.Press(t, "add_item_to_cart_")
+	h.Press(t, telegram.PaymentCmd)
+	h.Press(t, "pay_type_cash")
+	h.Expect(t, "успешно сохранён")
+
+	h.SendText(telegram.ExportOrdersCmd + " xlsx")
+	doc = h.Expect(t, "🧾 Заказы за")
+	if !strings.HasSuffix(doc.Text, "1, выручка 150.00") {
+		t.Fatalf("unexpected orders export caption: %q", doc.Text)
+	}
+	rows, err = table.ReadXLSX(doc.Document)
+	if err != nil || len(rows) != 2 || rows[1][5] != "Шампунь" || rows[1][10] != "150" {
+		t.Fatalf("unexpected orders export: %q, %v", rows, err)
+	}
+}
+
+func scenarioBackgroundIndexing(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooSampleID, shampooPhoto, shampooVector)
+
+	// CLIP недоступен: мастер не ждёт его и сохраняет товар
+	h.Server.SetClipDown(true)
+	h.SendText("Добавить товар")
+	h.Expect(t, "Отправьте фото товара")
+	h.SendPhoto(shampooPhotoID)
+	h.Expect(t, "Введите название товара")
+	h.SendText("Шампунь")
+	h.Expect(t, "Введите описание товара")
+	h.SendText("Тестовый товар")
+	h.Expect(t, "Введите количество товара")
+	h.SendText("5")
+	h.Expect(t, "Введите цену закупки")
+	h.SendText("100")
+	h.Expect(t, "Введите цену продажи")
+
+	// Сервис вернулся: фото индексируется из очереди
+	h.Server.SetClipDown(false)
+	h.SendText("150")
+	h.Expect(t, "Фото распознаются в фоне: 1")
+	h.WaitIndexed(t)
+
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "Шампунь")
+}
+
+func scenarioReindex(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooSampleID, shampooPhoto, shampooVector)
+	addProduct(t, h, "Шампунь", "5", "100", "150")
+
+	// Векторы старой модели не сравниваются с векторами новой: товар не находится
+	h.Recognizer.Model = "clip-vit-l-14"
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "Введите название товара")
+	h.SendText(telegram.CancelOperationsText)
+
+	h.SendText(telegram.ReindexCmd)
+	h.Expect(t, "Переиндексация моделью clip-vit-l-14 завершена: 1 из 1")
+
+	h.SendPhoto(shampooSampleID)
+	h.Expect(t, "Шампунь")
+}
+
+func scenarioDuplicates(t T, h *Harness) {
+	h.Server.AddPhoto(shampooPhotoID, shampooPhoto, shampooVector)
+	h.Server.AddPhoto(shampooBackID, shampooBackPhoto, shampooBackVector)
+	h.Server.AddPhoto(shampooBackSample, shampooBackPhoto, shampooBackVector)
+	addProduct(t, h, "Шампунь Head Shoulders", "5", "100", "150")
+
+	// Снимок сзади не похож на первый, и мастер заводит тот же товар второй раз
+	addProductWithPhoto(t, h, shampooBackID, "Шампунь Head Shoulders 400", "3", "100", "150")
+
+	h.SendText(telegram.DuplicatesCmd)
+	h.Expect(t, "Возможные дубли 1 из 1")
+	h.Press(t, telegram.MergeKeepFirstCmd)
+	h.ExpectAnswer(t, "Товары объединены")
+	h.Expect(t, "объединено 1, пропущено 0")
+
+	// Фото второго товара перешло к первому вместе с остатком
+	h.SendPhoto(shampooBackSample)
+	h.Expect(t, "Наличие: 8")
+
+	h.SendText(telegram.DuplicatesCmd)
+	h.Expect(t, "Похожих товаров не найдено")
+}
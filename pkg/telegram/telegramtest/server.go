@@ -0,0 +1,425 @@
+package telegramtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+)
+
+// Token токен, с которым бот обращается к поддельному серверу
+const Token = "123456:TEST"
+
+// SentMessage сообщение, отправленное ботом в поддельный Telegram
+type SentMessage struct {
+	Method    string
+	ChatID    int64
+	MessageID int
+	Text      string
+	Keyboard  *tgbotapi.InlineKeyboardMarkup
+	Deleted   bool
+	// FileID фото из sendPhoto, Upload — фото загружено байтами, а не отправлено по file_id
+	FileID string
+	Upload bool
+	// Document содержимое файла из sendDocument
+	Document []byte
+}
+
+// Server эмулирует эндпоинты Bot API, которые использует бот, и CLIP-сервис
+type Server struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	updates  []tgbotapi.Update
+	notify   chan struct{}
+	done     chan struct{}
+	nextUpd  int
+	nextMsg  int
+	log      []*SentMessage
+	byID     map[int]*SentMessage
+	answers  []string
+	inline   []string
+	files    map[string][]byte
+	features map[string][]float64
+	category map[string]string
+	text     map[string]string
+	clipDown bool
+}
+
+func NewServer() *Server {
+	s := &Server{
+		notify:   make(chan struct{}, 1),
+		done:     make(chan struct{}),
+		nextUpd:  1,
+		nextMsg:  1,
+		byID:     make(map[int]*SentMessage),
+		files:    make(map[string][]byte),
+		features: make(map[string][]float64),
+		category: make(map[string]string),
+		text:     make(map[string]string),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Close останавливает сервер и отпускает ожидающие getUpdates
+func (s *Server) Close() {
+	close(s.done)
+	s.Server.Close()
+}
+
+// Client возвращает HTTP-клиент, который направляет запросы к api.telegram.org на этот сервер
+func (s *Server) Client() *http.Client {
+	target, _ := url.Parse(s.URL)
+	return &http.Client{Transport: &rewriteTransport{target: target, base: http.DefaultTransport}}
+}
+
+// rewriteTransport подменяет хост api.telegram.org адресом поддельного сервера
+type rewriteTransport struct {
+	target *url.URL
+	base   http.RoundTripper
+}
+
+func (t *rewriteTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host != "api.telegram.org" {
+		return t.base.RoundTrip(req)
+	}
+	r := req.Clone(req.Context())
+	r.URL.Scheme = t.target.Scheme
+	r.URL.Host = t.target.Host
+	r.Host = t.target.Host
+	return t.base.RoundTrip(r)
+}
+
+// ClipURL адрес поддельного CLIP-сервиса
+func (s *Server) ClipURL() string {
+	return s.URL + "/extract_features"
+}
+
+// AddPhoto регистрирует фото, которое можно отправить боту, и вектор, который вернёт CLIP
+func (s *Server) AddPhoto(fileID string, data []byte, features []float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[fileID] = data
+	s.features[fileID] = features
+}
+
+// AddFile регистрирует документ, который можно отправить боту
+func (s *Server) AddFile(fileID string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[fileID] = data
+}
+
+// SetCategory задаёт категорию, которую CLIP вернёт для фото
+func (s *Server) SetCategory(fileID, category string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.category[fileID] = category
+}
+
+// SetText задаёт текст, который OCR найдёт на фото
+func (s *Server) SetText(fileID, text string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.text[fileID] = text
+}
+
+// SetClipDown включает и выключает недоступность CLIP-сервиса
+func (s *Server) SetClipDown(down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.clipDown = down
+}
+
+// Push ставит обновление в очередь getUpdates
+func (s *Server) Push(update tgbotapi.Update) {
+	s.mu.Lock()
+	update.UpdateID = s.nextUpd
+	s.nextUpd++
+	s.updates = append(s.updates, update)
+	s.mu.Unlock()
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// Messages возвращает копию журнала сообщений, отправленных ботом
+func (s *Server) Messages() []SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := make([]SentMessage, len(s.log))
+	for i, m := range s.log {
+		res[i] = *m
+	}
+	return res
+}
+
+// Answers возвращает тексты ответов на нажатия inline-кнопок
+func (s *Server) Answers() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.answers...)
+}
+
+// InlineResults возвращает JSON результатов каждого ответа на inline-запрос
+func (s *Server) InlineResults() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.inline...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	switch {
+	case path == "/extract_features":
+		s.serveClip(w, r)
+	case strings.HasPrefix(path, "/file/bot"+Token+"/"):
+		s.serveFile(w, strings.TrimPrefix(path, "/file/bot"+Token+"/"))
+	case strings.HasPrefix(path, "/bot"+Token+"/"):
+		s.serveMethod(w, r, strings.TrimPrefix(path, "/bot"+Token+"/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveMethod(w http.ResponseWriter, r *http.Request, method string) {
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		if err := r.ParseMultipartForm(32 << 20); err != nil {
+			writeError(w, err)
+			return
+		}
+	} else if err := r.ParseForm(); err != nil {
+		writeError(w, err)
+		return
+	}
+
+	switch method {
+	case "getMe":
+		writeResult(w, tgbotapi.User{ID: 1, IsBot: true, UserName: "test_bot"})
+	case "getUpdates":
+		s.serveUpdates(w, r)
+	case "sendMessage", "sendPhoto", "sendDocument":
+		writeResult(w, s.recordSent(method, r))
+	case "editMessageText", "editMessageReplyMarkup":
+		msg, err := s.recordEdit(method, r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeResult(w, msg)
+	case "deleteMessage":
+		s.mu.Lock()
+		if m, ok := s.byID[formInt(r, "message_id")]; ok {
+			m.Deleted = true
+		}
+		s.mu.Unlock()
+		writeResult(w, true)
+	case "answerCallbackQuery":
+		s.mu.Lock()
+		s.answers = append(s.answers, r.FormValue("text"))
+		s.mu.Unlock()
+		writeResult(w, true)
+	case "answerInlineQuery":
+		s.mu.Lock()
+		s.inline = append(s.inline, r.FormValue("results"))
+		s.mu.Unlock()
+		writeResult(w, true)
+	case "getFile":
+		fileID := r.FormValue("file_id")
+		s.mu.Lock()
+		_, ok := s.files[fileID]
+		s.mu.Unlock()
+		if !ok {
+			writeError(w, fmt.Errorf("file %s not found", fileID))
+			return
+		}
+		writeResult(w, tgbotapi.File{FileID: fileID, FilePath: "photos/" + fileID + ".jpg"})
+	default:
+		writeError(w, fmt.Errorf("method %s is not emulated", method))
+	}
+}
+
+func (s *Server) serveUpdates(w http.ResponseWriter, r *http.Request) {
+	offset := formInt(r, "offset")
+	deadline := time.After(time.Second)
+	for {
+		s.mu.Lock()
+		var pending []tgbotapi.Update
+		for _, u := range s.updates {
+			if u.UpdateID >= offset {
+				pending = append(pending, u)
+			}
+		}
+		s.mu.Unlock()
+
+		if len(pending) > 0 {
+			writeResult(w, pending)
+			return
+		}
+
+		select {
+		case <-s.notify:
+		case <-deadline:
+			writeResult(w, []tgbotapi.Update{})
+			return
+		case <-s.done:
+			writeResult(w, []tgbotapi.Update{})
+			return
+		}
+	}
+}
+
+func (s *Server) recordSent(method string, r *http.Request) tgbotapi.Message {
+	chatID, _ := strconv.ParseInt(r.FormValue("chat_id"), 10, 64)
+	text := r.FormValue("text")
+	if method != "sendMessage" {
+		text = r.FormValue("caption")
+	}
+
+	var upload, document []byte
+	if method == "sendPhoto" && r.MultipartForm != nil {
+		if f, _, err := r.FormFile("photo"); err == nil {
+			upload, _ = io.ReadAll(f)
+			f.Close()
+		}
+	}
+	if method == "sendDocument" && r.MultipartForm != nil {
+		if f, _, err := r.FormFile("document"); err == nil {
+			document, _ = io.ReadAll(f)
+			f.Close()
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m := &SentMessage{
+		Method:    method,
+		ChatID:    chatID,
+		MessageID: s.nextMsg,
+		Text:      text,
+		Keyboard:  parseKeyboard(r.FormValue("reply_markup")),
+		Document:  document,
+	}
+	s.nextMsg++
+	s.log = append(s.log, m)
+	s.byID[m.MessageID] = m
+
+	sent := tgbotapi.Message{
+		MessageID: m.MessageID,
+		Chat:      &tgbotapi.Chat{ID: chatID},
+		Text:      text,
+		Date:      int(time.Now().Unix()),
+	}
+	if method == "sendPhoto" {
+		// Загруженное фото получает новый file_id, как в Telegram
+		m.FileID, m.Upload = r.FormValue("photo"), upload != nil
+		if m.Upload {
+			m.FileID = fmt.Sprintf("sent_photo_%d", m.MessageID)
+			s.files[m.FileID] = upload
+		}
+		sent.Photo = &[]tgbotapi.PhotoSize{{FileID: m.FileID, Width: 640, Height: 480}}
+	}
+	return sent
+}
+
+func (s *Server) recordEdit(method string, r *http.Request) (tgbotapi.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.byID[formInt(r, "message_id")]
+	if !ok {
+		return tgbotapi.Message{}, fmt.Errorf("message %s not found", r.FormValue("message_id"))
+	}
+	if method == "editMessageText" {
+		m.Text = r.FormValue("text")
+	}
+	m.Keyboard = parseKeyboard(r.FormValue("reply_markup"))
+	s.log = append(s.log, &SentMessage{
+		Method:    method,
+		ChatID:    m.ChatID,
+		MessageID: m.MessageID,
+		Text:      m.Text,
+		Keyboard:  m.Keyboard,
+	})
+
+	return tgbotapi.Message{MessageID: m.MessageID, Chat: &tgbotapi.Chat{ID: m.ChatID}, Text: m.Text}, nil
+}
+
+func (s *Server) serveFile(w http.ResponseWriter, filePath string) {
+	fileID := strings.TrimSuffix(strings.TrimPrefix(filePath, "photos/"), ".jpg")
+	s.mu.Lock()
+	data, ok := s.files[fileID]
+	s.mu.Unlock()
+	if !ok {
+		http.Error(w, "not found", http.StatusNotFound)
+		return
+	}
+	_, _ = w.Write(data)
+}
+
+// serveClip отвечает как CLIP-сервис: вектор выбирается по file_id из image_url
+func (s *Server) serveClip(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	imageURL := r.FormValue("image_url")
+	fileID := strings.TrimSuffix(imageURL[strings.LastIndex(imageURL, "/")+1:], ".jpg")
+
+	s.mu.Lock()
+	features, ok := s.features[fileID]
+	category := s.category[fileID]
+	text := s.text[fileID]
+	down := s.clipDown
+	s.mu.Unlock()
+	if down {
+		http.Error(w, "clip is down", http.StatusServiceUnavailable)
+		return
+	}
+	if !ok {
+		http.Error(w, "unknown image", http.StatusNotFound)
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"best_category":  category,
+		"extracted_text": text,
+		"features":       features,
+		"similarities":   map[string]float64{category: 0.9},
+	})
+}
+
+func parseKeyboard(raw string) *tgbotapi.InlineKeyboardMarkup {
+	if raw == "" {
+		return nil
+	}
+	var kb tgbotapi.InlineKeyboardMarkup
+	if err := json.Unmarshal([]byte(raw), &kb); err != nil || kb.InlineKeyboard == nil {
+		return nil
+	}
+	return &kb
+}
+
+func formInt(r *http.Request, key string) int {
+	v, _ := strconv.Atoi(r.FormValue(key))
+	return v
+}
+
+func writeResult(w http.ResponseWriter, result interface{}) {
+	raw, _ := json.Marshal(result)
+	_ = json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: true, Result: raw})
+}
+
+func writeError(w http.ResponseWriter, err error) {
+	_ = json.NewEncoder(w).Encode(tgbotapi.APIResponse{Ok: false, Description: err.Error()})
+}
@@ -0,0 +1,240 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Bariban/vector-shop-bot/pkg/cart"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api"
+	"github.com/shopspring/decimal"
+)
+
+// handleVariants показывает варианты товара с кнопками добавления и удаления
+func (b *Bot) handleVariants(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	product, err := b.storage.GetProductByID(context.Background(), b.tempProduct[chatID].ProductID)
+	if err != nil || product == nil {
+		b.alert(callback, "Товар не найден")
+		return err
+	}
+	if product.ParentID != 0 {
+		b.alert(callback, "У варианта не может быть своих вариантов")
+		return nil
+	}
+	return b.sendVariants(chatID, product, 0)
+}
+
+// sendVariants отправляет список вариантов товара или обновляет сообщение msgID
+func (b *Bot) sendVariants(chatID int64, product *storage.Product, msgID int) error {
+	variants, err := b.storage.GetVariants(context.Background(), product.ProductID)
+	if err != nil {
+		return err
+	}
+
+	text := fmt.Sprintf("🎨 Варианты «%s»\n\n", product.Name)
+	if len(variants) == 0 {
+		text += "Вариантов пока нет. Добавьте размеры или цвета, чтобы вести их остатки отдельно."
+	} else {
+		text += variantLines(variants)
+	}
+	keyboard := getVariantsKeyboard(product.ProductID, variants)
+
+	if msgID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, msgID, text)
+		edit.ReplyMarkup = &keyboard
+		_, err = b.bot.Send(edit)
+		return err
+	}
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = keyboard
+	_, err = b.bot.Send(msg)
+	return err
+}
+
+// variantLines строки вариантов с остатком, ценой и артикулом
+func variantLines(variants []*storage.Product) string {
+	var sb strings.Builder
+	for _, v := range variants {
+		fmt.Fprintf(&sb, "• %s — %s, %s", v.Variant, formatQuantity(v.Count, v.Unit), v.SellingPrice.StringFixed(cart.Places))
+		if v.SKU != "" {
+			fmt.Fprintf(&sb, ", арт. %s", v.SKU)
+		}
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+// getVariantsKeyboard кнопки удаления вариантов и добавления нового
+func getVariantsKeyboard(productID uint, variants []*storage.Product) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, v := range variants {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData("🗑 "+v.Variant, fmt.Sprintf("%s_%d", DelVariantCmd, v.ProductID)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("➕ Добавить вариант", fmt.Sprintf("%s_%d", AddVariantCmd, productID)),
+	))
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handleAddVariant запрашивает параметры нового варианта
+func (b *Bot) handleAddVariant(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	b.states[chatID] = stateWaitingForVariant
+	msg := tgbotapi.NewMessage(chatID, "Введите вариант: название; количество; цена; артикул\n"+
+		"Цена и артикул необязательны, без цены действует цена товара.\n"+
+		"Например: 42 чёрный; 5; 15000; NK-42-BLK\n"+
+		"Вариант с тем же названием будет обновлён.")
+	_, err := b.bot.Send(msg)
+	return err
+}
+
+// handleVariantText сохраняет вариант, введённый продавцом
+func (b *Bot) handleVariantText(message *tgbotapi.Message) error {
+	chatID := message.Chat.ID
+	ctx := context.Background()
+
+	product, err := b.storage.GetProductByID(ctx, b.tempProduct[chatID].ProductID)
+	if err != nil || product == nil {
+		delete(b.states, chatID)
+		_, _ = b.bot.Send(tgbotapi.NewMessage(chatID, "Товар не найден"))
+		return err
+	}
+
+	variant, err := parseVariant(message.Text)
+	if err != nil {
+		_, err = b.bot.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return err
+	}
+	if !storage.Fractional(product.Unit) && !variant.Count.IsInteger() {
+		_, err = b.bot.Send(tgbotapi.NewMessage(chatID, "Количество штучного товара должно быть целым"))
+		return err
+	}
+	variant.ParentID = product.ProductID
+	variant.UserName = product.UserName
+
+	if _, err := b.storage.SaveVariant(ctx, variant); err != nil {
+		_, _ = b.bot.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить вариант"))
+		return err
+	}
+	delete(b.states, chatID)
+
+	if _, err := b.bot.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Вариант «%s» сохранён", variant.Variant))); err != nil {
+		return err
+	}
+	return b.sendVariants(chatID, product, 0)
+}
+
+// parseVariant разбирает строку «название; количество; цена; артикул»
+func parseVariant(text string) (*storage.Product, error) {
+	fields := strings.Split(text, ";")
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	if len(fields) < 2 || len(fields) > 4 || fields[0] == "" {
+		return nil, fmt.Errorf("Введите вариант в формате: название; количество; цена; артикул")
+	}
+
+	count, _, err := parseQuantity(fields[1])
+	if err != nil {
+		return nil, fmt.Errorf("Количество должно быть неотрицательным числом")
+	}
+	variant := &storage.Product{Variant: fields[0], Count: count}
+
+	if len(fields) > 2 && fields[2] != "" && fields[2] != "-" {
+		price, err := decimal.NewFromString(fields[2])
+		if err != nil || !price.IsPositive() {
+			return nil, fmt.Errorf("Цена должна быть положительным числом")
+		}
+		variant.SellingPrice = price
+	}
+	if len(fields) > 3 {
+		variant.SKU = fields[3]
+	}
+	return variant, nil
+}
+
+// handleDelVariant удаляет вариант и обновляет список вариантов
+func (b *Bot) handleDelVariant(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	ctx := context.Background()
+
+	variant, err := b.storage.GetProductByID(ctx, b.tempProduct[chatID].ProductID)
+	if err != nil || variant == nil || variant.ParentID == 0 {
+		b.alert(callback, "Вариант не найден")
+		return err
+	}
+	if err := b.storage.RemoveVariant(ctx, variant.ProductID); err != nil {
+		b.alert(callback, "Не удалось удалить вариант")
+		return err
+	}
+	b.toast(callback, "Удалено")
+
+	product, err := b.storage.GetProductByID(ctx, variant.ParentID)
+	if err != nil || product == nil {
+		return err
+	}
+	return b.sendVariants(chatID, product, callback.Message.MessageID)
+}
+
+// getVariantPickKeyboard кнопки выбора варианта для продажи
+func getVariantPickKeyboard(variants []*storage.Product) tgbotapi.InlineKeyboardMarkup {
+	var rows [][]tgbotapi.InlineKeyboardButton
+	var row []tgbotapi.InlineKeyboardButton
+	for _, v := range variants {
+		label := fmt.Sprintf("%s · %s", v.Variant, formatQuantity(v.Count, v.Unit))
+		if !v.Count.IsPositive() {
+			label = v.Variant + " · нет"
+		}
+		row = append(row, tgbotapi.NewInlineKeyboardButtonData(label, fmt.Sprintf("%s_%d", PickVariantCmd, v.ProductID)))
+		if len(row) == 2 {
+			rows = append(rows, row)
+			row = nil
+		}
+	}
+	if len(row) > 0 {
+		rows = append(rows, row)
+	}
+	return tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// handlePickVariant добавляет выбранный вариант в корзину. Для нового варианта в корзине
+// отправляется его карточка с кнопками количества и скидки
+func (b *Bot) handlePickVariant(callback *tgbotapi.CallbackQuery) error {
+	chatID := callback.Message.Chat.ID
+	variant, err := b.storage.GetProductByID(context.Background(), b.tempProduct[chatID].ProductID)
+	if err != nil || variant == nil || variant.UserName != callback.From.UserName {
+		b.alert(callback, "Вариант не найден")
+		return err
+	}
+
+	c := b.cart(chatID)
+	c.Put(productLine(variant))
+	if err := c.Add(variant.ProductID, one); err != nil {
+		b.toast(callback, "Нет в наличии")
+		return nil
+	}
+	b.toast(callback, fmt.Sprintf("+1 добавлено · 🛍 %s", c.Total().StringFixed(cart.Places)))
+
+	if line, _ := c.Line(variant.ProductID); line.Quantity.Equal(one) {
+		msg := tgbotapi.NewMessage(chatID, productCardText(variant))
+		msg.ParseMode = "Markdown"
+		msg.ReplyMarkup = b.getCountItemInCartKeyboard(chatID, variant.ProductID)
+		sent, err := b.bot.Send(msg)
+		if err != nil {
+			return err
+		}
+		// Чат не очищаем: карточка с выбором вариантов нужна, чтобы добавить другой размер
+		b.tracker.rememberCard(chatID, variant.ProductID, sent.MessageID)
+	}
+	return b.refreshCartLine(chatID, variant.ProductID)
+}
@@ -0,0 +1,140 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Bariban/vector-shop-bot/pkg/ann"
+	"github.com/Bariban/vector-shop-bot/pkg/storage"
+)
+
+// annCandidates сколько ближайших по вектору фото индекс отдаёт на проверку с учётом надписей
+const annCandidates = 32
+
+// SetVectorIndex включает поиск похожих фото по индексу в памяти вместо перебора всех
+// векторов магазина. Индекс нужно загрузить LoadVectorIndex до запуска бота
+func (b *Bot) SetVectorIndex(registry *ann.Registry) {
+	b.vectors = registry
+}
+
+// LoadVectorIndex читает снимки индексов и сверяет их с базой: база остаётся источником
+// правды, снимок лишь избавляет от построения графа заново
+func (b *Bot) LoadVectorIndex(ctx context.Context) error {
+	loaded, err := b.vectors.Load()
+	if err != nil {
+		return err
+	}
+	shops, err := b.storage.GetVectorsByModel(ctx, b.vectors.Model)
+	if err != nil {
+		return err
+	}
+	for _, name := range b.vectors.Shops() {
+		if _, ok := shops[name]; !ok {
+			shops[name] = nil
+		}
+	}
+
+	for name, images := range shops {
+		items := make([]ann.Item, 0, len(images))
+		for _, image := range images {
+			items = append(items, vectorItem(image))
+		}
+		added, removed, err := b.vectors.Shop(name).Sync(items)
+		if err != nil {
+			log.Printf("индекс фото магазина %s собран не полностью: %v", name, err)
+		}
+		if added > 0 || removed > 0 {
+			log.Printf("индекс фото магазина %s: добавлено %d, удалено %d", name, added, removed)
+		}
+	}
+	log.Printf("индексы фото загружены: снимков %d, магазинов %d", loaded, len(shops))
+	return b.vectors.Save()
+}
+
+// RunVectorSnapshots сохраняет изменённые индексы фото на диск до отмены ctx
+func (b *Bot) RunVectorSnapshots(ctx context.Context, interval time.Duration) {
+	b.vectors.Run(ctx, interval)
+}
+
+// indexVectors добавляет в индекс фото, векторы которых посчитаны текущей моделью
+func (b *Bot) indexVectors(userName string, images []*storage.ImageMeta) {
+	if b.vectors == nil {
+		return
+	}
+	for _, image := range images {
+		if image.ImageID == 0 || image.Float == nil || image.Model != b.vectors.Model {
+			continue
+		}
+		if err := b.vectors.Shop(userName).Add(vectorItem(image)); err != nil {
+			log.Printf("фото %d не добавлено в индекс: %v", image.ImageID, err)
+		}
+	}
+}
+
+// unindexImage убирает фото из индекса
+func (b *Bot) unindexImage(userName string, imageID uint) {
+	if b.vectors != nil {
+		b.vectors.Shop(userName).Remove(imageID)
+	}
+}
+
+// unindexProduct убирает из индекса все фото товара
+func (b *Bot) unindexProduct(userName string, productID uint) {
+	if b.vectors != nil {
+		b.vectors.Shop(userName).RemoveProduct(productID)
+	}
+}
+
+// moveProductVectors переносит фото объединённого товара from к товару to
+func (b *Bot) moveProductVectors(userName string, from, to uint) {
+	if b.vectors != nil {
+		b.vectors.Shop(userName).MoveProduct(from, to)
+	}
+}
+
+// vectorMatch фото, похожее на образец, и расстояние между векторами
+type vectorMatch struct {
+	image    *storage.ImageMeta
+	distance float64
+}
+
+// nearestImages возвращает фото магазина для сравнения с образцом. С индексом это
+// annCandidates ближайших фото без ключей для отправки, complete = false. Без индекса —
+// все фото той же модели и размерности
+func (b *Bot) nearestImages(userName string, sample *storage.ImageMeta) (matches []vectorMatch, complete bool, err error) {
+	if b.vectors != nil && sample.Model == b.vectors.Model {
+		index := b.vectors.Shop(userName)
+		if index.Dim() == len(sample.Float) {
+			results, err := index.Search(sample.Float, annCandidates)
+			if err != nil {
+				return nil, false, err
+			}
+			for _, r := range results {
+				image := &storage.ImageMeta{ImageID: r.ID, ProductID: r.ProductID, Text: r.Text}
+				matches = append(matches, vectorMatch{image: image, distance: r.Distance})
+			}
+			return matches, false, nil
+		}
+	}
+
+	// Сравнимы только векторы той же модели и размерности, что у образца
+	images, err := b.storage.GetVectorsByUsername(context.Background(), userName, sample.Model, len(sample.Float))
+	if err != nil {
+		return nil, false, err
+	}
+	for _, image := range images {
+		distance, err := b.metric.Distance(sample.Float, image.Float)
+		if err != nil {
+			// Один несовместимый вектор не должен срывать весь поиск
+			log.Printf("фото %d пропущено при поиске: %v", image.ImageID, err)
+			continue
+		}
+		matches = append(matches, vectorMatch{image: image, distance: distance})
+	}
+	return matches, true, nil
+}
+
+func vectorItem(image *storage.ImageMeta) ann.Item {
+	return ann.Item{ID: image.ImageID, ProductID: image.ProductID, Text: image.Text, Vector: image.Float}
+}